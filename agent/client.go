@@ -0,0 +1,138 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+// Client : a connection to an agent, offering the read methods of the vault it serves
+type Client struct {
+	conn   net.Conn
+	reader *bufio.Reader
+	info   enpasscli.VaultInfo
+}
+
+// Dial : connect to the agent listening at path and fetch the info of its vault, which also checks
+// that both speak the same protocol version
+func Dial(ctx context.Context, path string) (*Client, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "unix", path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not connect to agent")
+	}
+
+	c := &Client{conn: conn, reader: bufio.NewReader(conn)}
+	resp, err := c.call(ctx, Request{Op: OpInfo})
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if resp.Info == nil {
+		conn.Close()
+		return nil, errors.New("agent sent no vault info")
+	}
+
+	c.info = *resp.Info
+	return c, nil
+}
+
+// Close : close the connection, the agent keeps running
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Info : the vault.json contents of the vault the agent serves, as of Dial
+func (c *Client) Info() enpasscli.VaultInfo {
+	return c.info
+}
+
+// GetItems : see enpasscli.Vault.GetItems
+func (c *Client) GetItems(ctx context.Context) ([]enpasscli.Item, error) {
+	resp, err := c.call(ctx, Request{Op: OpItems})
+	if err != nil {
+		return nil, err
+	}
+
+	return resp.Items, nil
+}
+
+// FindItem : see enpasscli.Vault.FindItem
+func (c *Client) FindItem(ctx context.Context, query string) (enpasscli.Item, error) {
+	resp, err := c.call(ctx, Request{Op: OpFind, Query: query})
+	if err != nil {
+		return enpasscli.Item{}, err
+	}
+	if resp.Item == nil {
+		return enpasscli.Item{}, errors.New("agent sent no item")
+	}
+
+	return *resp.Item, nil
+}
+
+// Search : see enpasscli.Vault.Search, the query is applied on this side
+func (c *Client) Search(ctx context.Context, query enpasscli.Query) ([]enpasscli.Item, error) {
+	items, err := c.GetItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return query.Filter(items), nil
+}
+
+// Lock : make the agent close its vault and stop
+func (c *Client) Lock(ctx context.Context) error {
+	_, err := c.call(ctx, Request{Op: OpLock})
+	return err
+}
+
+func (c *Client) call(ctx context.Context, req Request) (Response, error) {
+	// cancelling ctx interrupts the read or write in progress, a deadline left by an earlier call is cleared
+	c.conn.SetDeadline(time.Time{})
+	stop, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		select {
+		case <-ctx.Done():
+			c.conn.SetDeadline(time.Now())
+		case <-stop:
+		}
+	}()
+	defer func() {
+		close(stop)
+		<-stopped
+	}()
+
+	req.Version = ProtocolVersion
+	line, err := json.Marshal(req)
+	if err != nil {
+		return Response{}, err
+	}
+	if _, err := c.conn.Write(append(line, '\n')); err != nil {
+		return Response{}, errors.Wrap(err, "could not send request to agent")
+	}
+
+	line, err = c.reader.ReadBytes('\n')
+	if err != nil {
+		return Response{}, errors.Wrap(err, "could not read response from agent")
+	}
+
+	var resp Response
+	if err := json.Unmarshal(line, &resp); err != nil {
+		return Response{}, errors.Wrap(err, "could not parse response from agent")
+	}
+	if resp.Version != ProtocolVersion {
+		return Response{}, errors.Wrapf(ErrUnsupportedVersion, "agent speaks version %d, this client version %d", resp.Version, ProtocolVersion)
+	}
+	if resp.Error != nil {
+		return resp, resp.Error
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,34 @@
+package agent
+
+import (
+	"net"
+	"os"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// checkPeer : only processes of the user running the agent may use it, whatever the socket permissions
+func checkPeer(conn *net.UnixConn) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var cred *syscall.Ucred
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return err
+	}
+	if credErr != nil {
+		return errors.Wrap(credErr, "could not read peer credentials")
+	}
+
+	if int(cred.Uid) != os.Getuid() {
+		return errors.Errorf("peer uid %d is not %d", cred.Uid, os.Getuid())
+	}
+
+	return nil
+}
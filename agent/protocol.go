@@ -0,0 +1,97 @@
+package agent
+
+import (
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+// ProtocolVersion : sent with every request and response, raised when either changes incompatibly
+const ProtocolVersion = 1
+
+// operations a request can ask for
+const (
+	// the vault.json contents of the unlocked vault
+	OpInfo = "info"
+	// every item with its fields
+	OpItems = "items"
+	// the one item matching Request.Query, see enpasscli.Vault.FindItem
+	OpFind = "find"
+	// close the vault and stop the agent
+	OpLock = "lock"
+)
+
+// error codes of a response, each one maps back to an error of enpasscli
+const (
+	CodeItemNotFound       = "item_not_found"
+	CodeAmbiguousMatch     = "ambiguous_match"
+	CodeLocked             = "locked"
+	CodeBadRequest         = "bad_request"
+	CodeUnsupportedVersion = "unsupported_version"
+	CodeInternal           = "internal"
+)
+
+// Request : one JSON line sent by the client
+type Request struct {
+	Version int    `json:"version"`
+	Op      string `json:"op"`
+	Query   string `json:"query,omitempty"`
+}
+
+// Response : one JSON line sent back for every request
+type Response struct {
+	Version int                  `json:"version"`
+	Error   *Error               `json:"error,omitempty"`
+	Info    *enpasscli.VaultInfo `json:"info,omitempty"`
+	Items   []enpasscli.Item     `json:"items,omitempty"`
+	Item    *enpasscli.Item      `json:"item,omitempty"`
+}
+
+var (
+	// ErrLocked : the agent locked the vault and stopped serving
+	ErrLocked = errors.New("agent is locked")
+	// ErrUnsupportedVersion : the agent speaks another protocol version
+	ErrUnsupportedVersion = errors.New("unsupported agent protocol version")
+)
+
+// Error : a failed request, matches the error of its code
+type Error struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	switch e.Code {
+	case CodeItemNotFound:
+		return enpasscli.ErrItemNotFound
+	case CodeAmbiguousMatch:
+		return enpasscli.ErrAmbiguousMatch
+	case CodeLocked:
+		return ErrLocked
+	case CodeUnsupportedVersion:
+		return ErrUnsupportedVersion
+	default:
+		return nil
+	}
+}
+
+// errorResponse : the response for err, with the code of the enpasscli error it wraps
+func errorResponse(err error) Response {
+	code := CodeInternal
+	switch {
+	case errors.Is(err, enpasscli.ErrItemNotFound):
+		code = CodeItemNotFound
+	case errors.Is(err, enpasscli.ErrAmbiguousMatch):
+		code = CodeAmbiguousMatch
+	case errors.Is(err, ErrLocked):
+		code = CodeLocked
+	case errors.Is(err, ErrUnsupportedVersion):
+		code = CodeUnsupportedVersion
+	}
+
+	return Response{Version: ProtocolVersion, Error: &Error{Code: code, Message: err.Error()}}
+}
@@ -0,0 +1,247 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+const (
+	// longest request line the server reads
+	maxRequestSize = 64 * 1024
+	// connections without a request for this long are closed
+	connIdleTimeout = time.Minute
+)
+
+// Server : serves the items of one unlocked vault until it locks, which closes the vault
+type Server struct {
+	idleTimeout time.Duration
+	log         enpasscli.Logger
+
+	// vault is nil once the server locked
+	mu    sync.RWMutex
+	vault *enpasscli.Vault
+	conns map[net.Conn]struct{}
+	done  chan struct{}
+
+	idleMu sync.Mutex
+	idle   *time.Timer
+}
+
+// NewServer : a server for vault that locks after idleTimeout without requests, never when it is zero
+func NewServer(vault *enpasscli.Vault, idleTimeout time.Duration, log enpasscli.Logger) *Server {
+	return &Server{
+		idleTimeout: idleTimeout,
+		log:         log,
+		vault:       vault,
+		conns:       make(map[net.Conn]struct{}),
+		done:        make(chan struct{}),
+	}
+}
+
+// Listen : a Unix domain socket at path that only its owner can use, path must not exist yet
+func Listen(path string) (*net.UnixListener, error) {
+	if _, err := os.Lstat(path); err == nil {
+		return nil, errors.Errorf("%s already exists, is another agent running?", path)
+	}
+
+	l, err := net.ListenUnix("unix", &net.UnixAddr{Name: path, Net: "unix"})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not listen")
+	}
+
+	if err := os.Chmod(path, 0600); err != nil {
+		l.Close()
+		return nil, errors.Wrap(err, "could not restrict socket permissions")
+	}
+
+	return l, nil
+}
+
+// Serve : answer requests on l until the server locks or ctx is done, then lock and close l,
+// which removes the socket
+func (s *Server) Serve(ctx context.Context, l *net.UnixListener) error {
+	defer l.Close()
+	defer s.Lock()
+
+	if s.idleTimeout > 0 {
+		s.idleMu.Lock()
+		s.idle = time.AfterFunc(s.idleTimeout, func() {
+			s.log.Debugf("agent idle for %s", s.idleTimeout)
+			s.Lock()
+		})
+		s.idleMu.Unlock()
+	}
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-s.done:
+		}
+		// unblocks Accept
+		l.Close()
+	}()
+
+	for {
+		conn, err := l.AcceptUnix()
+		if err != nil {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-s.done:
+				return nil
+			default:
+				return errors.Wrap(err, "could not accept connection")
+			}
+		}
+
+		if !s.track(conn) {
+			conn.Close()
+			return nil
+		}
+		go s.serveConn(ctx, conn)
+	}
+}
+
+// Lock : close the vault and every connection and stop serving, safe to call more than once
+func (s *Server) Lock() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.vault == nil {
+		return
+	}
+
+	s.vault.Close()
+	s.vault = nil
+	close(s.done)
+
+	for conn := range s.conns {
+		conn.Close()
+	}
+
+	s.idleMu.Lock()
+	if s.idle != nil {
+		s.idle.Stop()
+	}
+	s.idleMu.Unlock()
+
+	s.log.Debugf("agent locked")
+}
+
+// track : remember conn so Lock closes it, false once locked
+func (s *Server) track(conn net.Conn) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.vault == nil {
+		return false
+	}
+
+	s.conns[conn] = struct{}{}
+	return true
+}
+
+func (s *Server) untrack(conn net.Conn) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.conns, conn)
+}
+
+// touch : a request restarts the idle timeout
+func (s *Server) touch() {
+	s.idleMu.Lock()
+	defer s.idleMu.Unlock()
+
+	if s.idle != nil {
+		s.idle.Reset(s.idleTimeout)
+	}
+}
+
+// serveConn : one JSON request per line, each answered with one JSON response line
+func (s *Server) serveConn(ctx context.Context, conn *net.UnixConn) {
+	defer s.untrack(conn)
+	defer conn.Close()
+
+	if err := checkPeer(conn); err != nil {
+		s.log.Debugf("agent refused connection: %v", err)
+		return
+	}
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 4096), maxRequestSize)
+	encoder := json.NewEncoder(conn)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(connIdleTimeout))
+		if !scanner.Scan() {
+			return
+		}
+
+		var req Request
+		var resp Response
+		if err := json.Unmarshal(scanner.Bytes(), &req); err != nil {
+			resp = Response{Version: ProtocolVersion, Error: &Error{Code: CodeBadRequest, Message: "could not parse request"}}
+		} else {
+			resp = s.handle(ctx, req)
+		}
+
+		conn.SetWriteDeadline(time.Now().Add(connIdleTimeout))
+		err := encoder.Encode(resp)
+
+		if req.Op == OpLock && resp.Error == nil {
+			s.Lock()
+			return
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (s *Server) handle(ctx context.Context, req Request) Response {
+	if req.Version != ProtocolVersion {
+		return errorResponse(errors.Wrapf(ErrUnsupportedVersion, "agent speaks version %d, the request is version %d", ProtocolVersion, req.Version))
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.vault == nil {
+		return errorResponse(ErrLocked)
+	}
+
+	s.touch()
+	s.log.Debugf("agent request %s", req.Op)
+
+	switch req.Op {
+	case OpInfo:
+		info := s.vault.Info()
+		return Response{Version: ProtocolVersion, Info: &info}
+	case OpItems:
+		items, err := s.vault.GetItems(ctx)
+		if err != nil {
+			return errorResponse(err)
+		}
+		return Response{Version: ProtocolVersion, Items: items}
+	case OpFind:
+		item, err := s.vault.FindItem(ctx, req.Query)
+		if err != nil {
+			return errorResponse(err)
+		}
+		return Response{Version: ProtocolVersion, Item: &item}
+	case OpLock:
+		return Response{Version: ProtocolVersion}
+	default:
+		return Response{Version: ProtocolVersion, Error: &Error{Code: CodeBadRequest, Message: "unknown op " + req.Op}}
+	}
+}
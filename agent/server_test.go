@@ -0,0 +1,223 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+// master password of the sample vault in the repository root
+const testPassword = "mymasterpassword"
+
+// copyTestVault : a copy of the sample vault that a test may change
+func copyTestVault(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, name := range []string{"vault.enpassdb", "vault.json"} {
+		data, err := ioutil.ReadFile(filepath.Join("..", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+type testLogger struct {
+	t *testing.T
+}
+
+func (l testLogger) Debugf(format string, args ...interface{}) {
+	l.t.Logf(format, args...)
+}
+
+// startServer : serve a copy of the sample vault, returns the socket and the result of Serve
+func startServer(t *testing.T, idleTimeout time.Duration) (string, <-chan error) {
+	t.Helper()
+
+	vault, err := enpasscli.Open(context.Background(), copyTestVault(t), enpasscli.WithPassword([]byte(testPassword)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "agent.sock")
+	listener, err := Listen(path)
+	if err != nil {
+		vault.Close()
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served, stopped := make(chan error, 1), make(chan struct{})
+	go func() {
+		served <- NewServer(vault, idleTimeout, testLogger{t}).Serve(ctx, listener)
+		close(stopped)
+	}()
+	t.Cleanup(func() {
+		cancel()
+		<-stopped
+	})
+
+	return path, served
+}
+
+func TestListenRestrictsSocket(t *testing.T) {
+	path, _ := startServer(t, 0)
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode()&os.ModeSocket == 0 || info.Mode().Perm() != 0600 {
+		t.Fatalf("socket mode is %s", info.Mode())
+	}
+
+	if _, err := Listen(path); err == nil {
+		t.Fatal("listening on a taken path succeeded")
+	}
+}
+
+func TestClientLookups(t *testing.T) {
+	path, _ := startServer(t, 0)
+	ctx := context.Background()
+
+	client, err := Dial(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if name := client.Info().VaultName; name != "Primary" {
+		t.Fatalf("vault name is %q", name)
+	}
+
+	item, err := client.FindItem(ctx, "mylogin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if password, err := item.Field("password"); err != nil || password.Value != "mypassword" {
+		t.Fatalf("password is %q, %v", password.Value, err)
+	}
+
+	items, err := client.Search(ctx, enpasscli.Query{Text: "myusername"})
+	if err != nil || len(items) != 1 || items[0].UUID != item.UUID {
+		t.Fatalf("search found %d items, %v", len(items), err)
+	}
+
+	// errors keep their class across the socket
+	if _, err := client.FindItem(ctx, "no such item"); !errors.Is(err, enpasscli.ErrItemNotFound) {
+		t.Fatalf("got %v, expected %v", err, enpasscli.ErrItemNotFound)
+	}
+}
+
+func TestServerRejectsBadRequests(t *testing.T) {
+	path, _ := startServer(t, 0)
+
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	tests := []struct {
+		request string
+		code    string
+	}{
+		{`{"version":2,"op":"info"}`, CodeUnsupportedVersion},
+		{`{"version":1,"op":"unknown"}`, CodeBadRequest},
+		{`not json`, CodeBadRequest},
+	}
+
+	reader := bufio.NewReader(conn)
+	for _, test := range tests {
+		if _, err := conn.Write([]byte(test.request + "\n")); err != nil {
+			t.Fatal(err)
+		}
+
+		var resp Response
+		line, err := reader.ReadBytes('\n')
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := json.Unmarshal(line, &resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Version != ProtocolVersion || resp.Error == nil || resp.Error.Code != test.code {
+			t.Errorf("%s: got %s", test.request, line)
+		}
+	}
+}
+
+func TestLockRemovesSocket(t *testing.T) {
+	path, served := startServer(t, 0)
+	ctx := context.Background()
+
+	client, err := Dial(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	if err := client.Lock(ctx); err != nil {
+		t.Fatal(err)
+	}
+	waitServed(t, served)
+
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("socket still exists: %v", err)
+	}
+	if _, err := client.FindItem(ctx, "mylogin"); err == nil {
+		t.Fatal("locked agent answered")
+	}
+}
+
+func TestIdleTimeoutLocks(t *testing.T) {
+	path, served := startServer(t, 300*time.Millisecond)
+	ctx := context.Background()
+
+	client, err := Dial(ctx, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+
+	// requests keep the agent unlocked
+	for i := 0; i < 4; i++ {
+		time.Sleep(150 * time.Millisecond)
+		if _, err := client.FindItem(ctx, "mylogin"); err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+	}
+
+	waitServed(t, served)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("socket still exists: %v", err)
+	}
+}
+
+func waitServed(t *testing.T, served <-chan error) {
+	t.Helper()
+
+	select {
+	case err := <-served:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("agent did not stop")
+	}
+}
@@ -0,0 +1,68 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// the workflow of the usage text, with the enpass binary built from this tree
+func TestEvalWorkflow(t *testing.T) {
+	if testing.Short() {
+		t.Skip("builds the enpass binary")
+	}
+
+	bin := filepath.Join(t.TempDir(), "enpass")
+	build := exec.Command("go", "build", "-o", bin, ".")
+	build.Dir = ".."
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("could not build enpass: %v\n%s", err, out)
+	}
+
+	// eval returns only once the agent detached, a foreground agent would hold the pipe open
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	script := `
+set -e
+eval "$(enpass agent -idle-timeout 1m)"
+unset ENPASS_PASSWORD
+enpass get mylogin
+echo
+enpass agent -lock
+echo "$ENPASS_AGENT_SOCK"
+`
+	cmd := exec.CommandContext(ctx, "sh", "-c", script)
+	cmd.Env = append(os.Environ(),
+		"PATH="+filepath.Dir(bin)+string(os.PathListSeparator)+os.Getenv("PATH"),
+		"ENPASS_VAULT="+copyTestVault(t),
+		"ENPASS_PASSWORD="+testPassword,
+		"ENPASS_AGENT_SOCK=",
+	)
+	out, err := cmd.Output()
+	if ctx.Err() != nil {
+		t.Fatal("eval \"$(enpass agent)\" did not return")
+	}
+	if err != nil {
+		t.Fatalf("workflow failed: %v\n%s", err, out)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	if len(lines) != 2 || lines[0] != "mypassword" {
+		t.Fatalf("workflow printed %q", out)
+	}
+
+	// the locked agent removes its socket
+	socket := lines[1]
+	for i := 0; i < 50; i++ {
+		if _, err := os.Stat(socket); os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	t.Fatalf("socket %s still exists after the agent was locked", socket)
+}
@@ -0,0 +1,295 @@
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+// Check : one kind of finding
+type Check string
+
+const (
+	// password entropy below Options.MinEntropy
+	CheckWeak Check = "weak_password"
+	// the same password is used by more than one item
+	CheckReused Check = "reused_password"
+	// password not changed within Options.MaxAge
+	CheckOld Check = "old_password"
+	// login with a password but no one-time password
+	CheckMissingTOTP Check = "missing_totp"
+	// url field with a plain http:// address
+	CheckInsecureURL Check = "insecure_url"
+)
+
+// AllChecks : every check, in the order findings are reported
+var AllChecks = []Check{CheckWeak, CheckReused, CheckOld, CheckMissingTOTP, CheckInsecureURL}
+
+const (
+	// default Options.MinEntropy, in bits
+	DefaultMinEntropy = 50
+	// length of the per-run key the reuse check hashes passwords with
+	reuseKeyLength = 32
+)
+
+// Options : settings for Run
+type Options struct {
+	// checks to run, all when empty
+	Checks []Check
+	// passwords with a lower entropy estimate in bits are weak, the check is skipped when zero, so callers
+	// pass DefaultMinEntropy for the default
+	MinEntropy float64
+	// passwords whose field was modified longer ago are old, the check is skipped when zero
+	MaxAge time.Duration
+	// reference time for MaxAge, the current time when zero
+	Now time.Time
+	// HMAC key passwords are compared under, a random key when nil
+	ReuseKey []byte
+}
+
+// Finding : one problem with one item, it never contains a secret
+type Finding struct {
+	Check     Check  `json:"check"`
+	ItemUUID  string `json:"item_uuid"`
+	ItemTitle string `json:"item_title"`
+	Field     string `json:"field"`
+	Detail    string `json:"detail"`
+	// times the password was seen in breaches, for CheckBreached
+	Prevalence int `json:"prevalence"`
+}
+
+// Report : the findings of Run over a set of items
+type Report struct {
+	Items    int       `json:"items"`
+	Findings []Finding `json:"findings"`
+}
+
+// Count : the number of findings of a check
+func (r Report) Count(check Check) int {
+	count := 0
+	for _, finding := range r.Findings {
+		if finding.Check == check {
+			count++
+		}
+	}
+
+	return count
+}
+
+// passwordField : a non-empty password of an item
+type passwordField struct {
+	item  *enpasscli.Item
+	field enpasscli.Field
+}
+
+// Run : audit decrypted items
+func Run(items []enpasscli.Item, opts Options) (Report, error) {
+	checks := opts.Checks
+	if len(checks) == 0 {
+		checks = AllChecks
+	}
+
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	var passwords []passwordField
+	for i := range items {
+		for _, field := range items[i].Fields {
+			if field.Type == "password" && field.Value != "" {
+				passwords = append(passwords, passwordField{&items[i], field})
+			}
+		}
+	}
+
+	report := Report{Items: len(items), Findings: []Finding{}}
+	for _, check := range checks {
+		var findings []Finding
+		var err error
+
+		switch check {
+		case CheckWeak:
+			findings = checkWeak(passwords, opts.MinEntropy)
+		case CheckReused:
+			findings, err = checkReused(passwords, opts.ReuseKey)
+		case CheckOld:
+			findings = checkOld(passwords, opts.MaxAge, opts.Now)
+		case CheckMissingTOTP:
+			findings = checkMissingTOTP(items)
+		case CheckInsecureURL:
+			findings = checkInsecureURL(items)
+		default:
+			return Report{}, errors.Errorf("unknown audit check %q", check)
+		}
+
+		if err != nil {
+			return Report{}, err
+		}
+		report.Findings = append(report.Findings, findings...)
+	}
+
+	return report, nil
+}
+
+// ParseCheck : a check by name, the short names weak, reused, old, totp and http included
+func ParseCheck(name string) (Check, error) {
+	switch strings.ToLower(name) {
+	case "weak", string(CheckWeak):
+		return CheckWeak, nil
+	case "reused", string(CheckReused):
+		return CheckReused, nil
+	case "old", string(CheckOld):
+		return CheckOld, nil
+	case "totp", string(CheckMissingTOTP):
+		return CheckMissingTOTP, nil
+	case "http", string(CheckInsecureURL):
+		return CheckInsecureURL, nil
+	default:
+		return "", errors.Errorf("unknown audit check %q", name)
+	}
+}
+
+func checkWeak(passwords []passwordField, minEntropy float64) []Finding {
+	if minEntropy <= 0 {
+		return nil
+	}
+
+	var findings []Finding
+	for _, p := range passwords {
+		if entropy := Entropy(p.field.Value); entropy < minEntropy {
+			findings = append(findings, newFinding(CheckWeak, p.item, p.field,
+				fmt.Sprintf("estimated entropy %.0f bits, at least %.0f required", entropy, minEntropy)))
+		}
+	}
+
+	return findings
+}
+
+// checkReused : passwords are compared by HMAC so the grouping never holds them in a map key or prints them
+func checkReused(passwords []passwordField, key []byte) ([]Finding, error) {
+	if key == nil {
+		key = make([]byte, reuseKeyLength)
+		if _, err := rand.Read(key); err != nil {
+			return nil, errors.Wrap(err, "could not generate reuse key")
+		}
+	}
+
+	groups := make(map[string][]passwordField)
+	var order []string
+	for _, p := range passwords {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(p.field.Value))
+		sum := string(mac.Sum(nil))
+
+		if _, ok := groups[sum]; !ok {
+			order = append(order, sum)
+		}
+		groups[sum] = append(groups[sum], p)
+	}
+
+	var findings []Finding
+	group := 0
+	for _, sum := range order {
+		members := groups[sum]
+		if len(members) < 2 {
+			continue
+		}
+		group++
+
+		for _, p := range members {
+			var others []string
+			for _, other := range members {
+				if other.item != p.item {
+					others = append(others, other.item.UUID)
+				}
+			}
+			sort.Strings(others)
+
+			detail := fmt.Sprintf("reuse group %d, same password as another field of the item", group)
+			if len(others) > 0 {
+				detail = fmt.Sprintf("reuse group %d, same password as %s", group, strings.Join(others, ", "))
+			}
+			findings = append(findings, newFinding(CheckReused, p.item, p.field, detail))
+		}
+	}
+
+	return findings, nil
+}
+
+func checkOld(passwords []passwordField, maxAge time.Duration, now time.Time) []Finding {
+	if maxAge <= 0 {
+		return nil
+	}
+
+	var findings []Finding
+	for _, p := range passwords {
+		changed := p.field.UpdatedAt
+		if changed.IsZero() {
+			changed = p.item.UpdatedAt
+		}
+
+		if age := now.Sub(changed); age > maxAge {
+			findings = append(findings, newFinding(CheckOld, p.item, p.field,
+				fmt.Sprintf("last changed %s, %d days ago", changed.UTC().Format("2006-01-02"), int(age.Hours()/24))))
+		}
+	}
+
+	return findings
+}
+
+func checkMissingTOTP(items []enpasscli.Item) []Finding {
+	var findings []Finding
+	for i := range items {
+		item := &items[i]
+		if item.Category != "login" {
+			continue
+		}
+
+		password, err := item.Field("password")
+		if err != nil {
+			continue
+		}
+		if _, err := item.Field("totp"); err == nil {
+			continue
+		}
+
+		findings = append(findings, newFinding(CheckMissingTOTP, item, password, "login has no one-time password"))
+	}
+
+	return findings
+}
+
+func checkInsecureURL(items []enpasscli.Item) []Finding {
+	var findings []Finding
+	for i := range items {
+		for _, field := range items[i].Fields {
+			if field.Type != "url" || field.Sensitive {
+				continue
+			}
+
+			if strings.HasPrefix(strings.ToLower(strings.TrimSpace(field.Value)), "http://") {
+				findings = append(findings, newFinding(CheckInsecureURL, &items[i], field, field.Value))
+			}
+		}
+	}
+
+	return findings
+}
+
+func newFinding(check Check, item *enpasscli.Item, field enpasscli.Field, detail string) Finding {
+	return Finding{
+		Check:     check,
+		ItemUUID:  item.UUID,
+		ItemTitle: item.Title,
+		Field:     field.Name(),
+		Detail:    detail,
+	}
+}
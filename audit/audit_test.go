@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"testing"
+	"time"
+
+	"main/enpasscli"
+)
+
+func TestRunWeak(t *testing.T) {
+	items := []enpasscli.Item{
+		{UUID: "1", Title: "weak", Fields: []enpasscli.Field{{Label: "Password", Type: "password", Value: "password"}}},
+		{UUID: "2", Title: "strong", Fields: []enpasscli.Field{{Label: "Password", Type: "password", Value: "xK9#mQ2$vL7!pR4@"}}},
+	}
+
+	tests := []struct {
+		minEntropy float64
+		want       int
+	}{
+		{minEntropy: DefaultMinEntropy, want: 1},
+		{minEntropy: 200, want: 2},
+		// zero turns the check off
+		{minEntropy: 0, want: 0},
+	}
+
+	for _, tt := range tests {
+		report, err := Run(items, Options{Checks: []Check{CheckWeak}, MinEntropy: tt.minEntropy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := report.Count(CheckWeak); got != tt.want {
+			t.Errorf("MinEntropy %.0f: %d weak passwords, want %d", tt.minEntropy, got, tt.want)
+		}
+	}
+}
+
+func TestRunOld(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	recent, old := now.AddDate(0, -1, 0), now.AddDate(-2, 0, 0)
+	password := func(updatedAt time.Time) []enpasscli.Field {
+		return []enpasscli.Field{{Label: "Password", Type: "password", Value: "pw", UpdatedAt: updatedAt}}
+	}
+
+	items := []enpasscli.Item{
+		{UUID: "recent field", UpdatedAt: old, Fields: password(recent)},
+		{UUID: "old field", UpdatedAt: recent, Fields: password(old)},
+		// a field without a change time is as old as its item
+		{UUID: "recent item", UpdatedAt: recent, Fields: password(time.Time{})},
+		{UUID: "old item", UpdatedAt: old, Fields: password(time.Time{})},
+	}
+
+	report, err := Run(items, Options{Checks: []Check{CheckOld}, MaxAge: 365 * 24 * time.Hour, Now: now})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var found []string
+	for _, finding := range report.Findings {
+		found = append(found, finding.ItemUUID)
+	}
+	if len(found) != 2 || found[0] != "old field" || found[1] != "old item" {
+		t.Errorf("old passwords %q, want old field and old item", found)
+	}
+
+	if report, _ := Run(items, Options{Checks: []Check{CheckOld}, Now: now}); len(report.Findings) != 0 {
+		t.Errorf("MaxAge 0: %d findings, want none", len(report.Findings))
+	}
+}
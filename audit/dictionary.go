@@ -0,0 +1,51 @@
+package audit
+
+import (
+	"sync"
+
+	"main/generator"
+)
+
+// commonPasswords : the most common passwords and password stems of public breach lists, most common first
+var commonPasswords = []string{
+	"password", "123456", "123456789", "12345678", "12345", "qwerty", "1234567", "111111", "1234567890",
+	"123123", "abc123", "1234", "password1", "iloveyou", "1q2w3e4r", "000000", "qwerty123", "zaq12wsx",
+	"dragon", "sunshine", "princess", "letmein", "654321", "monkey", "1qaz2wsx", "123321", "qwertyuiop",
+	"superman", "asdfghjkl", "football", "baseball", "welcome", "admin", "master", "shadow", "michael",
+	"jennifer", "hunter", "jordan", "harley", "ranger", "buster", "thomas", "tigger", "robert", "soccer",
+	"batman", "test", "pass", "killer", "hockey", "george", "charlie", "andrew", "michelle", "love",
+	"jessica", "pepper", "daniel", "access", "joshua", "maggie", "starwars", "silver", "william", "dallas",
+	"yankees", "hello", "amanda", "orange", "biteme", "freedom", "computer", "thunder", "nicole", "ginger",
+	"heather", "hammer", "summer", "corvette", "taylor", "austin", "merlin", "matthew", "chelsea",
+	"diamond", "secret", "trustno1", "whatever", "cheese", "chocolate", "flower", "lovely", "passw0rd",
+	"login", "changeme", "default", "root", "guest", "qazwsx", "zxcvbnm", "asdf", "mustang", "zxcvbn",
+	"welcome1", "admin123", "p@ssw0rd", "iloveu", "angel", "babygirl", "butterfly", "purple", "jasmine",
+	"liverpool", "arsenal", "samsung", "google", "internet", "pokemon", "naruto", "blink182", "qwe123",
+	"asd123", "zxc123", "aaaaaa", "121212", "7777777", "987654321", "666666", "888888", "abcdef",
+	"abcd1234", "secret1", "letmein1", "monkey1", "dragon1", "sunshine1", "princess1", "football1",
+}
+
+var (
+	dictionaryOnce sync.Once
+	// rank of each common password, 1 for the most common
+	commonRanks map[string]int
+	// the generator's passphrase words, equally likely
+	passphraseWords map[string]bool
+)
+
+func loadDictionaries() {
+	dictionaryOnce.Do(func() {
+		commonRanks = make(map[string]int, len(commonPasswords))
+		for i, password := range commonPasswords {
+			if _, ok := commonRanks[password]; !ok {
+				commonRanks[password] = i + 1
+			}
+		}
+
+		words := generator.Words()
+		passphraseWords = make(map[string]bool, len(words))
+		for _, word := range words {
+			passphraseWords[word] = true
+		}
+	})
+}
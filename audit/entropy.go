@@ -0,0 +1,297 @@
+package audit
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// character pool sizes of the classes Entropy distinguishes
+const (
+	lowerPool  = 26
+	upperPool  = 26
+	digitPool  = 10
+	symbolPool = 33
+	// any letter outside ASCII, a conservative guess
+	otherPool = 100
+)
+
+const (
+	// patterns are looked for in this many leading characters, the rest of a longer password counts as random
+	maxPatternLength = 64
+	// shortest word, sequence or keyboard run that counts as a pattern
+	minPatternLength = 3
+	// years from 1900 to 2099
+	yearCount = 200
+)
+
+// keyboardRows : rows of a US keyboard, unshifted and shifted, runs along them are patterns
+var keyboardRows = []string{"`1234567890-=", "~!@#$%^&*()_+", "qwertyuiop[]\\", "asdfghjkl;'", "zxcvbnm,./"}
+
+// leetSubstitutions : look-alike characters undone before dictionary lookups
+var leetSubstitutions = map[rune]rune{
+	'4': 'a', '@': 'a', '8': 'b', '(': 'c', '3': 'e', '6': 'g', '1': 'i', '!': 'i',
+	'|': 'l', '0': 'o', '$': 's', '5': 's', '7': 't', '+': 't', '2': 'z',
+}
+
+// Entropy : estimated bits of a password, the cheapest way to build it from common passwords, dictionary
+// words, sequences like "abc" or "987", keyboard runs, repeats and years, every other character drawn at
+// random from the classes the password uses. Without any pattern that is length × log2(pool), the upper
+// bound for a random password.
+func Entropy(password string) float64 {
+	runes := []rune(password)
+	pool := charPool(runes)
+	if pool == 0 {
+		return 0
+	}
+	charBits := math.Log2(float64(pool))
+
+	if len(runes) > maxPatternLength {
+		return estimate(runes[:maxPatternLength], charBits) + float64(len(runes)-maxPatternLength)*charBits
+	}
+
+	return estimate(runes, charBits)
+}
+
+// charPool : the size of the character classes the password uses
+func charPool(runes []rune) int {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range runes {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	pool := 0
+	for _, class := range []struct {
+		used bool
+		size int
+	}{{lower, lowerPool}, {upper, upperPool}, {digit, digitPool}, {symbol, symbolPool}, {other, otherPool}} {
+		if class.used {
+			pool += class.size
+		}
+	}
+
+	return pool
+}
+
+// estimate : the fewest bits over all ways to split runes into patterns and random characters
+func estimate(runes []rune, charBits float64) float64 {
+	best := make([]float64, len(runes)+1)
+	for end := 1; end <= len(runes); end++ {
+		best[end] = best[end-1] + charBits
+		for start := 0; start < end-1; start++ {
+			if bits, ok := patternBits(runes[start:end], charBits); ok && best[start]+bits < best[end] {
+				best[end] = best[start] + bits
+			}
+		}
+	}
+
+	return best[len(runes)]
+}
+
+// patternBits : the bits of the cheapest pattern segment matches, false if it matches none
+func patternBits(segment []rune, charBits float64) (float64, bool) {
+	bits, found := math.Inf(1), false
+	for _, match := range []func([]rune) (float64, bool){dictionaryBits, sequenceBits, keyboardBits, yearBits} {
+		if b, ok := match(segment); ok && b < bits {
+			bits, found = b, true
+		}
+	}
+
+	if b, ok := repeatBits(segment, charBits); ok && b < bits {
+		bits, found = b, true
+	}
+
+	return bits, found
+}
+
+// dictionaryBits : a common password or passphrase word, possibly capitalised or with look-alike characters
+func dictionaryBits(segment []rune) (float64, bool) {
+	if len(segment) < minPatternLength {
+		return 0, false
+	}
+
+	word := strings.ToLower(string(segment))
+	bits, found := wordBits(word)
+
+	if unleeted, substitutions := unleet(word); substitutions > 0 {
+		if b, ok := wordBits(unleeted); ok && (!found || b+float64(substitutions) < bits) {
+			bits, found = b+float64(substitutions), true
+		}
+	}
+
+	if !found {
+		return 0, false
+	}
+
+	return bits + caseBits(segment), true
+}
+
+// wordBits : log2 of the rank of a common password, or of the wordlist size for a passphrase word
+func wordBits(word string) (float64, bool) {
+	loadDictionaries()
+
+	if rank, ok := commonRanks[word]; ok {
+		return math.Log2(float64(rank + 1)), true
+	}
+	if passphraseWords[word] {
+		return math.Log2(float64(len(passphraseWords))), true
+	}
+
+	return 0, false
+}
+
+// unleet : word with look-alike characters replaced by letters, and how many were replaced
+func unleet(word string) (string, int) {
+	substitutions := 0
+	unleeted := strings.Map(func(r rune) rune {
+		if letter, ok := leetSubstitutions[r]; ok {
+			substitutions++
+			return letter
+		}
+		return r
+	}, word)
+
+	return unleeted, substitutions
+}
+
+// caseBits : bits for where the upper case letters of a word are, one for the usual capitalisations
+func caseBits(segment []rune) float64 {
+	upper, lower := 0, 0
+	for _, r := range segment {
+		switch {
+		case unicode.IsUpper(r):
+			upper++
+		case unicode.IsLower(r):
+			lower++
+		}
+	}
+
+	switch {
+	case upper == 0:
+		return 0
+	case lower == 0, upper == 1 && unicode.IsUpper(segment[0]):
+		return 1
+	}
+
+	// every way to pick up to min(upper, lower) of the letters
+	letters := upper + lower
+	guesses, choose := 0.0, 1.0
+	for i := 1; i <= upper && i <= lower; i++ {
+		choose = choose * float64(letters-i+1) / float64(i)
+		guesses += choose
+	}
+
+	return math.Log2(guesses)
+}
+
+// sequenceBits : letters or digits counting up or down by one, like "abcd" or "987"
+func sequenceBits(segment []rune) (float64, bool) {
+	if len(segment) < minPatternLength {
+		return 0, false
+	}
+
+	delta := segment[1] - segment[0]
+	if delta != 1 && delta != -1 {
+		return 0, false
+	}
+	for i := 2; i < len(segment); i++ {
+		if segment[i]-segment[i-1] != delta {
+			return 0, false
+		}
+	}
+
+	first, last := segment[0], segment[len(segment)-1]
+	var starts float64
+	switch {
+	case first >= 'a' && first <= 'z' && last >= 'a' && last <= 'z',
+		first >= 'A' && first <= 'Z' && last >= 'A' && last <= 'Z':
+		starts = 26
+	case first >= '0' && first <= '9' && last >= '0' && last <= '9':
+		starts = 10
+	default:
+		return 0, false
+	}
+
+	// the first guesses start at either end
+	if strings.ContainsRune("aAzZ019", first) {
+		starts = 4
+	}
+	if delta < 0 {
+		starts *= 2
+	}
+
+	return math.Log2(starts * float64(len(segment))), true
+}
+
+// keyboardBits : a run of neighbouring keys of one keyboard row, like "qwerty" or "lkjh"
+func keyboardBits(segment []rune) (float64, bool) {
+	if len(segment) < minPatternLength {
+		return 0, false
+	}
+
+	run := strings.ToLower(string(segment))
+	reversed := []rune(run)
+	for i, j := 0, len(reversed)-1; i < j; i, j = i+1, j-1 {
+		reversed[i], reversed[j] = reversed[j], reversed[i]
+	}
+
+	keys := 0
+	for _, row := range keyboardRows {
+		keys += len(row)
+	}
+
+	for _, row := range keyboardRows {
+		switch {
+		case strings.Contains(row, run):
+			return math.Log2(float64(keys*len(segment))) + caseBits(segment), true
+		case strings.Contains(row, string(reversed)):
+			return math.Log2(float64(2*keys*len(segment))) + caseBits(segment), true
+		}
+	}
+
+	return 0, false
+}
+
+// yearBits : a year from 1900 to 2099
+func yearBits(segment []rune) (float64, bool) {
+	if len(segment) != 4 || (string(segment[:2]) != "19" && string(segment[:2]) != "20") {
+		return 0, false
+	}
+	for _, r := range segment[2:] {
+		if r < '0' || r > '9' {
+			return 0, false
+		}
+	}
+
+	return math.Log2(yearCount), true
+}
+
+// repeatBits : a shorter part repeated, like "aaaa" or "abcabc", costs the part and the repeat count
+func repeatBits(segment []rune, charBits float64) (float64, bool) {
+	for size := 1; size <= len(segment)/2; size++ {
+		if len(segment)%size != 0 {
+			continue
+		}
+
+		repeated := true
+		for i := size; i < len(segment) && repeated; i++ {
+			repeated = segment[i] == segment[i-size]
+		}
+		if repeated {
+			return estimate(segment[:size], charBits) + math.Log2(float64(len(segment)/size)), true
+		}
+	}
+
+	return 0, false
+}
@@ -0,0 +1,68 @@
+package audit
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestEntropyPatterns(t *testing.T) {
+	// each password is below DefaultMinEntropy for the pattern it is built from
+	for _, password := range []string{
+		"Password1!",
+		"P@ssw0rd",
+		"password",
+		"PASSWORD2024",
+		"Summer2023!",
+		"iloveyou123",
+		"aaaaaaaaaaaa",
+		"abcabcabcabc",
+		"abcdefgh123",
+		"zyxwvuts",
+		"qwertyuiop",
+		"1qaz2wsx",
+		"!@#$%^&*",
+		"Dragon1990",
+		"correcthorse",
+		"W3lc0me123",
+	} {
+		if bits := Entropy(password); bits >= DefaultMinEntropy {
+			t.Errorf("Entropy(%q) = %.1f bits, want below %d", password, bits, DefaultMinEntropy)
+		}
+	}
+}
+
+func TestEntropyRandom(t *testing.T) {
+	tests := []struct {
+		password string
+		min      float64
+	}{
+		// random characters keep the upper bound, length × log2(pool)
+		{password: "xK9#mQ2$vL7!pR4@", min: 16 * math.Log2(95) * 0.9},
+		{password: "7hG2kP9wX4nB", min: 12 * math.Log2(62) * 0.9},
+		// four passphrase words and separators
+		{password: "correct-horse-battery-staple", min: 4 * math.Log2(7776)},
+		{password: strings.Repeat("x", 200), min: 0},
+	}
+
+	for _, tt := range tests {
+		if bits := Entropy(tt.password); bits < tt.min {
+			t.Errorf("Entropy(%q) = %.1f bits, want at least %.1f", tt.password, bits, tt.min)
+		}
+	}
+}
+
+func TestEntropyUpperBound(t *testing.T) {
+	// patterns only ever lower the estimate of a random password of the same classes
+	for _, password := range []string{"", "a", "Password1!", "xK9#mQ2$vL7!pR4@", "héllo wörld", strings.Repeat("ab", 50)} {
+		runes := []rune(password)
+		bound := 0.0
+		if pool := charPool(runes); pool > 0 {
+			bound = float64(len(runes)) * math.Log2(float64(pool))
+		}
+
+		if bits := Entropy(password); bits > bound+1e-9 || bits < 0 {
+			t.Errorf("Entropy(%q) = %.1f bits, want between 0 and %.1f", password, bits, bound)
+		}
+	}
+}
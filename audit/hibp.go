@@ -0,0 +1,265 @@
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+// CheckBreached : the password appears in a Have I Been Pwned list
+const CheckBreached Check = "breached_password"
+
+const (
+	// first bytes of a binary index written by WritePwnedIndex
+	pwnedIndexMagic = "HIBPIDX1"
+	// a binary index record: SHA-1 followed by a big-endian uint32 count
+	pwnedRecordSize = sha1.Size + 4
+	// longest line of the text format, "<40 hex>:<count>\r\n" with room to spare
+	maxPwnedLineLength = 128
+)
+
+// PwnedDB : a local Have I Been Pwned SHA-1 password list, searched on disk without loading it.
+// It is either the "SHA1:count" text download ordered by hash or an index from WritePwnedIndex.
+type PwnedDB struct {
+	f     *os.File
+	size  int64
+	index bool
+}
+
+// OpenPwnedDB : open a sorted text list or a binary index, recognised by its header
+func OpenPwnedDB(path string) (*PwnedDB, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open breached password list")
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, errors.Wrap(err, "could not read breached password list")
+	}
+
+	db := &PwnedDB{f: f, size: stat.Size()}
+
+	magic := make([]byte, len(pwnedIndexMagic))
+	if _, err := f.ReadAt(magic, 0); err == nil && string(magic) == pwnedIndexMagic {
+		db.index = true
+		if (db.size-int64(len(magic)))%pwnedRecordSize != 0 {
+			f.Close()
+			return nil, errors.New("breached password index is truncated")
+		}
+	}
+
+	return db, nil
+}
+
+func (db *PwnedDB) Close() error {
+	return db.f.Close()
+}
+
+// Lookup : how often the password with this SHA-1 was seen in breaches, 0 if never
+func (db *PwnedDB) Lookup(sum [sha1.Size]byte) (int, error) {
+	if db.index {
+		return db.lookupIndex(sum)
+	}
+
+	return db.lookupText(sum)
+}
+
+func (db *PwnedDB) lookupIndex(sum [sha1.Size]byte) (int, error) {
+	record := make([]byte, pwnedRecordSize)
+	offset := int64(len(pwnedIndexMagic))
+
+	lo, hi := int64(0), (db.size-offset)/pwnedRecordSize
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		if _, err := db.f.ReadAt(record, offset+mid*pwnedRecordSize); err != nil {
+			return 0, errors.Wrap(err, "could not read breached password index")
+		}
+
+		switch bytes.Compare(record[:sha1.Size], sum[:]) {
+		case 0:
+			return int(binary.BigEndian.Uint32(record[sha1.Size:])), nil
+		case -1:
+			lo = mid + 1
+		default:
+			hi = mid
+		}
+	}
+
+	return 0, nil
+}
+
+// lookupText : binary search over byte offsets, lo always being the start of a line
+// that may hold the hash and hi the end of the range still to search
+func (db *PwnedDB) lookupText(sum [sha1.Size]byte) (int, error) {
+	lo, hi := int64(0), db.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+
+		start, err := db.lineStart(mid)
+		if err != nil {
+			return 0, err
+		}
+		if start >= hi {
+			hi = mid
+			continue
+		}
+
+		line, next, err := db.readLine(start)
+		if err != nil {
+			return 0, err
+		}
+
+		lineSum, count, err := parsePwnedLine(line)
+		if err != nil {
+			return 0, errors.Wrapf(err, "breached password list at offset %d", start)
+		}
+
+		switch bytes.Compare(lineSum[:], sum[:]) {
+		case 0:
+			return count, nil
+		case -1:
+			lo = next
+		default:
+			hi = mid
+		}
+	}
+
+	return 0, nil
+}
+
+// lineStart : offset of the first line starting at or after offset
+func (db *PwnedDB) lineStart(offset int64) (int64, error) {
+	if offset == 0 {
+		return 0, nil
+	}
+
+	r := bufio.NewReaderSize(io.NewSectionReader(db.f, offset-1, db.size-offset+1), maxPwnedLineLength)
+	skipped, err := r.ReadSlice('\n')
+	if err == io.EOF {
+		return db.size, nil
+	}
+	if err != nil {
+		return 0, errors.Wrap(err, "could not read breached password list")
+	}
+
+	return offset - 1 + int64(len(skipped)), nil
+}
+
+// readLine : the line at offset without its line ending, and the offset of the next line
+func (db *PwnedDB) readLine(offset int64) ([]byte, int64, error) {
+	r := bufio.NewReaderSize(io.NewSectionReader(db.f, offset, db.size-offset), maxPwnedLineLength)
+	line, err := r.ReadSlice('\n')
+	if err != nil && err != io.EOF {
+		return nil, 0, errors.Wrap(err, "could not read breached password list")
+	}
+
+	return bytes.TrimRight(line, "\r\n"), offset + int64(len(line)), nil
+}
+
+func parsePwnedLine(line []byte) ([sha1.Size]byte, int, error) {
+	var sum [sha1.Size]byte
+
+	separator := bytes.IndexByte(line, ':')
+	if separator != sha1.Size*2 {
+		return sum, 0, errors.Errorf("malformed line %q, expected SHA1:count", line)
+	}
+
+	if _, err := hex.Decode(sum[:], line[:separator]); err != nil {
+		return sum, 0, errors.Errorf("malformed hash in line %q", line)
+	}
+
+	count, err := strconv.Atoi(string(bytes.TrimSpace(line[separator+1:])))
+	if err != nil {
+		return sum, 0, errors.Errorf("malformed count in line %q", line)
+	}
+
+	return sum, count, nil
+}
+
+// WritePwnedIndex : convert the sorted "SHA1:count" text list into the fixed-size records OpenPwnedDB searches faster
+func WritePwnedIndex(w io.Writer, r io.Reader) error {
+	bw := bufio.NewWriter(w)
+	if _, err := bw.WriteString(pwnedIndexMagic); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(r)
+	record := make([]byte, pwnedRecordSize)
+	var previous []byte
+
+	for line := 1; scanner.Scan(); line++ {
+		text := bytes.TrimSpace(scanner.Bytes())
+		if len(text) == 0 {
+			continue
+		}
+
+		sum, count, err := parsePwnedLine(text)
+		if err != nil {
+			return errors.Wrapf(err, "line %d", line)
+		}
+		if previous != nil && bytes.Compare(sum[:], previous) <= 0 {
+			return errors.Errorf("line %d: the list is not ordered by hash", line)
+		}
+		if count < 0 || uint64(count) > 1<<32-1 {
+			return errors.Errorf("line %d: count %d out of range", line, count)
+		}
+
+		copy(record, sum[:])
+		binary.BigEndian.PutUint32(record[sha1.Size:], uint32(count))
+		if _, err := bw.Write(record); err != nil {
+			return err
+		}
+
+		previous = append(previous[:0], sum[:]...)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return errors.Wrap(err, "could not read breached password list")
+	}
+
+	return bw.Flush()
+}
+
+// RunBreached : a finding for every password in db, Finding.Prevalence holding how often it was seen
+func RunBreached(items []enpasscli.Item, db *PwnedDB) (Report, error) {
+	report := Report{Items: len(items), Findings: []Finding{}}
+	counts := make(map[[sha1.Size]byte]int)
+
+	for i := range items {
+		for _, field := range items[i].Fields {
+			if field.Type != "password" || field.Value == "" {
+				continue
+			}
+
+			sum := sha1.Sum([]byte(field.Value))
+			count, ok := counts[sum]
+			if !ok {
+				var err error
+				if count, err = db.Lookup(sum); err != nil {
+					return Report{}, err
+				}
+				counts[sum] = count
+			}
+
+			if count > 0 {
+				finding := newFinding(CheckBreached, &items[i], field, fmt.Sprintf("seen %d times in breaches", count))
+				finding.Prevalence = count
+				report.Findings = append(report.Findings, finding)
+			}
+		}
+	}
+
+	return report, nil
+}
@@ -0,0 +1,244 @@
+package audit
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+// sorted "SHA1:count" lines with CRLF endings like the download, the first and last hashes sit at the
+// ends of the hash space
+const pwnedFixture = "testdata/pwned.txt"
+
+func pwnedSum(t *testing.T, h string) [sha1.Size]byte {
+	t.Helper()
+
+	var sum [sha1.Size]byte
+	if _, err := hex.Decode(sum[:], []byte(h)); err != nil {
+		t.Fatal(err)
+	}
+
+	return sum
+}
+
+// pwnedLists : the fixture as the text formats OpenPwnedDB reads and as an index, by name
+func pwnedLists(t *testing.T) map[string]string {
+	t.Helper()
+
+	text, err := ioutil.ReadFile(pwnedFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	lf := bytes.Replace(text, []byte("\r\n"), []byte("\n"), -1)
+	lists := map[string][]byte{
+		"crlf":            text,
+		"lf":              lf,
+		"no final ending": bytes.TrimRight(lf, "\n"),
+	}
+
+	var index bytes.Buffer
+	if err := WritePwnedIndex(&index, bytes.NewReader(text)); err != nil {
+		t.Fatal(err)
+	}
+	lists["index"] = index.Bytes()
+
+	paths := make(map[string]string)
+	for name, contents := range lists {
+		path := filepath.Join(dir, strings.Replace(name, " ", "-", -1))
+		if err := ioutil.WriteFile(path, contents, 0600); err != nil {
+			t.Fatal(err)
+		}
+		paths[name] = path
+	}
+
+	return paths
+}
+
+func TestPwnedLookup(t *testing.T) {
+	tests := []struct {
+		name string
+		hash string
+		want int
+	}{
+		{name: "first line", hash: "000000005AD76BD555C1D6D771DE417A4B87E4B4", want: 4},
+		{name: "second line", hash: "32CA9FC1A0F5B6330E3F4C8C1BBECDE9BEDB9573", want: 12345},
+		{name: "middle line", hash: "91DFD9DDB4198AFFC5C194CD8CE6D338FDE470E2", want: 103391},
+		{name: "second to last line", hash: "B7A875FC1EA228B9061041B7CEC4BD3C52AB3CE3", want: 1003210},
+		{name: "last line", hash: "FFFFFFFEE791CBAC0F6305CAF0CEE06BBE131160", want: 2},
+		{name: "before the first line", hash: "0000000000000000000000000000000000000000"},
+		{name: "first line with another last byte", hash: "000000005AD76BD555C1D6D771DE417A4B87E4B5"},
+		{name: "between two lines", hash: "91DFD9DDB4198AFFC5C194CD8CE6D338FDE470E1"},
+		{name: "after a line with the same prefix", hash: "91DFD9DDB4198AFFC5C194CD8CE6D338FDE470E3"},
+		{name: "last line with another last byte", hash: "FFFFFFFEE791CBAC0F6305CAF0CEE06BBE131161"},
+		{name: "after the last line", hash: "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF"},
+	}
+
+	for format, path := range pwnedLists(t) {
+		db, err := OpenPwnedDB(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		if db.index != (format == "index") {
+			t.Errorf("%s: index is %v", format, db.index)
+		}
+
+		for _, tt := range tests {
+			got, err := db.Lookup(pwnedSum(t, tt.hash))
+			if err != nil {
+				t.Errorf("%s, %s: %v", format, tt.name, err)
+			} else if got != tt.want {
+				t.Errorf("%s, %s: Lookup = %d, want %d", format, tt.name, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestPwnedLookupSmallLists(t *testing.T) {
+	const line = "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\r\n"
+	dir := t.TempDir()
+
+	for name, contents := range map[string]string{"empty": "", "one line": line} {
+		path := filepath.Join(dir, strings.Replace(name, " ", "-", -1))
+		if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+			t.Fatal(err)
+		}
+
+		db, err := OpenPwnedDB(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer db.Close()
+
+		want := 0
+		if contents != "" {
+			want = 9545824
+		}
+		for hash, count := range map[string]int{
+			"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8": want,
+			"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD7": 0,
+			"5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD9": 0,
+		} {
+			if got, err := db.Lookup(pwnedSum(t, hash)); err != nil || got != count {
+				t.Errorf("%s: Lookup(%s) = %d, %v, want %d", name, hash, got, err, count)
+			}
+		}
+	}
+}
+
+func TestWritePwnedIndex(t *testing.T) {
+	text, err := ioutil.ReadFile(pwnedFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(text)), "\r\n")
+
+	var index bytes.Buffer
+	if err := WritePwnedIndex(&index, bytes.NewReader(text)); err != nil {
+		t.Fatal(err)
+	}
+	if want := len(pwnedIndexMagic) + len(lines)*pwnedRecordSize; index.Len() != want {
+		t.Fatalf("index is %d bytes, want %d", index.Len(), want)
+	}
+	if !bytes.HasPrefix(index.Bytes(), []byte(pwnedIndexMagic)) {
+		t.Fatal("index does not start with its magic")
+	}
+
+	// every record is the hash and count of its line, in the order of the list
+	for i, line := range lines {
+		sum, count, err := parsePwnedLine([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+		record := index.Bytes()[len(pwnedIndexMagic)+i*pwnedRecordSize:][:pwnedRecordSize]
+		if !bytes.Equal(record[:sha1.Size], sum[:]) {
+			t.Errorf("record %d has hash %X, want %X", i, record[:sha1.Size], sum)
+		}
+		if got := int(binary.BigEndian.Uint32(record[sha1.Size:])); got != count {
+			t.Errorf("record %d has count %d, want %d", i, got, count)
+		}
+	}
+
+	first, last := lines[0]+"\n", lines[len(lines)-1]+"\n"
+	tests := []struct {
+		name  string
+		list  string
+		fails bool
+	}{
+		{name: "blank lines", list: "\n" + first + "\n\n" + last},
+		{name: "lower case hashes", list: strings.ToLower(first + last)},
+		{name: "out of order", list: last + first, fails: true},
+		{name: "duplicate", list: first + first, fails: true},
+		{name: "no count", list: strings.Split(first, ":")[0] + "\n", fails: true},
+		{name: "short hash", list: first[1:], fails: true},
+		{name: "negative count", list: strings.Split(first, ":")[0] + ":-1\n", fails: true},
+		{name: "count over 32 bits", list: strings.Split(first, ":")[0] + ":4294967296\n", fails: true},
+	}
+
+	for _, tt := range tests {
+		err := WritePwnedIndex(ioutil.Discard, strings.NewReader(tt.list))
+		if tt.fails && err == nil {
+			t.Errorf("%s: no error", tt.name)
+		} else if !tt.fails && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+	}
+}
+
+func TestOpenPwnedDBTruncatedIndex(t *testing.T) {
+	paths := pwnedLists(t)
+	contents, err := ioutil.ReadFile(paths["index"])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "truncated")
+	if err := ioutil.WriteFile(path, contents[:len(contents)-1], 0600); err != nil {
+		t.Fatal(err)
+	}
+	if db, err := OpenPwnedDB(path); err == nil {
+		db.Close()
+		t.Error("truncated index opened")
+	}
+	if _, err := OpenPwnedDB(filepath.Join(t.TempDir(), "missing")); !os.IsNotExist(errors.Cause(err)) {
+		t.Errorf("missing list: error %v", err)
+	}
+}
+
+func TestRunBreached(t *testing.T) {
+	db, err := OpenPwnedDB(pwnedFixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	items := []enpasscli.Item{
+		{UUID: "1", Title: "breached", Fields: []enpasscli.Field{{Label: "Password", Type: "password", Value: "mypassword"}}},
+		{UUID: "2", Title: "not breached", Fields: []enpasscli.Field{{Label: "Password", Type: "password", Value: "correct horse battery staple"}}},
+		{UUID: "3", Title: "not a password", Fields: []enpasscli.Field{{Label: "Username", Type: "username", Value: "password"}}},
+	}
+
+	report, err := RunBreached(items, db)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Findings) != 1 || report.Findings[0].ItemUUID != "1" || report.Findings[0].Prevalence != 103391 {
+		t.Fatalf("findings %+v, want one for item 1 seen 103391 times", report.Findings)
+	}
+	if strings.Contains(report.Findings[0].Detail, "mypassword") {
+		t.Errorf("finding %q contains the password", report.Findings[0].Detail)
+	}
+}
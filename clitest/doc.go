@@ -0,0 +1,3 @@
+// Package clitest : tests of the enpass commands. Package main of the module main cannot be imported by
+// a test binary, so these tests build the enpass binary from this tree and run it.
+package clitest
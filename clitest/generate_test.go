@@ -0,0 +1,27 @@
+package clitest
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestGenerateUsageErrors(t *testing.T) {
+	tests := [][]string{
+		{"generate", "-length", "0"},
+		{"generate", "-classes", "lower,emoji"},
+		{"generate", "-length", "4", "-min-digits", "5"},
+		{"generate", "-passphrase", "-words", "0"},
+		{"generate", "-passphrase", "-words", "-3"},
+	}
+
+	for _, args := range tests {
+		if stdout, stderr, code := run(t, args...); code != 2 || stdout != "" {
+			t.Errorf("%s: exit code %d, printed %q, want exit code 2 and nothing on stdout\n%s",
+				strings.Join(args, " "), code, stdout, stderr)
+		}
+	}
+
+	if stdout := enpass(t, "generate", "-passphrase", "-words", "3", "-separator", "_"); strings.Count(stdout, "_") != 2 {
+		t.Errorf("generate -passphrase -words 3 printed %q", stdout)
+	}
+}
@@ -0,0 +1,216 @@
+package clitest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// master password of the sample vault at the repository root
+const testPassword = "mymasterpassword"
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var (
+	// the enpass binary built from this tree
+	enpassBin string
+	// a copy of the sample vault
+	vaultDir string
+)
+
+func TestMain(m *testing.M) {
+	flag.Parse()
+	if testing.Short() {
+		fmt.Println("skipping, the tests build the enpass binary")
+		os.Exit(0)
+	}
+
+	dir, err := ioutil.TempDir("", "enpass-clitest")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	code := 1
+	if err := setup(dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	} else {
+		code = m.Run()
+	}
+
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func setup(dir string) error {
+	enpassBin = filepath.Join(dir, "enpass")
+	build := exec.Command("go", "build", "-o", enpassBin, ".")
+	build.Dir = ".."
+	if out, err := build.CombinedOutput(); err != nil {
+		return fmt.Errorf("could not build enpass: %v\n%s", err, out)
+	}
+
+	vaultDir = filepath.Join(dir, "vault")
+	if err := os.Mkdir(vaultDir, 0700); err != nil {
+		return err
+	}
+	for _, name := range []string{"vault.enpassdb", "vault.json"} {
+		data, err := ioutil.ReadFile(filepath.Join("..", name))
+		if err != nil {
+			return err
+		}
+		if err := ioutil.WriteFile(filepath.Join(vaultDir, name), data, 0600); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// run : run the binary on the sample vault, read-only, and return its stdout, stderr and exit code
+func run(t *testing.T, args ...string) (string, string, int) {
+	t.Helper()
+
+	cmd := exec.Command(enpassBin, append([]string{"-read-only", "-log-level", "error"}, args...)...)
+	cmd.Env = append(os.Environ(),
+		"ENPASS_VAULT="+vaultDir,
+		"ENPASS_PASSWORD="+testPassword,
+		"ENPASS_AGENT_SOCK=",
+	)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return stdout.String(), stderr.String(), exitErr.ExitCode()
+	}
+	if err != nil {
+		t.Fatalf("enpass %s: %v", strings.Join(args, " "), err)
+	}
+
+	return stdout.String(), stderr.String(), 0
+}
+
+// enpass : run the binary like run and return its stdout, the command has to succeed
+func enpass(t *testing.T, args ...string) string {
+	t.Helper()
+
+	stdout, stderr, code := run(t, args...)
+	if code != 0 {
+		t.Fatalf("enpass %s: exit code %d\n%s", strings.Join(args, " "), code, stderr)
+	}
+
+	return stdout
+}
+
+// checkGolden : compare got with testdata/name.golden, or rewrite the file with -update
+func checkGolden(t *testing.T, name string, got string) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := ioutil.WriteFile(path, []byte(got), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s:\n got:\n%s\nwant:\n%s", path, got, want)
+	}
+}
+
+// withFormat : args with -format after the command name, flags go before the other arguments
+func withFormat(args []string, format string) []string {
+	return append([]string{args[0], "-format", format}, args[1:]...)
+}
+
+func TestOutputFormats(t *testing.T) {
+	commands := []struct {
+		name string
+		args []string
+	}{
+		{name: "list", args: []string{"list"}},
+		{name: "list-empty", args: []string{"list", "-q", "no such item"}},
+		{name: "show", args: []string{"show", "mylogin"}},
+		{name: "show-reveal", args: []string{"show", "-reveal", "mylogin"}},
+	}
+
+	for _, command := range commands {
+		for _, format := range []string{"text", "json", "jsonl", "csv", "yaml", "tsv"} {
+			name := command.name + "-" + format
+			t.Run(name, func(t *testing.T) {
+				checkGolden(t, name, enpass(t, withFormat(command.args, format)...))
+			})
+		}
+	}
+}
+
+func TestOutputEnvelope(t *testing.T) {
+	for _, args := range [][]string{{"list"}, {"list", "-q", "no such item"}, {"show", "mylogin"}, {"info"}} {
+		var envelope struct {
+			SchemaVersion *int              `json:"schema_version"`
+			Kind          string            `json:"kind"`
+			Records       []json.RawMessage `json:"records"`
+		}
+		out := enpass(t, withFormat(args, "json")...)
+		if err := json.Unmarshal([]byte(out), &envelope); err != nil {
+			t.Fatalf("%s: %v", args, err)
+		}
+		if envelope.SchemaVersion == nil || *envelope.SchemaVersion != 1 || envelope.Kind == "" || envelope.Records == nil {
+			t.Errorf("%s: envelope %s", args, out)
+		}
+
+		dec := json.NewDecoder(strings.NewReader(enpass(t, withFormat(args, "jsonl")...)))
+		for {
+			var line map[string]interface{}
+			if err := dec.Decode(&line); err == io.EOF {
+				break
+			} else if err != nil {
+				t.Fatalf("%s: %v", args, err)
+			}
+			if line["schema_version"] != float64(1) || line["kind"] != envelope.Kind {
+				t.Errorf("%s: jsonl line %v has no schema_version 1 and kind %s", args, line, envelope.Kind)
+			}
+		}
+	}
+}
+
+func TestOutputMasksSecrets(t *testing.T) {
+	for _, format := range []string{"text", "json", "jsonl", "csv", "yaml", "tsv"} {
+		masked := enpass(t, "show", "-format", format, "mylogin")
+		if strings.Contains(masked, "mypassword") || !strings.Contains(masked, "********") {
+			t.Errorf("%s: show without -reveal does not mask the password:\n%s", format, masked)
+		}
+
+		revealed := enpass(t, "show", "-reveal", "-format", format, "mylogin")
+		if !strings.Contains(revealed, "mypassword") {
+			t.Errorf("%s: show -reveal does not print the password:\n%s", format, revealed)
+		}
+	}
+}
+
+func TestSeparatedHeaderWithoutRecords(t *testing.T) {
+	for format, separator := range map[string]string{"csv": ",", "tsv": "\t"} {
+		out := enpass(t, "list", "-q", "no such item", "-format", format)
+		want := strings.Join([]string{"uuid", "title", "subtitle", "category", "template", "tags", "favorite",
+			"trashed", "archived", "created_at", "updated_at"}, separator) + "\n"
+		if out != want {
+			t.Errorf("%s: printed %q, want only the header %q", format, out, want)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"main/agent"
+	"main/enpasscli"
+)
+
+const (
+	// socket of a running agent, read commands use it instead of unlocking the vault
+	envAgentSock = "ENPASS_AGENT_SOCK"
+	// process id of a detached agent, so scripts can stop it
+	envAgentPID = "ENPASS_AGENT_PID"
+	// name of the socket in the private directory the agent creates by default
+	agentSocketName = "agent.sock"
+	// how long commands wait for an agent to answer its first request
+	agentDialTimeout = 5 * time.Second
+	// descriptors of the detached agent for the master password and its report, after stdin, stdout and stderr
+	agentPasswordFD = 3
+	agentReadyFD    = 4
+)
+
+// agentReport : how a detached agent tells the command that started it that it is serving, or why not
+type agentReport struct {
+	Socket string `json:"socket,omitempty"`
+	PID    int    `json:"pid,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Code   int    `json:"code,omitempty"`
+}
+
+func runAgent(a *app, args []string) error {
+	fs := flag.NewFlagSet("agent", flag.ContinueOnError)
+	socketPath := fs.String("socket", "", "listen on this path instead of a socket in a new private temporary directory")
+	idleTimeout := fs.Duration("idle-timeout", 15*time.Minute, "lock and stop after this long without requests, 0 never locks")
+	lock := fs.Bool("lock", false, "lock the agent at "+envAgentSock+" instead of starting one")
+	foreground := fs.Bool("foreground", false, "serve in this process and log to stderr instead of detaching")
+	readyFD := fs.Int("ready-fd", -1, "with -foreground, report the socket as a JSON line on this file descriptor instead of printing it")
+	if err := parseFlags(fs, args, a.stderr); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		return usageErrorf("agent takes no arguments")
+	}
+	if *idleTimeout < 0 {
+		return usageErrorf("-idle-timeout must not be negative")
+	}
+
+	if *lock {
+		return lockAgent(a)
+	}
+
+	if !*foreground {
+		return detachAgent(a, *socketPath, *idleTimeout)
+	}
+
+	if *readyFD < 0 {
+		return serveAgent(a, *socketPath, *idleTimeout, func(path string) {
+			printAgentEnv(a.stdout, path, os.Getpid())
+		})
+	}
+
+	ready := os.NewFile(uintptr(*readyFD), "ready-fd")
+	if ready == nil {
+		return usageErrorf("invalid ready file descriptor %d", *readyFD)
+	}
+
+	reported := false
+	err := serveAgent(a, *socketPath, *idleTimeout, func(path string) {
+		json.NewEncoder(ready).Encode(agentReport{Socket: path, PID: os.Getpid()})
+		ready.Close()
+		reported = true
+	})
+	if err != nil && !reported {
+		json.NewEncoder(ready).Encode(agentReport{Error: err.Error(), Code: exitCode(err)})
+		ready.Close()
+	}
+
+	return err
+}
+
+// serveAgent : unlock the vault and serve it on the socket until the agent locks, started is called once it listens
+func serveAgent(a *app, socketPath string, idleTimeout time.Duration, started func(path string)) error {
+	path := socketPath
+	if path == "" {
+		dir, err := ioutil.TempDir("", "enpass-agent")
+		if err != nil {
+			return errors.Wrap(err, "could not create socket directory")
+		}
+		defer os.RemoveAll(dir)
+
+		path = filepath.Join(dir, agentSocketName)
+	}
+
+	// listen first, so a taken socket path fails before the password prompt
+	listener, err := agent.Listen(path)
+	if err != nil {
+		return err
+	}
+	defer listener.Close()
+
+	// the server closes the vault when it locks, closing it again on exit does no harm
+	vault, err := a.openVault()
+	if err != nil {
+		return err
+	}
+
+	started(path)
+
+	if idleTimeout > 0 {
+		a.log.Infof("agent %d serving vault %s, locks after %s without requests", os.Getpid(), vault.Info().VaultName, idleTimeout)
+	} else {
+		a.log.Infof("agent %d serving vault %s until it is stopped", os.Getpid(), vault.Info().VaultName)
+	}
+
+	err = agent.NewServer(vault, idleTimeout, a.log).Serve(a.ctx, listener)
+	a.log.Infof("agent %d locked", os.Getpid())
+	return err
+}
+
+// detachAgent : like ssh-agent, run the agent in a new background process and print where it listens once it
+// serves, so eval "$(enpass agent)" returns. The master password is read here and handed over on a pipe.
+func detachAgent(a *app, socketPath string, idleTimeout time.Duration) error {
+	// no prompt for a vault that is not there
+	if _, err := enpasscli.ReadVaultRef(a.vaultDir); err != nil {
+		return err
+	}
+
+	args, err := a.detachedAgentArgs(socketPath, idleTimeout)
+	if err != nil {
+		return err
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return errors.Wrap(err, "could not find the enpass executable")
+	}
+
+	password, err := readPassword(a.passwordFD)
+	if err != nil {
+		return err
+	}
+	defer wipe(password)
+
+	passwordR, passwordW, err := os.Pipe()
+	if err != nil {
+		return errors.Wrap(err, "could not create password pipe")
+	}
+	defer passwordW.Close()
+	readyR, readyW, err := os.Pipe()
+	if err != nil {
+		passwordR.Close()
+		return errors.Wrap(err, "could not create report pipe")
+	}
+	defer readyR.Close()
+
+	cmd := exec.Command(executable, args...)
+	cmd.ExtraFiles = []*os.File{passwordR, readyW}
+	cmd.Dir = "/"
+	cmd.SysProcAttr = detachedProcAttr()
+	err = cmd.Start()
+	passwordR.Close()
+	readyW.Close()
+	if err != nil {
+		return errors.Wrap(err, "could not start agent")
+	}
+
+	// the agent reads the password right away, before it derives the key
+	passwordW.Write(password)
+	passwordW.Write([]byte{'\n'})
+	passwordW.Close()
+
+	// the agent runs in its own session, so an interrupt here has to stop it
+	waiting := make(chan struct{})
+	defer close(waiting)
+	go func() {
+		select {
+		case <-a.ctx.Done():
+			cmd.Process.Kill()
+		case <-waiting:
+		}
+	}()
+
+	var report agentReport
+	if err := json.NewDecoder(readyR).Decode(&report); err != nil {
+		waitErr := cmd.Wait()
+		if a.ctx.Err() != nil {
+			return a.ctx.Err()
+		}
+		return errors.Errorf("agent exited before it was ready: %v", waitErr)
+	}
+
+	if report.Error != "" {
+		cmd.Wait()
+		return &childError{msg: report.Error, code: report.Code}
+	}
+
+	cmd.Process.Release()
+	printAgentEnv(a.stdout, report.Socket, report.PID)
+	return nil
+}
+
+// detachedAgentArgs : the command line of the detached agent, paths made absolute as it runs in /
+func (a *app) detachedAgentArgs(socketPath string, idleTimeout time.Duration) ([]string, error) {
+	vaultDir, err := filepath.Abs(a.vaultDir)
+	if err != nil {
+		return nil, err
+	}
+
+	args := []string{"-vault", vaultDir, "-password-fd", strconv.Itoa(agentPasswordFD)}
+	if a.keyfilePath != "" {
+		keyfilePath, err := filepath.Abs(a.keyfilePath)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-keyfile", keyfilePath)
+	}
+	if a.snapshot {
+		args = append(args, "-snapshot")
+	} else if a.readOnly {
+		args = append(args, "-read-only")
+	}
+
+	args = append(args, "agent", "-foreground", "-ready-fd", strconv.Itoa(agentReadyFD), "-idle-timeout", idleTimeout.String())
+	if socketPath != "" {
+		socketPath, err := filepath.Abs(socketPath)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, "-socket", socketPath)
+	}
+
+	return args, nil
+}
+
+// printAgentEnv : shell commands pointing later commands at the agent, for eval like the output of ssh-agent
+func printAgentEnv(w io.Writer, path string, pid int) {
+	fmt.Fprintf(w, "%s=%s; export %s;\n", envAgentSock, shellQuote(path), envAgentSock)
+	fmt.Fprintf(w, "%s=%d; export %s;\n", envAgentPID, pid, envAgentPID)
+}
+
+// lockAgent : make the agent at ENPASS_AGENT_SOCK close the vault and stop
+func lockAgent(a *app) error {
+	path := os.Getenv(envAgentSock)
+	if path == "" {
+		return usageErrorf("%s is not set", envAgentSock)
+	}
+
+	ctx, cancel := context.WithTimeout(a.ctx, agentDialTimeout)
+	defer cancel()
+
+	client, err := agent.Dial(ctx, path)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	return client.Lock(ctx)
+}
+
+// dialAgent : the agent at ENPASS_AGENT_SOCK, nil if none is set, it cannot be reached or it serves another vault
+func (a *app) dialAgent() *agent.Client {
+	if a.agent != nil {
+		return a.agent
+	}
+
+	path := os.Getenv(envAgentSock)
+	if path == "" {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(a.ctx, agentDialTimeout)
+	defer cancel()
+
+	client, err := agent.Dial(ctx, path)
+	if err != nil {
+		a.log.Debugf("not using the agent at %s: %v", path, err)
+		return nil
+	}
+
+	// scripts may set only the socket, so a directory without a vault does not count as another vault
+	if ref, err := enpasscli.ReadVaultRef(a.vaultDir); err == nil && ref.UUID != client.Info().VaultUUID {
+		a.log.Debugf("not using the agent at %s, it serves vault %s", path, client.Info().VaultName)
+		client.Close()
+		return nil
+	}
+
+	a.log.Debugf("reading vault %s from the agent at %s", client.Info().VaultName, path)
+	a.agent = client
+	return client
+}
+
+// shellQuote : s as a single POSIX shell word
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
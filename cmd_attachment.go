@@ -0,0 +1,110 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+func runAttachment(a *app, args []string) error {
+	if len(args) == 0 {
+		return usageErrorf("usage: attachment list <item> | attachment get [-out file] <attachment-uuid>")
+	}
+
+	switch args[0] {
+	case "list":
+		return runAttachmentList(a, args[1:])
+	case "get":
+		return runAttachmentGet(a, args[1:])
+	default:
+		return usageErrorf("unknown attachment command %q, use list or get", args[0])
+	}
+}
+
+func runAttachmentList(a *app, args []string) error {
+	fs := flag.NewFlagSet("attachment list", flag.ContinueOnError)
+	format := addOutputFlags(fs, false)
+	if err := parseFlags(fs, args, a.stderr); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return usageErrorf("usage: attachment list [flags] <item>")
+	}
+
+	if err := format.validate(); err != nil {
+		return err
+	}
+
+	vault, err := a.openVault()
+	if err != nil {
+		return err
+	}
+
+	item, err := vault.FindItem(a.ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	attachments, err := vault.ListAttachments(item.UUID)
+	if err != nil {
+		return err
+	}
+
+	out := output{
+		kind:    "attachment",
+		records: make([]record, 0, len(attachments)),
+		columns: attachmentRecord(enpasscli.Attachment{}).keys(),
+	}
+	for _, attachment := range attachments {
+		out.records = append(out.records, attachmentRecord(attachment))
+	}
+
+	return format.write(a.stdout, out)
+}
+
+func runAttachmentGet(a *app, args []string) error {
+	fs := flag.NewFlagSet("attachment get", flag.ContinueOnError)
+	out := fs.String("out", "", "write to this file instead of stdout, created with 0600 permissions")
+	if err := parseFlags(fs, args, a.stderr); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return usageErrorf("usage: attachment get [-out file] <attachment-uuid>")
+	}
+
+	vault, err := a.openVault()
+	if err != nil {
+		return err
+	}
+
+	content, attachment, err := vault.OpenAttachment(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	a.log.Debugf("attachment %s: %s, %d bytes, sha256 %s", attachment.UUID, attachment.Name, attachment.Size, attachment.SHA256)
+
+	if *out == "" {
+		_, err := io.Copy(a.stdout, content)
+		return errors.Wrap(err, "could not write attachment")
+	}
+
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return errors.Wrap(err, "could not create attachment file")
+	}
+
+	if _, err := io.Copy(f, content); err != nil {
+		f.Close()
+		return errors.Wrap(err, "could not write attachment")
+	}
+
+	return errors.Wrap(f.Close(), "could not write attachment")
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"main/audit"
+)
+
+// thresholdError : the audit found more problems than allowed
+type thresholdError struct {
+	findings  int
+	threshold int
+}
+
+func (e *thresholdError) Error() string {
+	return fmt.Sprintf("%d findings, more than the threshold of %d", e.findings, e.threshold)
+}
+
+func runAudit(a *app, args []string) error {
+	if len(args) > 0 && args[0] == "breached" {
+		return runAuditBreached(a, args[1:])
+	}
+
+	fs := flag.NewFlagSet("audit", flag.ContinueOnError)
+	filters := addQueryFlags(fs)
+	format := addOutputFlags(fs, false)
+	checks := fs.String("checks", "weak,reused,old,totp,http", "comma-separated checks to run: weak, reused, old, totp, http")
+	minEntropy := fs.Float64("min-entropy", audit.DefaultMinEntropy, "passwords with a lower estimated entropy in bits are weak, 0 disables the check")
+	maxAgeDays := fs.Int("max-age-days", 365, "passwords not changed for more days are old, 0 disables the check")
+	threshold := addThresholdFlag(fs)
+	if err := parseFlags(fs, args, a.stderr); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		return usageErrorf("audit takes no arguments")
+	}
+
+	if err := format.validate(); err != nil {
+		return err
+	}
+
+	query, err := filters.query()
+	if err != nil {
+		return err
+	}
+
+	opts := audit.Options{
+		MinEntropy: *minEntropy,
+		MaxAge:     time.Duration(*maxAgeDays) * 24 * time.Hour,
+	}
+	for _, name := range splitList(*checks) {
+		check, err := audit.ParseCheck(name)
+		if err != nil {
+			return usageErrorf("invalid -checks: %v", err)
+		}
+		opts.Checks = append(opts.Checks, check)
+	}
+
+	vault, err := a.items()
+	if err != nil {
+		return err
+	}
+
+	items, err := vault.Search(a.ctx, query)
+	if err != nil {
+		return err
+	}
+
+	report, err := audit.Run(items, opts)
+	if err != nil {
+		return err
+	}
+
+	return writeFindings(a, format, report, *threshold)
+}
+
+func runAuditBreached(a *app, args []string) error {
+	fs := flag.NewFlagSet("audit breached", flag.ContinueOnError)
+	filters := addQueryFlags(fs)
+	format := addOutputFlags(fs, false)
+	hibp := fs.String("hibp", "", "Have I Been Pwned SHA-1 list ordered by hash, as text or a binary index")
+	index := fs.String("write-index", "", "convert the -hibp text list to a binary index at this path and exit")
+	threshold := addThresholdFlag(fs)
+	if err := parseFlags(fs, args, a.stderr); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		return usageErrorf("audit breached takes no arguments")
+	}
+	if *hibp == "" {
+		return usageErrorf("audit breached needs -hibp")
+	}
+
+	if *index != "" {
+		return writePwnedIndex(*hibp, *index)
+	}
+
+	if err := format.validate(); err != nil {
+		return err
+	}
+
+	query, err := filters.query()
+	if err != nil {
+		return err
+	}
+
+	db, err := audit.OpenPwnedDB(*hibp)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	vault, err := a.items()
+	if err != nil {
+		return err
+	}
+
+	items, err := vault.Search(a.ctx, query)
+	if err != nil {
+		return err
+	}
+
+	report, err := audit.RunBreached(items, db)
+	if err != nil {
+		return err
+	}
+
+	return writeFindings(a, format, report, *threshold)
+}
+
+func writePwnedIndex(listPath string, indexPath string) error {
+	in, err := os.Open(listPath)
+	if err != nil {
+		return errors.Wrap(err, "could not open breached password list")
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(indexPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return errors.Wrap(err, "could not create breached password index")
+	}
+
+	if err := audit.WritePwnedIndex(out, in); err != nil {
+		out.Close()
+		os.Remove(indexPath)
+		return errors.Wrap(err, "could not write breached password index")
+	}
+
+	return errors.Wrap(out.Close(), "could not write breached password index")
+}
+
+func addThresholdFlag(fs *flag.FlagSet) *int {
+	return fs.Int("threshold", 0, "exit with status 6 when there are more findings, negative never fails")
+}
+
+// writeFindings : print the report, failing when it has more findings than threshold
+func writeFindings(a *app, format *outputFlags, report audit.Report, threshold int) error {
+	if err := format.write(a.stdout, findingsOutput(report)); err != nil {
+		return err
+	}
+
+	if threshold >= 0 && len(report.Findings) > threshold {
+		return &thresholdError{findings: len(report.Findings), threshold: threshold}
+	}
+
+	return nil
+}
+
+func findingsOutput(report audit.Report) output {
+	out := output{
+		kind:    "finding",
+		records: make([]record, 0, len(report.Findings)),
+		columns: findingRecord(audit.Finding{}).keys(),
+	}
+	for _, finding := range report.Findings {
+		out.records = append(out.records, findingRecord(finding))
+	}
+
+	out.text = func(w io.Writer) error {
+		if len(out.records) > 0 {
+			if err := writeTable(w, out.records); err != nil {
+				return err
+			}
+		}
+
+		_, err := fmt.Fprintf(w, "%d findings in %d items\n", len(report.Findings), report.Items)
+		return err
+	}
+
+	return out
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+func runBackup(a *app, args []string) error {
+	fs := flag.NewFlagSet("backup", flag.ContinueOnError)
+	out := fs.String("out", "", "backup file, - for stdout, by default enpass-backup-<vault uuid>-<time>.tar in the current directory")
+	encrypt := fs.Bool("encrypt", false, "encrypt the backup with a passphrase (env "+envBackupPassphrase+")")
+	passphraseFD := fs.Int("passphrase-fd", -1, "read the backup passphrase from this file descriptor, implies -encrypt")
+	if err := parseFlags(fs, args, a.stderr); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		return usageErrorf("backup takes no arguments")
+	}
+
+	ref, err := enpasscli.ReadVaultRef(a.vaultDir)
+	if err != nil {
+		return err
+	}
+
+	var passphrase []byte
+	if *encrypt || *passphraseFD >= 0 {
+		if passphrase, err = readBackupPassphrase(*passphraseFD, true); err != nil {
+			return err
+		}
+		defer wipe(passphrase)
+
+		if len(passphrase) == 0 {
+			return usageErrorf("the backup passphrase is empty")
+		}
+	}
+
+	path := *out
+	if path == "" {
+		path = fmt.Sprintf("enpass-backup-%s-%s.tar", ref.UUID, time.Now().UTC().Format("20060102T150405Z"))
+		if passphrase != nil {
+			path += ".enc"
+		}
+	}
+
+	if path == "-" {
+		manifest, err := enpasscli.WriteBackup(a.stdout, a.vaultDir, passphrase)
+		if err != nil {
+			return err
+		}
+
+		a.log.Infof("backed up %d files of vault %s", len(manifest.Files), manifest.VaultName)
+		return nil
+	}
+
+	// never overwrite, an older backup may be the one that is still good
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return errors.Wrap(err, "could not create backup file")
+	}
+
+	manifest, err := enpasscli.WriteBackup(f, a.vaultDir, passphrase)
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = errors.Wrap(closeErr, "could not write backup")
+	}
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	if passphrase == nil {
+		a.log.Warnf("the backup is not encrypted with a passphrase, only the vault database itself is encrypted")
+	}
+	a.log.Infof("backed up %d files of vault %s to %s", len(manifest.Files), manifest.VaultName, path)
+	return nil
+}
+
+func runRestore(a *app, args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ContinueOnError)
+	force := fs.Bool("force", false, "replace the vault even if it was modified after the backup or is a different vault")
+	itemQuery := fs.String("item", "", "only restore this item from the backup into the vault, its attachments are not restored")
+	passphraseFD := fs.Int("passphrase-fd", -1, "read the backup passphrase from this file descriptor (env "+envBackupPassphrase+")")
+	args, err := parseFlagsInterspersed(fs, args, a.stderr)
+	if err != nil {
+		return err
+	}
+
+	if len(args) != 1 {
+		return usageErrorf("usage: restore [-force] [-item <item>] <backup>")
+	}
+
+	// before the backup passphrase is asked for
+	if err := a.checkWritable(); err != nil {
+		return err
+	}
+
+	backup, err := openBackup(args[0], *passphraseFD)
+	if err != nil {
+		return err
+	}
+	defer backup.Close()
+
+	manifest := backup.Manifest
+	a.log.Infof("verified %d files of the backup of vault %s from %s", len(manifest.Files), manifest.VaultName,
+		time.Unix(manifest.LastModifiedTime, 0).Format(time.RFC3339))
+
+	if *itemQuery != "" {
+		return restoreItem(a, backup, *itemQuery)
+	}
+
+	if err := backup.Restore(a.vaultDir, *force); err != nil {
+		return err
+	}
+
+	a.log.Infof("restored vault %s to %s", manifest.VaultName, a.vaultDir)
+	return nil
+}
+
+func openBackup(path string, passphraseFD int) (*enpasscli.Backup, error) {
+	encrypted, err := enpasscli.BackupEncrypted(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var passphrase []byte
+	if encrypted {
+		if passphrase, err = readBackupPassphrase(passphraseFD, false); err != nil {
+			return nil, err
+		}
+		defer wipe(passphrase)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open backup")
+	}
+	defer f.Close()
+
+	return enpasscli.OpenBackup(f, passphrase)
+}
+
+// restoreItem : copy one item from the vault in the backup into the live vault, both unlock with the same master password
+func restoreItem(a *app, backup *enpasscli.Backup, query string) error {
+	password, err := readPassword(a.passwordFD)
+	if err != nil {
+		return err
+	}
+	defer wipe(password)
+
+	backupVault, err := backup.OpenVault(a.ctx, append(a.openOptions(), enpasscli.WithPassword(password))...)
+	if err != nil {
+		return errors.Wrap(err, "could not open the vault in the backup")
+	}
+	defer backupVault.Close()
+
+	item, err := backupVault.FindItem(a.ctx, query)
+	if err != nil {
+		return err
+	}
+
+	vault, err := a.openVaultWith(password)
+	if err != nil {
+		return err
+	}
+
+	restored, err := vault.UpdateItem(a.ctx, item)
+	if errors.Is(err, enpasscli.ErrItemNotFound) {
+		// deleted since the backup, it comes back under a new uuid
+		restored, err = vault.CreateItem(a.ctx, item)
+	}
+	if err != nil {
+		return err
+	}
+
+	a.log.Infof("restored item %q (%s)", restored.Title, restored.UUID)
+	return nil
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+	"main/exporter"
+)
+
+// the enpass-cli item model, the default export format
+const itemsExportFormat = "json"
+
+func runExport(a *app, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	to := fs.String("to", itemsExportFormat, "export format: "+strings.Join(append([]string{itemsExportFormat}, exporter.Formats()...), ", "))
+	out := fs.String("out", "", "write to this file instead of stdout, created with 0600 permissions")
+	if err := parseFlags(fs, args, a.stderr); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		return usageErrorf("export takes no arguments")
+	}
+
+	if *to != itemsExportFormat && !isExportFormat(*to) {
+		return usageErrorf("invalid -to %q, use one of %s, %s", *to, itemsExportFormat, strings.Join(exporter.Formats(), ", "))
+	}
+
+	vault, err := a.openVault()
+	if err != nil {
+		return err
+	}
+
+	items, err := vault.GetItems(a.ctx)
+	if err != nil {
+		return err
+	}
+
+	// printed at every log level, the export itself may go to a file or a pipe out of sight
+	fmt.Fprintln(a.stderr, "WARNING: the export contains every password and secret of the vault in PLAINTEXT")
+	fmt.Fprintln(a.stderr, "WARNING: keep it off shared and synced storage and delete it as soon as it was imported")
+
+	if *out == "" {
+		return writeExport(a, vault, items, *to, a.stdout)
+	}
+
+	f, err := os.OpenFile(*out, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return errors.Wrap(err, "could not create export file")
+	}
+
+	// an existing file keeps its mode on open
+	err = f.Chmod(0600)
+	if err != nil {
+		err = errors.Wrap(err, "could not restrict export file permissions")
+	} else {
+		err = writeExport(a, vault, items, *to, f)
+	}
+	if closeErr := f.Close(); err == nil && closeErr != nil {
+		err = errors.Wrap(closeErr, "could not write export")
+	}
+
+	return err
+}
+
+// writeExport : items in format to, the json format follows the output.go rule that absent lists are empty lists
+func writeExport(a *app, vault *enpasscli.Vault, items []enpasscli.Item, to string, w io.Writer) error {
+	if to == itemsExportFormat {
+		for i := range items {
+			if items[i].Tags == nil {
+				items[i].Tags = []string{}
+			}
+			if items[i].Fields == nil {
+				items[i].Fields = []enpasscli.Field{}
+			}
+		}
+
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return errors.Wrap(enc.Encode(items), "could not write export")
+	}
+
+	entries := make([]exporter.Entry, 0, len(items))
+	for _, item := range items {
+		attachments, err := vault.ListAttachments(item.UUID)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, exporter.Entry{Item: item, Attachments: attachments})
+	}
+
+	a.log.Infof("exporting %d items as %s, attachments are referenced by name only", len(entries), to)
+	return exporter.Write(w, to, entries)
+}
+
+func isExportFormat(name string) bool {
+	for _, format := range exporter.Formats() {
+		if format == name {
+			return true
+		}
+	}
+
+	return false
+}
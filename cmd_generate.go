@@ -0,0 +1,131 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+
+	"main/generator"
+)
+
+var characterClasses = []string{"lower", "upper", "digits", "symbols"}
+
+// generatorFlags : the password and passphrase settings shared by generate and rotate
+type generatorFlags struct {
+	passphrase bool
+
+	length     int
+	classes    string
+	minLower   int
+	minUpper   int
+	minDigits  int
+	minSymbols int
+	ambiguous  bool
+
+	words      int
+	separator  string
+	capitalize bool
+}
+
+func addGeneratorFlags(fs *flag.FlagSet) *generatorFlags {
+	f := &generatorFlags{}
+	fs.BoolVar(&f.passphrase, "passphrase", false, "generate a passphrase of words instead of a password")
+	fs.IntVar(&f.length, "length", generator.DefaultLength, "password length")
+	fs.StringVar(&f.classes, "classes", strings.Join(characterClasses, ","), "comma-separated password character classes: "+strings.Join(characterClasses, ", "))
+	fs.IntVar(&f.minLower, "min-lower", 0, "at least this many lower case letters")
+	fs.IntVar(&f.minUpper, "min-upper", 0, "at least this many upper case letters")
+	fs.IntVar(&f.minDigits, "min-digits", 0, "at least this many digits")
+	fs.IntVar(&f.minSymbols, "min-symbols", 0, "at least this many symbols")
+	fs.BoolVar(&f.ambiguous, "no-ambiguous", false, "leave out easily confused characters like l, 1, O and 0")
+	fs.IntVar(&f.words, "words", generator.DefaultWords, "passphrase word count")
+	fs.StringVar(&f.separator, "separator", generator.DefaultSeparator, "passphrase word separator")
+	fs.BoolVar(&f.capitalize, "capitalize", false, "capitalize passphrase words")
+	return f
+}
+
+func (f *generatorFlags) generate() (generator.Generated, error) {
+	if f.passphrase {
+		generated, err := generator.Passphrase(generator.PassphraseOptions{
+			Words:      f.words,
+			Separator:  f.separator,
+			Capitalize: f.capitalize,
+		})
+		if err != nil {
+			return generator.Generated{}, usageErrorf("%v", err)
+		}
+
+		return generated, nil
+	}
+
+	opts := generator.PasswordOptions{
+		Length:           f.length,
+		MinLower:         f.minLower,
+		MinUpper:         f.minUpper,
+		MinDigits:        f.minDigits,
+		MinSymbols:       f.minSymbols,
+		ExcludeAmbiguous: f.ambiguous,
+	}
+	for _, class := range splitList(f.classes) {
+		switch class {
+		case "lower":
+			opts.Lower = true
+		case "upper":
+			opts.Upper = true
+		case "digits":
+			opts.Digits = true
+		case "symbols":
+			opts.Symbols = true
+		default:
+			return generator.Generated{}, usageErrorf("invalid -classes entry %q, use %s", class, strings.Join(characterClasses, ", "))
+		}
+	}
+
+	generated, err := generator.Password(opts)
+	if err != nil {
+		return generator.Generated{}, usageErrorf("%v", err)
+	}
+
+	return generated, nil
+}
+
+func (f *generatorFlags) kind() string {
+	if f.passphrase {
+		return "passphrase"
+	}
+
+	return "password"
+}
+
+func runGenerate(a *app, args []string) error {
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	settings := addGeneratorFlags(fs)
+	format := addOutputFlags(fs, false)
+	if err := parseFlags(fs, args, a.stderr); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		return usageErrorf("generate takes no arguments")
+	}
+
+	if err := format.validate(); err != nil {
+		return err
+	}
+
+	generated, err := settings.generate()
+	if err != nil {
+		return err
+	}
+
+	a.log.Debugf("generated a %s with an estimated %.0f bits of entropy", settings.kind(), generated.Entropy)
+
+	return format.write(a.stdout, output{
+		kind: "generated",
+		records: []record{{
+			{"type", settings.kind()},
+			{"value", generated.Value},
+			{"entropy_bits", int(generated.Entropy)},
+		}},
+		text: func(w io.Writer) error { return printValue(w, generated.Value) },
+	})
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+)
+
+const defaultGetField = "password"
+
+func runGet(a *app, args []string) error {
+	fs := flag.NewFlagSet("get", flag.ContinueOnError)
+	format := addOutputFlags(fs, false)
+	if err := parseFlags(fs, args, a.stderr); err != nil {
+		return err
+	}
+
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		return usageErrorf("usage: get [flags] <item> [field]")
+	}
+
+	if err := format.validate(); err != nil {
+		return err
+	}
+
+	fieldName := defaultGetField
+	if fs.NArg() == 2 {
+		fieldName = fs.Arg(1)
+	}
+
+	vault, err := a.items()
+	if err != nil {
+		return err
+	}
+
+	item, err := vault.FindItem(a.ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	field, err := item.Field(fieldName)
+	if err != nil {
+		return err
+	}
+
+	a.log.Debugf("printing field %s of item %s", field, item.UUID)
+
+	// asking for a field is asking for its value, so it is never masked
+	return format.write(a.stdout, output{
+		kind:    "field",
+		records: []record{fieldRecord(item, field, true)},
+		text:    func(w io.Writer) error { return printValue(w, field.Value) },
+	})
+}
+
+// printValue : only the value, a newline is added for terminals alone so scripts get the exact value
+func printValue(w io.Writer, value string) error {
+	if _, err := fmt.Fprint(w, value); err != nil {
+		return err
+	}
+
+	if isTerminal(w) {
+		_, err := fmt.Fprintln(w)
+		return err
+	}
+
+	return nil
+}
+
+// isTerminal : whether w is a character device, i.e. not a pipe or a file
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
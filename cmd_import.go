@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"main/importer"
+)
+
+func runImport(a *app, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	from := fs.String("from", "", "export format of the file: "+strings.Join(importer.Formats(), ", "))
+	commit := fs.Bool("commit", false, "create the items, without it only the report of what would happen is printed")
+	format := addOutputFlags(fs, false)
+	args, err := parseFlagsInterspersed(fs, args, a.stderr)
+	if err != nil {
+		return err
+	}
+
+	if len(args) != 1 || *from == "" {
+		return usageErrorf("usage: import -from <format> [-commit] <file>")
+	}
+
+	if err := format.validate(); err != nil {
+		return err
+	}
+
+	// without -commit import only reads the vault
+	if *commit {
+		if err := a.checkWritable(); err != nil {
+			return err
+		}
+	}
+
+	imported, err := importer.Read(*from, args[0])
+	if err != nil {
+		return err
+	}
+
+	vault, err := a.openVault()
+	if err != nil {
+		return err
+	}
+
+	existing, err := vault.GetItems(a.ctx)
+	if err != nil {
+		return err
+	}
+
+	plan := importer.NewPlan(imported, existing)
+
+	created := make([]string, len(plan.Steps))
+	if *commit {
+		items, err := plan.Apply(a.ctx, vault)
+		if err != nil {
+			return errors.Wrap(err, "nothing was imported")
+		}
+		for i, j := 0, 0; i < len(plan.Steps) && j < len(items); i++ {
+			if plan.Steps[i].Action == importer.ActionCreate {
+				created[i] = items[j].UUID
+				j++
+			}
+		}
+
+		a.log.Infof("created %d items, skipped %d", len(items), plan.Count(importer.ActionSkip))
+	}
+
+	out := output{
+		kind:    "import_step",
+		records: make([]record, 0, len(plan.Steps)),
+		columns: importStepRecord(importer.Step{}, "").keys(),
+	}
+	for i, step := range plan.Steps {
+		out.records = append(out.records, importStepRecord(step, created[i]))
+	}
+
+	out.text = func(w io.Writer) error {
+		if len(out.records) > 0 {
+			if err := writeTable(w, out.records); err != nil {
+				return err
+			}
+		}
+
+		if *commit {
+			_, err := fmt.Fprintf(w, "%d items created, %d skipped\n", plan.Count(importer.ActionCreate), plan.Count(importer.ActionSkip))
+			return err
+		}
+
+		_, err := fmt.Fprintf(w, "%d items would be created, %d skipped; nothing was written, run again with -commit to import\n",
+			plan.Count(importer.ActionCreate), plan.Count(importer.ActionSkip))
+		return err
+	}
+
+	return format.write(a.stdout, out)
+}
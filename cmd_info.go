@@ -0,0 +1,35 @@
+package main
+
+import (
+	"flag"
+	"io"
+)
+
+func runInfo(a *app, args []string) error {
+	fs := flag.NewFlagSet("info", flag.ContinueOnError)
+	format := addOutputFlags(fs, false)
+	if err := parseFlags(fs, args, a.stderr); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		return usageErrorf("info takes no arguments")
+	}
+
+	if err := format.validate(); err != nil {
+		return err
+	}
+
+	vault, err := a.items()
+	if err != nil {
+		return err
+	}
+
+	info := vaultRecord(vault.Info())
+
+	return format.write(a.stdout, output{
+		kind:    "vault",
+		records: []record{info},
+		text:    func(w io.Writer) error { return writeKeyValues(w, info) },
+	})
+}
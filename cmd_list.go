@@ -0,0 +1,68 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"main/enpasscli"
+)
+
+func runList(a *app, args []string) error {
+	fs := flag.NewFlagSet("list", flag.ContinueOnError)
+	filters := addQueryFlags(fs)
+	format := addOutputFlags(fs, false)
+	if err := parseFlags(fs, args, a.stderr); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		return usageErrorf("list takes no arguments, use -q to search")
+	}
+
+	query, err := filters.query()
+	if err != nil {
+		return err
+	}
+
+	return searchAndPrint(a, query, format)
+}
+
+func searchAndPrint(a *app, query enpasscli.Query, format *outputFlags) error {
+	if err := format.validate(); err != nil {
+		return err
+	}
+
+	vault, err := a.items()
+	if err != nil {
+		return err
+	}
+
+	items, err := vault.Search(a.ctx, query)
+	if err != nil {
+		return err
+	}
+
+	out := output{
+		kind:    "item",
+		records: make([]record, 0, len(items)),
+		columns: itemRecord(enpasscli.Item{}).keys(),
+	}
+	for _, item := range items {
+		out.records = append(out.records, itemRecord(item))
+	}
+	out.text = func(w io.Writer) error { return printItemTable(w, items) }
+
+	return format.write(a.stdout, out)
+}
+
+func printItemTable(w io.Writer, items []enpasscli.Item) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "UUID\tCATEGORY\tTITLE\tSUBTITLE")
+	for _, item := range items {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", item.UUID, item.Category, item.Title, item.Subtitle)
+	}
+
+	return tw.Flush()
+}
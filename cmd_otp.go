@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"time"
+)
+
+func runOTP(a *app, args []string) error {
+	fs := flag.NewFlagSet("otp", flag.ContinueOnError)
+	format := addOutputFlags(fs, false)
+	if err := parseFlags(fs, args, a.stderr); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return usageErrorf("usage: otp [flags] <item>")
+	}
+
+	if err := format.validate(); err != nil {
+		return err
+	}
+
+	vault, err := a.items()
+	if err != nil {
+		return err
+	}
+
+	item, err := vault.FindItem(a.ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	code, remaining, err := item.TOTP(now)
+	if err != nil {
+		return err
+	}
+
+	a.log.Debugf("one-time password of item %s is valid for %s", item.UUID, remaining)
+
+	return format.write(a.stdout, output{
+		kind: "otp",
+		records: []record{{
+			{"item_uuid", item.UUID},
+			{"item_title", item.Title},
+			{"code", code},
+			{"expires_at", now.Add(remaining).Truncate(time.Second)},
+			{"remaining_seconds", int(remaining.Round(time.Second) / time.Second)},
+		}},
+		text: func(w io.Writer) error { return printValue(w, code) },
+	})
+}
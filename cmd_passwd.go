@@ -0,0 +1,51 @@
+package main
+
+import (
+	"flag"
+
+	"main/enpasscli"
+)
+
+func runPasswd(a *app, args []string) error {
+	fs := flag.NewFlagSet("passwd", flag.ContinueOnError)
+	iterations := fs.Int("iterations", 0, "PBKDF2 iterations of the new key, 0 keeps the current count")
+	newPasswordFD := fs.Int("new-password-fd", -1, "read the new master password from this file descriptor (env "+envNewPassword+")")
+	if err := parseFlags(fs, args, a.stderr); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		return usageErrorf("passwd takes no arguments")
+	}
+
+	if err := a.checkWritable(); err != nil {
+		return err
+	}
+
+	oldPassword, err := readPassword(a.passwordFD)
+	if err != nil {
+		return err
+	}
+	defer wipe(oldPassword)
+
+	vault, err := a.openVaultWith(oldPassword)
+	if err != nil {
+		return err
+	}
+
+	newPassword, err := readNewPassword(*newPasswordFD)
+	if err != nil {
+		return err
+	}
+	defer wipe(newPassword)
+
+	if err := vault.ChangeMasterPassword(oldPassword, newPassword, enpasscli.ChangePasswordOptions{
+		KeyfilePath:   a.keyfilePath,
+		KDFIterations: *iterations,
+	}); err != nil {
+		return err
+	}
+
+	a.log.Infof("master password of vault %s changed", vault.Info().VaultName)
+	return nil
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"flag"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+func runRotate(a *app, args []string) error {
+	fs := flag.NewFlagSet("rotate", flag.ContinueOnError)
+	fieldName := fs.String("field", defaultGetField, "label or type of the field to replace")
+	settings := addGeneratorFlags(fs)
+	format := addOutputFlags(fs, false)
+	// flags may also follow the item, as in "rotate <item> -field pin"
+	args, err := parseFlagsInterspersed(fs, args, a.stderr)
+	if err != nil {
+		return err
+	}
+
+	if len(args) != 1 {
+		return usageErrorf("usage: rotate [flags] <item>")
+	}
+
+	if err := format.validate(); err != nil {
+		return err
+	}
+
+	if err := a.checkWritable(); err != nil {
+		return err
+	}
+
+	generated, err := settings.generate()
+	if err != nil {
+		return err
+	}
+
+	vault, err := a.openVault()
+	if err != nil {
+		return err
+	}
+
+	item, err := vault.FindItem(a.ctx, args[0])
+	if err != nil {
+		return err
+	}
+
+	index, err := findFieldIndex(item, *fieldName)
+	if err != nil {
+		return err
+	}
+	item.Fields[index].Value = generated.Value
+
+	item, err = vault.UpdateItem(a.ctx, item)
+	if err != nil {
+		return err
+	}
+
+	field := item.Fields[index]
+	a.log.Infof("replaced %s of item %q with a %s of about %.0f bits", field.Name(), item.Title, settings.kind(), generated.Entropy)
+
+	// the new value is printed once, so it can be entered where the old one is used
+	return format.write(a.stdout, output{
+		kind:    "field",
+		records: []record{fieldRecord(item, field, true)},
+		text:    func(w io.Writer) error { return printValue(w, field.Value) },
+	})
+}
+
+// findFieldIndex : like Item.Field, but empty fields match too so they can be filled in
+func findFieldIndex(item enpasscli.Item, name string) (int, error) {
+	for _, matchLabel := range []bool{true, false} {
+		for i, field := range item.Fields {
+			key := field.Type
+			if matchLabel {
+				key = field.Label
+			}
+
+			if strings.EqualFold(key, name) {
+				return i, nil
+			}
+		}
+	}
+
+	return 0, errors.Wrapf(enpasscli.ErrFieldNotFound, "%q in item %q", name, item.Title)
+}
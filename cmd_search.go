@@ -0,0 +1,26 @@
+package main
+
+import (
+	"flag"
+)
+
+func runSearch(a *app, args []string) error {
+	fs := flag.NewFlagSet("search", flag.ContinueOnError)
+	filters := addQueryFlags(fs)
+	format := addOutputFlags(fs, false)
+	if err := parseFlags(fs, args, a.stderr); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return usageErrorf("usage: search [flags] <text>")
+	}
+	filters.text = fs.Arg(0)
+
+	query, err := filters.query()
+	if err != nil {
+		return err
+	}
+
+	return searchAndPrint(a, query, format)
+}
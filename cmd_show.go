@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"main/enpasscli"
+)
+
+func runShow(a *app, args []string) error {
+	fs := flag.NewFlagSet("show", flag.ContinueOnError)
+	format := addOutputFlags(fs, true)
+	if err := parseFlags(fs, args, a.stderr); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 1 {
+		return usageErrorf("usage: show [flags] <item>")
+	}
+
+	if err := format.validate(); err != nil {
+		return err
+	}
+
+	vault, err := a.items()
+	if err != nil {
+		return err
+	}
+
+	item, err := vault.FindItem(a.ctx, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	out := output{
+		kind:    "item",
+		records: []record{itemDetailRecord(item, format.reveal)},
+		flat:    make([]record, 0, len(item.Fields)),
+		columns: fieldRecord(enpasscli.Item{}, enpasscli.Field{}, false).keys(),
+		text:    func(w io.Writer) error { return printItem(w, item, format.reveal) },
+	}
+	for _, field := range item.Fields {
+		out.flat = append(out.flat, fieldRecord(item, field, format.reveal))
+	}
+
+	return format.write(a.stdout, out)
+}
+
+func printItem(w io.Writer, item enpasscli.Item, reveal bool) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintf(tw, "title:\t%s\n", item.Title)
+	fmt.Fprintf(tw, "subtitle:\t%s\n", item.Subtitle)
+	fmt.Fprintf(tw, "uuid:\t%s\n", item.UUID)
+	fmt.Fprintf(tw, "category:\t%s\n", item.Category)
+	fmt.Fprintf(tw, "tags:\t%s\n", strings.Join(item.Tags, ", "))
+	fmt.Fprintf(tw, "created:\t%s\n", formatTime(item.CreatedAt))
+	fmt.Fprintf(tw, "updated:\t%s\n", formatTime(item.UpdatedAt))
+
+	for _, field := range item.Fields {
+		if field.Value == "" {
+			continue
+		}
+		fmt.Fprintf(tw, "%s:\t%s\n", field.Name(), fieldValue(field, reveal))
+	}
+
+	if item.Note != "" {
+		fmt.Fprintf(tw, "note:\t%s\n", item.Note)
+	}
+
+	return tw.Flush()
+}
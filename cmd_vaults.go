@@ -0,0 +1,61 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+
+	"main/enpasscli"
+)
+
+// defaultDataDir : where the Enpass desktop app keeps its vaults unless told otherwise
+func defaultDataDir() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "."
+	}
+
+	return filepath.Join(home, "Documents", "Enpass")
+}
+
+func runVaults(a *app, args []string) error {
+	fs := flag.NewFlagSet("vaults", flag.ContinueOnError)
+	format := addOutputFlags(fs, false)
+	if err := parseFlags(fs, args, a.stderr); err != nil {
+		return err
+	}
+
+	if fs.NArg() > 1 {
+		return usageErrorf("usage: vaults [flags] [root]")
+	}
+
+	if err := format.validate(); err != nil {
+		return err
+	}
+
+	root := defaultDataDir()
+	if fs.NArg() == 1 {
+		root = fs.Arg(0)
+	}
+
+	refs, err := enpasscli.DiscoverVaults(root)
+	if err != nil {
+		return err
+	}
+
+	out := output{
+		kind:    "vault_ref",
+		records: make([]record, 0, len(refs)),
+		columns: vaultRefRecord(enpasscli.VaultRef{}).keys(),
+	}
+	for _, ref := range refs {
+		if ref.Err != nil {
+			a.log.Warnf("skipping %v", ref.Err)
+			continue
+		}
+
+		out.records = append(out.records, vaultRefRecord(ref))
+	}
+
+	return format.write(a.stdout, out)
+}
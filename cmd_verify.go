@@ -0,0 +1,74 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"main/enpasscli"
+)
+
+// problemsError : verify found the vault damaged or inconsistent
+type problemsError struct {
+	problems int
+}
+
+func (e *problemsError) Error() string {
+	return fmt.Sprintf("vault has %d problems", e.problems)
+}
+
+func runVerify(a *app, args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ContinueOnError)
+	format := addOutputFlags(fs, false)
+	if err := parseFlags(fs, args, a.stderr); err != nil {
+		return err
+	}
+
+	if fs.NArg() != 0 {
+		return usageErrorf("verify takes no arguments")
+	}
+
+	if err := format.validate(); err != nil {
+		return err
+	}
+
+	vault, err := a.openVault()
+	if err != nil {
+		return err
+	}
+
+	report, err := vault.Verify(a.ctx)
+	if err != nil {
+		return err
+	}
+
+	out := output{
+		kind:    "problem",
+		records: make([]record, 0, len(report.Problems)),
+		columns: problemRecord(enpasscli.Problem{}).keys(),
+	}
+	for _, problem := range report.Problems {
+		out.records = append(out.records, problemRecord(problem))
+	}
+
+	out.text = func(w io.Writer) error {
+		if len(out.records) > 0 {
+			if err := writeTable(w, out.records); err != nil {
+				return err
+			}
+		}
+
+		_, err := fmt.Fprintf(w, "%d problems in %d items and %d attachments\n", len(report.Problems), report.Items, report.Attachments)
+		return err
+	}
+
+	if err := format.write(a.stdout, out); err != nil {
+		return err
+	}
+
+	if !report.OK() {
+		return &problemsError{problems: len(report.Problems)}
+	}
+
+	return nil
+}
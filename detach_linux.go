@@ -0,0 +1,10 @@
+package main
+
+import (
+	"syscall"
+)
+
+// detachedProcAttr : a new session without a controlling terminal, so closing the terminal does not stop the agent
+func detachedProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"syscall"
+)
+
+// detachedProcAttr : elsewhere the agent stays in the session of the command that started it
+func detachedProcAttr() *syscall.SysProcAttr {
+	return nil
+}
@@ -0,0 +1,220 @@
+package enpasscli
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// extension of the SQLCipher databases holding attachments that are not stored inline
+	attachmentFileExtension = ".enpassattach"
+	// the content of an attachment database
+	attachmentDataQuery = "SELECT data FROM attachment LIMIT 1;"
+)
+
+// Attachment : metadata of a file attached to an item
+type Attachment struct {
+	UUID     string
+	ItemUUID string
+	Name     string
+	Mime     string
+	// size in bytes of the decrypted content
+	Size  int64
+	Order int
+	// stored in the vault database instead of in a <uuid>.enpassattach file
+	Inline bool
+
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	// SHA-256 of the decrypted content, only set by OpenAttachment
+	SHA256 string
+}
+
+// attachmentRow : an attachment row, including the encrypted columns
+type attachmentRow struct {
+	Attachment
+	password []byte
+	data     []byte
+	extra    string
+}
+
+const attachmentColumns = `
+a.uuid, a.item_uuid, COALESCE(a.name, ''), COALESCE(a.mime, ''), COALESCE(a.size, 0), COALESCE(a.orde, 0),
+COALESCE(a.internal, 0), COALESCE(a.created_at, 0), COALESCE(a.updated_at, 0)`
+
+func scanAttachment(scanner interface{ Scan(...interface{}) error }, extra ...interface{}) (Attachment, error) {
+	var a Attachment
+	var createdAt, updatedAt int64
+
+	dest := []interface{}{
+		&a.UUID, &a.ItemUUID, &a.Name, &a.Mime, &a.Size, &a.Order, &a.Inline, &createdAt, &updatedAt,
+	}
+	if err := scanner.Scan(append(dest, extra...)...); err != nil {
+		return Attachment{}, err
+	}
+
+	a.CreatedAt = time.Unix(createdAt, 0)
+	a.UpdatedAt = time.Unix(updatedAt, 0)
+
+	return a, nil
+}
+
+// ListAttachments : the attachments of an item, in display order
+func (v *Vault) ListAttachments(itemUUID string) ([]Attachment, error) {
+	rows, err := v.db.Query(
+		"SELECT "+attachmentColumns+" FROM attachment a WHERE a.item_uuid = ? AND a.deleted = 0 ORDER BY a.orde;",
+		itemUUID,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not retrieve attachments")
+	}
+	defer rows.Close()
+
+	var attachments []Attachment
+	for rows.Next() {
+		attachment, err := scanAttachment(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not read attachment")
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	return attachments, errors.Wrap(rows.Err(), "could not retrieve attachments")
+}
+
+// OpenAttachment : the decrypted and verified content of an attachment
+func (v *Vault) OpenAttachment(id string) (io.ReadCloser, Attachment, error) {
+	var row attachmentRow
+	var err error
+
+	row.Attachment, err = scanAttachment(
+		v.db.QueryRow(
+			"SELECT "+attachmentColumns+", a.password, a.data, COALESCE(a.extra, '') "+
+				"FROM attachment a WHERE a.uuid = ? AND a.deleted = 0;",
+			id,
+		),
+		&row.password, &row.data, &row.extra,
+	)
+	if err == sql.ErrNoRows {
+		return nil, Attachment{}, errors.Wrapf(ErrAttachmentNotFound, "%q", id)
+	} else if err != nil {
+		return nil, Attachment{}, errors.Wrap(err, "could not read attachment")
+	}
+
+	content, err := v.attachmentContent(row)
+	if err != nil {
+		return nil, Attachment{}, err
+	}
+
+	sum := sha256.Sum256(content)
+	row.SHA256 = hex.EncodeToString(sum[:])
+
+	return ioutil.NopCloser(bytes.NewReader(content)), row.Attachment, nil
+}
+
+// attachmentContent : decrypt the content of an attachment row and check it against the recorded size and hash
+func (v *Vault) attachmentContent(row attachmentRow) ([]byte, error) {
+	cipher, err := v.itemCipher(row.ItemUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	var content []byte
+	if row.Inline {
+		content, err = cipher.open(row.data)
+	} else {
+		content, err = v.readAttachmentFile(row, cipher)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not decrypt attachment %s", row.UUID)
+	}
+
+	if err := row.verify(content); err != nil {
+		return nil, errors.Wrapf(err, "attachment %s", row.UUID)
+	}
+
+	return content, nil
+}
+
+// itemCipher : the key schedule of an item, looked up by uuid
+func (v *Vault) itemCipher(itemUUID string) (*itemCipher, error) {
+	var key []byte
+	err := v.db.QueryRow("SELECT key FROM item WHERE uuid = ?;", itemUUID).Scan(&key)
+	if err == sql.ErrNoRows {
+		return nil, errors.Wrapf(ErrItemNotFound, "%q", itemUUID)
+	} else if err != nil {
+		return nil, errors.Wrap(err, "could not read item key")
+	}
+
+	return newItemCipher(itemUUID, key)
+}
+
+// readAttachmentFile : larger attachments are SQLCipher databases, keyed with the attachment password
+func (v *Vault) readAttachmentFile(row attachmentRow, cipher *itemCipher) ([]byte, error) {
+	path := v.attachmentPath(row.UUID)
+	if _, err := os.Stat(path); err != nil {
+		return nil, errors.Wrap(err, "attachment file is missing")
+	}
+
+	key, err := cipher.open(row.password)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decrypt attachment key")
+	}
+
+	attachmentKey := newSecret(key, v.lockMemory)
+	defer attachmentKey.wipe()
+
+	// attachment files are never snapshotted
+	mode := readWrite
+	if v.readOnly {
+		mode = readOnlyImmutable
+	}
+
+	db := openSQLCipher(path, attachmentKey, v.cipherParams, mode)
+	defer db.Close()
+
+	var content []byte
+	if err := db.QueryRow(attachmentDataQuery).Scan(&content); err != nil {
+		return nil, errors.Wrap(err, "could not read attachment file")
+	}
+
+	return content, nil
+}
+
+func (v *Vault) attachmentPath(uuid string) string {
+	return filepath.Join(v.dir, uuid+attachmentFileExtension)
+}
+
+// verify : compare the content with the recorded size and, if the row has one, its SHA-1 hash
+func (row attachmentRow) verify(content []byte) error {
+	if int64(len(content)) != row.Size {
+		return errors.Wrapf(ErrTampered, "size is %d bytes, expected %d", len(content), row.Size)
+	}
+
+	var extra struct {
+		Hash string `json:"hash"`
+	}
+	if row.extra == "" || json.Unmarshal([]byte(row.extra), &extra) != nil || extra.Hash == "" {
+		return nil
+	}
+
+	sum := sha1.Sum(content)
+	if !strings.EqualFold(hex.EncodeToString(sum[:]), extra.Hash) {
+		return errors.Wrap(ErrTampered, "hash does not match")
+	}
+
+	return nil
+}
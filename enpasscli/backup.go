@@ -0,0 +1,388 @@
+package enpasscli
+
+import (
+	"archive/tar"
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// first entry of every backup archive
+	backupManifestName = "manifest.json"
+	// raised when the archive layout or the manifest keys change
+	backupFormatVersion = 1
+)
+
+// BackupManifest : what a backup holds, stored as its first tar entry
+type BackupManifest struct {
+	FormatVersion int       `json:"format_version"`
+	CreatedAt     time.Time `json:"created_at"`
+	VaultUUID     string    `json:"vault_uuid"`
+	VaultName     string    `json:"vault_name"`
+	// last_modified_time of the vault.json in the backup
+	LastModifiedTime int64        `json:"last_modified_time"`
+	Files            []BackupFile `json:"files"`
+}
+
+// BackupFile : a vault file in a backup and the SHA-256 of its content
+type BackupFile struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// WriteBackup : archive vault.enpassdb, vault.json and the attachment files of the vault in dir as tar,
+// encrypted with passphrase unless it is empty. The database stays encrypted with the master password either way.
+func WriteBackup(w io.Writer, dir string, passphrase []byte) (BackupManifest, error) {
+	names, err := vaultFileNames(dir)
+	if err != nil {
+		return BackupManifest{}, err
+	}
+
+	// snapshot first, so the manifest matches the archived content even if Enpass writes meanwhile
+	snapshot, err := ioutil.TempDir("", "enpass-backup")
+	if err != nil {
+		return BackupManifest{}, errors.Wrap(err, "could not create snapshot directory")
+	}
+	defer os.RemoveAll(snapshot)
+
+	manifest := BackupManifest{FormatVersion: backupFormatVersion, CreatedAt: time.Now().UTC().Truncate(time.Second)}
+	for _, name := range names {
+		if err := copyFile(filepath.Join(dir, name), filepath.Join(snapshot, name)); err != nil {
+			return BackupManifest{}, errors.Wrapf(err, "could not copy %s", name)
+		}
+
+		file, err := hashBackupFile(snapshot, name)
+		if err != nil {
+			return BackupManifest{}, err
+		}
+		manifest.Files = append(manifest.Files, file)
+	}
+
+	vaultInfo, err := loadVaultInfo(filepath.Join(snapshot, vaultInfoFileName))
+	if err != nil {
+		return BackupManifest{}, err
+	}
+	manifest.VaultUUID = vaultInfo.VaultUUID
+	manifest.VaultName = vaultInfo.VaultName
+	manifest.LastModifiedTime = vaultInfo.LastModifiedTime
+
+	out := w
+	var encrypter *encryptWriter
+	if len(passphrase) > 0 {
+		if encrypter, err = newEncryptWriter(w, passphrase); err != nil {
+			return BackupManifest{}, err
+		}
+		out = encrypter
+	}
+
+	if err := writeBackupTar(out, snapshot, manifest); err != nil {
+		return BackupManifest{}, errors.Wrap(err, "could not write backup")
+	}
+
+	if encrypter != nil {
+		if err := encrypter.Close(); err != nil {
+			return BackupManifest{}, errors.Wrap(err, "could not write backup")
+		}
+	}
+
+	return manifest, nil
+}
+
+// vaultFileNames : the files of the vault in dir that a backup holds
+func vaultFileNames(dir string) ([]string, error) {
+	names := []string{vaultDatabaseFileName, vaultInfoFileName}
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			return nil, errors.Wrap(err, "not a vault directory")
+		}
+	}
+
+	attachments, err := filepath.Glob(filepath.Join(dir, "*"+attachmentFileExtension))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not list attachment files")
+	}
+	for _, attachment := range attachments {
+		names = append(names, filepath.Base(attachment))
+	}
+
+	return names, nil
+}
+
+func hashBackupFile(dir string, name string) (BackupFile, error) {
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return BackupFile{}, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return BackupFile{}, errors.Wrapf(err, "could not read %s", name)
+	}
+
+	return BackupFile{Name: name, Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+func writeBackupTar(w io.Writer, dir string, manifest BackupManifest) error {
+	tw := tar.NewWriter(w)
+
+	manifestJSON, err := json.MarshalIndent(manifest, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    backupManifestName,
+		Mode:    0600,
+		Size:    int64(len(manifestJSON)),
+		ModTime: manifest.CreatedAt,
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(manifestJSON); err != nil {
+		return err
+	}
+
+	for _, file := range manifest.Files {
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    file.Name,
+			Mode:    0600,
+			Size:    file.Size,
+			ModTime: manifest.CreatedAt,
+		}); err != nil {
+			return err
+		}
+
+		f, err := os.Open(filepath.Join(dir, file.Name))
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(tw, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return tw.Close()
+}
+
+// Backup : a backup extracted to a private temporary directory, its files checked against the manifest
+type Backup struct {
+	Manifest BackupManifest
+	dir      string
+}
+
+// BackupEncrypted : whether the backup at path needs a passphrase
+func BackupEncrypted(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, errors.Wrap(err, "could not open backup")
+	}
+	defer f.Close()
+
+	return isEncryptedBackup(bufio.NewReader(f)), nil
+}
+
+// OpenBackup : extract and verify a backup, the passphrase is only used for encrypted ones
+func OpenBackup(r io.Reader, passphrase []byte) (*Backup, error) {
+	br := bufio.NewReader(r)
+
+	var in io.Reader = br
+	if isEncryptedBackup(br) {
+		if len(passphrase) == 0 {
+			return nil, errors.Wrap(ErrBackupPassphrase, "the backup is encrypted")
+		}
+
+		decrypter, err := newDecryptReader(br, passphrase)
+		if err != nil {
+			return nil, err
+		}
+		in = decrypter
+	}
+
+	dir, err := ioutil.TempDir("", "enpass-restore")
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create restore directory")
+	}
+
+	backup := &Backup{dir: dir}
+	if err := backup.extract(in); err != nil {
+		backup.Close()
+		return nil, err
+	}
+
+	return backup, nil
+}
+
+// Close : remove the extracted files
+func (b *Backup) Close() error {
+	return os.RemoveAll(b.dir)
+}
+
+func (b *Backup) extract(r io.Reader) error {
+	tr := tar.NewReader(r)
+
+	header, err := tr.Next()
+	if err != nil {
+		return errors.Wrap(err, "could not read backup")
+	}
+	if header.Name != backupManifestName {
+		return errors.New("backup does not start with a manifest")
+	}
+	if err := json.NewDecoder(tr).Decode(&b.Manifest); err != nil {
+		return errors.Wrap(err, "could not parse backup manifest")
+	}
+	if b.Manifest.FormatVersion != backupFormatVersion {
+		return errors.Errorf("unsupported backup format version %d", b.Manifest.FormatVersion)
+	}
+
+	expected := make(map[string]BackupFile)
+	for _, file := range b.Manifest.Files {
+		if !isVaultFileName(file.Name) {
+			return errors.Errorf("backup manifest lists unexpected file %q", file.Name)
+		}
+		expected[file.Name] = file
+	}
+
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return errors.Wrap(err, "could not read backup")
+		}
+
+		file, ok := expected[header.Name]
+		if !ok || header.Typeflag != tar.TypeReg {
+			return errors.Wrapf(ErrTampered, "backup holds %q, which is not in its manifest", header.Name)
+		}
+		delete(expected, header.Name)
+
+		if err := b.extractFile(tr, file); err != nil {
+			return err
+		}
+	}
+
+	for name := range expected {
+		return errors.Wrapf(ErrTampered, "backup is missing %s", name)
+	}
+
+	return nil
+}
+
+func (b *Backup) extractFile(r io.Reader, file BackupFile) error {
+	f, err := os.OpenFile(filepath.Join(b.dir, file.Name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return errors.Wrapf(err, "could not extract %s", file.Name)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(f, h), r)
+	if err != nil {
+		return errors.Wrapf(err, "could not extract %s", file.Name)
+	}
+
+	if size != file.Size || hex.EncodeToString(h.Sum(nil)) != file.SHA256 {
+		return errors.Wrapf(ErrTampered, "%s does not match the backup manifest", file.Name)
+	}
+
+	return nil
+}
+
+// isVaultFileName : plain names of the files a vault directory holds, never a path
+func isVaultFileName(name string) bool {
+	if name != filepath.Base(name) || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return false
+	}
+
+	return name == vaultDatabaseFileName || name == vaultInfoFileName || strings.HasSuffix(name, attachmentFileExtension)
+}
+
+// OpenVault : unlock the vault inside the backup, e.g. to copy single items out of it
+func (b *Backup) OpenVault(ctx context.Context, opts ...Option) (*Vault, error) {
+	return Open(ctx, b.dir, opts...)
+}
+
+// Restore : replace the vault files in dir with the backup. A vault in dir that was modified after the
+// backup, or is a different vault, is only replaced with force. Attachment files the backup does not
+// hold are left in place.
+func (b *Backup) Restore(dir string, force bool) error {
+	liveInfo, err := loadVaultInfo(filepath.Join(dir, vaultInfoFileName))
+	switch {
+	case err == nil && !force:
+		if liveInfo.VaultUUID != b.Manifest.VaultUUID {
+			return errors.Wrapf(ErrNewerVault, "%s holds vault %q, the backup is of %q", dir, liveInfo.VaultUUID, b.Manifest.VaultUUID)
+		}
+		if liveInfo.LastModifiedTime > b.Manifest.LastModifiedTime {
+			return errors.Wrapf(ErrNewerVault, "vault was modified %s, the backup is from %s",
+				time.Unix(liveInfo.LastModifiedTime, 0).UTC().Format(time.RFC3339),
+				time.Unix(b.Manifest.LastModifiedTime, 0).UTC().Format(time.RFC3339))
+		}
+	case err != nil && !errors.Is(err, ErrVaultNotFound) && !force:
+		return errors.Wrap(err, "could not compare with the current vault")
+	}
+
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return errors.Wrap(err, "could not create vault directory")
+	}
+
+	var existing []string
+	for _, file := range b.Manifest.Files {
+		path := filepath.Join(dir, file.Name)
+		if _, err := os.Stat(path); err == nil {
+			existing = append(existing, path)
+		}
+	}
+
+	if err := backupFiles(existing); err != nil {
+		return errors.Wrap(err, "could not keep a copy of the current vault")
+	}
+
+	// vault.json goes last, it is what tells Enpass and this package the vault is there
+	files := append([]BackupFile{}, b.Manifest.Files...)
+	for i, file := range files {
+		if file.Name == vaultInfoFileName {
+			files = append(append(files[:i:i], files[i+1:]...), file)
+			break
+		}
+	}
+
+	for _, file := range files {
+		if err := replaceFile(filepath.Join(b.dir, file.Name), filepath.Join(dir, file.Name)); err != nil {
+			if restoreErr := restoreFiles(existing); restoreErr != nil {
+				return errors.Wrapf(err, "could not restore %s, putting back the current vault (%s) failed: %v", file.Name, backupSuffix, restoreErr)
+			}
+			return errors.Wrapf(err, "could not restore %s, the current vault was kept", file.Name)
+		}
+	}
+
+	removeBackups(existing)
+	return nil
+}
+
+// replaceFile : copy src next to dst and rename it over dst
+func replaceFile(src string, dst string) error {
+	tmp := dst + ".restore"
+	if err := copyFile(src, tmp); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,69 @@
+package enpasscli
+
+import (
+	"bytes"
+	"context"
+	"testing"
+)
+
+// restore -item puts an item of the backup over the live one with UpdateItem
+func TestRestoreItemKeepsLiveHistory(t *testing.T) {
+	ctx := context.Background()
+	vault := openTestVault(t)
+
+	var archive bytes.Buffer
+	if _, err := WriteBackup(&archive, vault.dir, testBackupPassphrase); err != nil {
+		t.Fatal(err)
+	}
+	backup, err := OpenBackup(&archive, testBackupPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backup.Close()
+
+	backupVault, err := backup.OpenVault(ctx, WithPassword([]byte(testPassword)), WithReadOnly())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer backupVault.Close()
+
+	saved, err := backupVault.FindItem(ctx, "mylogin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// changed after the backup: a new password with the old one in its history
+	live, err := vault.FindItem(ctx, "mylogin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldCipher, err := newItemCipher(live.UUID, storedItemKey(t, vault, live.UUID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := `[{"value":"` + oldCipher.encrypt("mypassword") + `"}]`
+	if _, err := vault.db.Exec("UPDATE itemfield SET history = ? WHERE item_uuid = ? AND item_field_uid = 11;", history, live.UUID); err != nil {
+		t.Fatal(err)
+	}
+
+	restored, err := vault.UpdateItem(ctx, saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(restored.key, saved.key) {
+		t.Fatal("restored item kept the key of the backup")
+	}
+
+	newCipher, err := newItemCipher(live.UUID, storedItemKey(t, vault, live.UUID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// sealed again under the old key and nonce, the history has to come out as written
+	kept, err := reencryptHistory(storedColumn(t, vault, "history", live.UUID, 11), newCipher, oldCipher)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if kept != history {
+		t.Fatalf("history is %s, expected %s under the new key", kept, history)
+	}
+}
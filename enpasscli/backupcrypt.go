@@ -0,0 +1,223 @@
+package enpasscli
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"io"
+
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+// Encrypted backups are a header followed by AES-256-GCM sealed chunks:
+//
+//	magic (8) | PBKDF2-SHA256 salt (16) | iterations (uint32) | nonce prefix (8)
+//
+// Every chunk holds backupChunkSize bytes of the tar archive, the last one fewer or
+// none. A chunk's nonce is the prefix followed by its index, and its additional data
+// is the header followed by 1 for the last chunk and 0 otherwise, so chunks cannot
+// be reordered, dropped or cut off at the end.
+const (
+	backupMagic          = "ENPBAK01"
+	backupSaltLength     = 16
+	backupNoncePrefixLen = 8
+	backupHeaderLength   = len(backupMagic) + backupSaltLength + 4 + backupNoncePrefixLen
+	backupKDFIterations  = 200000
+	// iteration counts a backup header may ask for, the count is read before the header is authenticated
+	minBackupKDFIterations = 100000
+	maxBackupKDFIterations = 10000000
+	backupKeyLength        = 32
+	backupChunkSize        = 64 * 1024
+)
+
+type backupHeader []byte
+
+func (h backupHeader) salt() []byte {
+	return h[len(backupMagic) : len(backupMagic)+backupSaltLength]
+}
+
+func (h backupHeader) iterations() int {
+	return int(binary.BigEndian.Uint32(h[len(backupMagic)+backupSaltLength:]))
+}
+
+func (h backupHeader) noncePrefix() []byte {
+	return h[backupHeaderLength-backupNoncePrefixLen:]
+}
+
+func newBackupAEAD(passphrase []byte, header backupHeader) (cipher.AEAD, error) {
+	key := pbkdf2.Key(passphrase, header.salt(), header.iterations(), backupKeyLength, sha256.New)
+	defer wipeBytes(key)
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// backupChunk : nonce and additional data of chunk index
+func backupChunk(header backupHeader, index uint32, last bool) ([]byte, []byte) {
+	nonce := make([]byte, 0, backupNoncePrefixLen+4)
+	nonce = append(nonce, header.noncePrefix()...)
+	nonce = append(nonce, 0, 0, 0, 0)
+	binary.BigEndian.PutUint32(nonce[backupNoncePrefixLen:], index)
+
+	additionalData := append(append([]byte{}, header...), 0)
+	if last {
+		additionalData[len(additionalData)-1] = 1
+	}
+
+	return nonce, additionalData
+}
+
+// encryptWriter : seals full chunks as they fill up, Close seals the last one
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header backupHeader
+	index  uint32
+	buf    []byte
+}
+
+func newEncryptWriter(w io.Writer, passphrase []byte) (*encryptWriter, error) {
+	header := make(backupHeader, backupHeaderLength)
+	copy(header, backupMagic)
+	if _, err := rand.Read(header.salt()); err != nil {
+		return nil, errors.Wrap(err, "could not generate backup salt")
+	}
+	binary.BigEndian.PutUint32(header[len(backupMagic)+backupSaltLength:], backupKDFIterations)
+	if _, err := rand.Read(header.noncePrefix()); err != nil {
+		return nil, errors.Wrap(err, "could not generate backup nonce")
+	}
+
+	aead, err := newBackupAEAD(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{w: w, aead: aead, header: header}, nil
+}
+
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	e.buf = append(e.buf, p...)
+
+	// keep at least one byte back, so the last chunk is only sealed by Close
+	for len(e.buf) > backupChunkSize {
+		if err := e.seal(e.buf[:backupChunkSize], false); err != nil {
+			return 0, err
+		}
+		e.buf = e.buf[:copy(e.buf, e.buf[backupChunkSize:])]
+	}
+
+	return len(p), nil
+}
+
+func (e *encryptWriter) Close() error {
+	err := e.seal(e.buf, true)
+	wipeBytes(e.buf)
+	e.buf = nil
+	return err
+}
+
+func (e *encryptWriter) seal(chunk []byte, last bool) error {
+	nonce, additionalData := backupChunk(e.header, e.index, last)
+	e.index++
+
+	_, err := e.w.Write(e.aead.Seal(nil, nonce, chunk, additionalData))
+	return err
+}
+
+// decryptReader : opens one chunk at a time, failing on any change to the ciphertext
+type decryptReader struct {
+	r      *bufio.Reader
+	aead   cipher.AEAD
+	header backupHeader
+	index  uint32
+	plain  []byte
+	done   bool
+}
+
+func newDecryptReader(r *bufio.Reader, passphrase []byte) (*decryptReader, error) {
+	header := make(backupHeader, backupHeaderLength)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, errors.Wrap(err, "could not read backup header")
+	}
+	if !bytes.Equal(header[:len(backupMagic)], []byte(backupMagic)) {
+		return nil, errors.New("not an encrypted backup")
+	}
+	if iterations := header.iterations(); iterations < minBackupKDFIterations || iterations > maxBackupKDFIterations {
+		return nil, errors.Errorf("backup asks for %d key derivation iterations, only %d to %d are accepted",
+			iterations, minBackupKDFIterations, maxBackupKDFIterations)
+	}
+
+	aead, err := newBackupAEAD(passphrase, header)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{r: r, aead: aead, header: header}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+	return n, nil
+}
+
+func (d *decryptReader) next() error {
+	sealed := make([]byte, backupChunkSize+d.aead.Overhead())
+	n, err := io.ReadFull(d.r, sealed)
+
+	last := false
+	switch err {
+	case nil:
+		if _, peekErr := d.r.Peek(1); peekErr == io.EOF {
+			last = true
+		}
+	case io.ErrUnexpectedEOF:
+		last = true
+	case io.EOF:
+		return errors.Wrap(ErrTampered, "backup is truncated")
+	default:
+		return errors.Wrap(err, "could not read backup")
+	}
+
+	nonce, additionalData := backupChunk(d.header, d.index, last)
+	plain, err := d.aead.Open(nil, nonce, sealed[:n], additionalData)
+	if err != nil {
+		if d.index == 0 {
+			return ErrBackupPassphrase
+		}
+		return errors.Wrap(ErrTampered, "backup chunk failed authentication")
+	}
+
+	d.index++
+	d.plain = plain
+	d.done = last
+	return nil
+}
+
+// isEncryptedBackup : whether the stream starts with the encrypted backup header
+func isEncryptedBackup(r *bufio.Reader) bool {
+	magic, err := r.Peek(len(backupMagic))
+	return err == nil && string(magic) == backupMagic
+}
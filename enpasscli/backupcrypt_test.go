@@ -0,0 +1,126 @@
+package enpasscli
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+var testBackupPassphrase = []byte("correct horse battery staple")
+
+func encryptBackup(t *testing.T, plain []byte) []byte {
+	t.Helper()
+
+	var sealed bytes.Buffer
+	w, err := newEncryptWriter(&sealed, testBackupPassphrase)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return sealed.Bytes()
+}
+
+func decryptBackup(sealed []byte, passphrase []byte) ([]byte, error) {
+	r, err := newDecryptReader(bufio.NewReader(bytes.NewReader(sealed)), passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	return ioutil.ReadAll(r)
+}
+
+// testBackupData : n bytes that differ from chunk to chunk
+func testBackupData(n int) []byte {
+	data := make([]byte, n)
+	for i := range data {
+		data[i] = byte(i / 7)
+	}
+
+	return data
+}
+
+func TestBackupStreamRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, backupChunkSize - 1, backupChunkSize, backupChunkSize + 1, 3*backupChunkSize + 5} {
+		plain := testBackupData(size)
+
+		got, err := decryptBackup(encryptBackup(t, plain), testBackupPassphrase)
+		if err != nil {
+			t.Fatalf("%d bytes: %v", size, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("%d bytes: decrypted content differs", size)
+		}
+	}
+}
+
+func TestBackupStreamTampering(t *testing.T) {
+	sealedChunk := backupChunkSize + 16
+	sealed := encryptBackup(t, testBackupData(3*backupChunkSize+5))
+	header, chunks := sealed[:backupHeaderLength], sealed[backupHeaderLength:]
+
+	swapped := append([]byte{}, chunks...)
+	copy(swapped[sealedChunk:], chunks[2*sealedChunk:3*sealedChunk])
+	copy(swapped[2*sealedChunk:], chunks[sealedChunk:2*sealedChunk])
+
+	tests := []struct {
+		name     string
+		sealed   []byte
+		expected error
+	}{
+		{"truncated final chunk", sealed[:len(sealed)-3], ErrTampered},
+		{"final chunk dropped", sealed[:backupHeaderLength+3*sealedChunk], ErrTampered},
+		{"only the header", header, ErrTampered},
+		{"reordered chunks", append(append([]byte{}, header...), swapped...), ErrTampered},
+		{"flipped bit", flipBit(sealed, backupHeaderLength+sealedChunk+10), ErrTampered},
+		// the header is authenticated with the first chunk, which can not tell a change from a wrong passphrase
+		{"changed header", flipBit(sealed, len(backupMagic)), ErrBackupPassphrase},
+	}
+	for _, test := range tests {
+		if _, err := decryptBackup(test.sealed, testBackupPassphrase); !errors.Is(err, test.expected) {
+			t.Errorf("%s: got %v, expected %v", test.name, err, test.expected)
+		}
+	}
+}
+
+func TestBackupStreamWrongPassphrase(t *testing.T) {
+	sealed := encryptBackup(t, testBackupData(100))
+
+	if _, err := decryptBackup(sealed, []byte("wrong")); !errors.Is(err, ErrBackupPassphrase) {
+		t.Fatalf("got %v, expected %v", err, ErrBackupPassphrase)
+	}
+}
+
+func TestBackupStreamIterationBounds(t *testing.T) {
+	sealed := encryptBackup(t, testBackupData(100))
+
+	for _, iterations := range []uint32{0, 1, minBackupKDFIterations - 1, maxBackupKDFIterations + 1, 1<<32 - 1} {
+		changed := append([]byte{}, sealed...)
+		binary.BigEndian.PutUint32(changed[len(backupMagic)+backupSaltLength:], iterations)
+
+		start := time.Now()
+		if _, err := decryptBackup(changed, testBackupPassphrase); err == nil {
+			t.Fatalf("%d iterations were accepted", iterations)
+		}
+		// rejected before the key derivation
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("%d iterations took %s to reject", iterations, elapsed)
+		}
+	}
+}
+
+func flipBit(b []byte, offset int) []byte {
+	changed := append([]byte{}, b...)
+	changed[offset] ^= 1
+	return changed
+}
@@ -0,0 +1,111 @@
+package enpasscli
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// VaultRef : a vault found on disk, described by its vault.json, without unlocking it
+type VaultRef struct {
+	// directory holding vault.enpassdb and vault.json
+	Dir          string
+	Name         string
+	UUID         string
+	Items        int
+	HasKeyfile   bool
+	LastModified time.Time
+	// why vault.json could not be read, only Dir is set then
+	Err error
+}
+
+// OpenOptions : how OpenVaultDir unlocks a vault
+type OpenOptions struct {
+	// empty unless the vault uses a keyfile
+	KeyfilePath string
+	Password    []byte
+}
+
+// DiscoverVaults : find every vault below root, e.g. the Enpass data directory with its Vaults/<uuid> folders.
+// A vault whose vault.json cannot be read is returned with Err set, unreadable directories below root are skipped.
+func DiscoverVaults(root string) ([]VaultRef, error) {
+	var refs []VaultRef
+
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			return nil
+		}
+
+		if info.IsDir() {
+			if path != root && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if info.Name() != vaultInfoFileName {
+			return nil
+		}
+
+		dir := filepath.Dir(path)
+		if _, err := os.Stat(filepath.Join(dir, vaultDatabaseFileName)); err != nil {
+			// a vault.json without a database, e.g. a half synced vault
+			return nil
+		}
+
+		ref, err := ReadVaultRef(dir)
+		if err != nil {
+			ref = VaultRef{Dir: dir, Err: err}
+		}
+
+		refs = append(refs, ref)
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not discover vaults")
+	}
+
+	sort.Slice(refs, func(i, j int) bool {
+		if refs[i].Name != refs[j].Name {
+			return refs[i].Name < refs[j].Name
+		}
+		return refs[i].Dir < refs[j].Dir
+	})
+
+	return refs, nil
+}
+
+// ReadVaultRef : describe the vault stored in dir from its vault.json
+func ReadVaultRef(dir string) (VaultRef, error) {
+	vaultInfo, err := loadVaultInfo(filepath.Join(dir, vaultInfoFileName))
+	if err != nil {
+		return VaultRef{}, errors.Wrapf(err, "vault %s", dir)
+	}
+
+	return VaultRef{
+		Dir:          dir,
+		Name:         vaultInfo.VaultName,
+		UUID:         vaultInfo.VaultUUID,
+		Items:        vaultInfo.VaultNumItems,
+		HasKeyfile:   vaultInfo.HasKeyfile == 1,
+		LastModified: time.Unix(vaultInfo.LastModifiedTime, 0),
+	}, nil
+}
+
+// OpenVaultDir : open the vault stored in dir, see Open
+func OpenVaultDir(dir string, opts OpenOptions) (Vault, error) {
+	vault, err := Open(context.Background(), dir, WithKeyfile(opts.KeyfilePath), WithPassword(opts.Password))
+	if err != nil {
+		return Vault{}, err
+	}
+
+	return *vault, nil
+}
@@ -0,0 +1,140 @@
+package enpasscli
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+const workVaultUUID = "3f1c7a52-9d4e-4b8a-a6f0-1e2d3c4b5a69"
+
+// discoverTree : an Enpass data directory with a primary vault, a second vault, a vault with a corrupt
+// vault.json, a vault.json without a database and a hidden vault
+func discoverTree(t *testing.T) string {
+	t.Helper()
+
+	root := t.TempDir()
+	copyVault := func(dir string, editInfo func(string) string) {
+		dir = filepath.Join(root, dir)
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{vaultDatabaseFileName, vaultInfoFileName} {
+			if err := copyFile(filepath.Join("..", name), filepath.Join(dir, name)); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if editInfo == nil {
+			return
+		}
+
+		path := filepath.Join(dir, vaultInfoFileName)
+		info, err := ioutil.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(editInfo(string(info))), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	copyVault(filepath.Join("Vaults", "primary"), nil)
+	copyVault(filepath.Join("Vaults", workVaultUUID), func(info string) string {
+		info = strings.Replace(info, `"vault_name": "Primary"`, `"vault_name": "Work"`, 1)
+		return strings.Replace(info, `"vault_uuid": "primary"`, `"vault_uuid": "`+workVaultUUID+`"`, 1)
+	})
+	copyVault(filepath.Join("Vaults", "corrupt"), func(string) string { return "{not json" })
+	copyVault(filepath.Join("Vaults", "half-synced"), nil)
+	if err := os.Remove(filepath.Join(root, "Vaults", "half-synced", vaultDatabaseFileName)); err != nil {
+		t.Fatal(err)
+	}
+	copyVault(filepath.Join(".trash", "Vaults", "primary"), nil)
+
+	return root
+}
+
+func TestDiscoverVaults(t *testing.T) {
+	root := discoverTree(t)
+
+	refs, err := DiscoverVaults(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sorted by name, the corrupt vault has none
+	want := []struct {
+		dir, name, uuid string
+		corrupt         bool
+	}{
+		{dir: "corrupt", corrupt: true},
+		{dir: "primary", name: "Primary", uuid: "primary"},
+		{dir: workVaultUUID, name: "Work", uuid: workVaultUUID},
+	}
+	if len(refs) != len(want) {
+		t.Fatalf("found %d vaults, want %d: %+v", len(refs), len(want), refs)
+	}
+
+	for i, w := range want {
+		ref := refs[i]
+		if ref.Dir != filepath.Join(root, "Vaults", w.dir) {
+			t.Errorf("vault %d is in %s, want Vaults/%s", i, ref.Dir, w.dir)
+		}
+		if w.corrupt {
+			if !errors.Is(ref.Err, ErrCorruptVault) {
+				t.Errorf("%s: error %v, want %v", w.dir, ref.Err, ErrCorruptVault)
+			}
+			continue
+		}
+
+		if ref.Err != nil || ref.Name != w.name || ref.UUID != w.uuid || ref.Items != 1 || ref.HasKeyfile {
+			t.Errorf("%s: %+v, want vault %s (%s) with one item", w.dir, ref, w.name, w.uuid)
+		}
+	}
+
+	if _, err := DiscoverVaults(filepath.Join(root, "missing")); err == nil {
+		t.Error("a missing root is not an error")
+	}
+}
+
+func TestOpenVaultDir(t *testing.T) {
+	root := discoverTree(t)
+	vaults := filepath.Join(root, "Vaults")
+
+	tests := []struct {
+		dir      string
+		password string
+		want     error
+	}{
+		{dir: "primary", password: testPassword},
+		{dir: workVaultUUID, password: testPassword},
+		{dir: "primary", password: "notmymasterpassword", want: ErrWrongPassword},
+		{dir: "corrupt", password: testPassword, want: ErrCorruptVault},
+		{dir: "half-synced", password: testPassword, want: ErrVaultNotFound},
+		{dir: "missing", password: testPassword, want: ErrVaultNotFound},
+	}
+
+	for _, tt := range tests {
+		vault, err := OpenVaultDir(filepath.Join(vaults, tt.dir), OpenOptions{Password: []byte(tt.password)})
+		if tt.want != nil {
+			if !errors.Is(err, tt.want) {
+				t.Errorf("%s: error %v, want %v", tt.dir, err, tt.want)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.dir, err)
+			continue
+		}
+
+		items, err := vault.GetItems(context.Background())
+		vault.Close()
+		if err != nil || len(items) != 1 {
+			t.Errorf("%s: %d items, %v, want one", tt.dir, len(items), err)
+		}
+	}
+}
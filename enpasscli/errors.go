@@ -0,0 +1,83 @@
+package enpasscli
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	// ErrWrongPassword : the vault could not be unlocked with the given master password
+	ErrWrongPassword = errors.New("wrong master password")
+	// ErrVaultNotFound : vault.json or vault.enpassdb does not exist
+	ErrVaultNotFound = errors.New("vault not found")
+	// ErrCorruptVault : vault.json or vault.enpassdb is damaged, or not from an Enpass vault
+	ErrCorruptVault = errors.New("vault is damaged or not an Enpass vault")
+	// ErrWrongKeyfile : the keyfile is missing, malformed or does not belong to the vault
+	ErrWrongKeyfile = errors.New("wrong keyfile")
+	// ErrTampered : an encrypted value did not authenticate under its key
+	ErrTampered = errors.New("value failed authentication, the vault may have been tampered with")
+	// ErrItemNotFound : no item matched the query
+	ErrItemNotFound = errors.New("no item found")
+	// ErrAmbiguousMatch : more than one item matched the query
+	ErrAmbiguousMatch = errors.New("ambiguous match")
+	// ErrAttachmentNotFound : no attachment has the given id
+	ErrAttachmentNotFound = errors.New("no such attachment")
+	// ErrFieldNotFound : the item has no field with the given label or type
+	ErrFieldNotFound = errors.New("no such field")
+	// ErrReadOnly : the vault was opened read-only
+	ErrReadOnly = errors.New("vault is open read-only")
+	// ErrBackupPassphrase : an encrypted backup could not be opened with the given passphrase
+	ErrBackupPassphrase = errors.New("wrong backup passphrase")
+	// ErrNewerVault : a restore would replace a vault that changed since the backup, or a different vault
+	ErrNewerVault = errors.New("vault is newer than the backup")
+)
+
+// KeyfileError : describes why a keyfile was rejected, matches ErrWrongKeyfile
+type KeyfileError struct {
+	Path   string
+	Reason string
+}
+
+func (e *KeyfileError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("%s: %s", ErrWrongKeyfile, e.Reason)
+	}
+
+	return fmt.Sprintf("%s %s: %s", ErrWrongKeyfile, e.Path, e.Reason)
+}
+
+func (e *KeyfileError) Unwrap() error {
+	return ErrWrongKeyfile
+}
+
+// AmbiguousMatchError : a query matched more than one item, matches ErrAmbiguousMatch
+type AmbiguousMatchError struct {
+	Query      string
+	Candidates []Item
+}
+
+func (e *AmbiguousMatchError) Error() string {
+	candidates := make([]string, 0, len(e.Candidates))
+	for _, item := range e.Candidates {
+		candidates = append(candidates, fmt.Sprintf("%q (%s)", item.Title, item.UUID))
+	}
+
+	return fmt.Sprintf("%s %q: %s", ErrAmbiguousMatch, e.Query, strings.Join(candidates, ", "))
+}
+
+func (e *AmbiguousMatchError) Unwrap() error {
+	return ErrAmbiguousMatch
+}
+
+// ErrUnsupportedVault : the vault uses a key derivation, cipher or format version with no registered implementation
+type ErrUnsupportedVault struct {
+	KDFAlgo        string
+	EncryptionAlgo string
+	Version        int
+}
+
+func (e *ErrUnsupportedVault) Error() string {
+	return fmt.Sprintf("unsupported vault: kdf_algo %q, encryption_algo %q, version %d", e.KDFAlgo, e.EncryptionAlgo, e.Version)
+}
@@ -0,0 +1,185 @@
+package enpasscli
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Item : a decrypted vault entry, e.g. a login or a credit card
+type Item struct {
+	UUID     string `json:"uuid"`
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle"`
+	// e.g. login, creditcard, identity, note
+	Category string `json:"category"`
+	// template type, e.g. login.default
+	Template string `json:"template"`
+	Note     string `json:"note"`
+	// JSON description of the item icon
+	Icon string `json:"icon"`
+
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Favorite bool `json:"favorite"`
+	Trashed  bool `json:"trashed"`
+	Archived bool `json:"archived"`
+
+	// titles of the folders the item is in
+	Tags []string `json:"tags"`
+
+	Fields []Field `json:"fields"`
+
+	// per-item key used to encrypt the sensitive field values
+	key []byte
+}
+
+// Field : a decrypted item field, e.g. a username or a password
+type Field struct {
+	UID   int    `json:"uid"`
+	Label string `json:"label"`
+	// e.g. username, password, email, url, totp, text
+	Type      string    `json:"type"`
+	Value     string    `json:"value"`
+	Sensitive bool      `json:"sensitive"`
+	Order     int       `json:"order"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+const itemsQuery = `
+SELECT i.uuid, COALESCE(i.title, ''), COALESCE(i.subtitle, ''), COALESCE(i.category, ''),
+       COALESCE(i.template, ''), COALESCE(i.note, ''), COALESCE(i.icon, ''),
+       COALESCE(i.created_at, 0), COALESCE(i.updated_at, 0),
+       i.favorite, i.trashed, i.archived, i.key,
+       f.item_field_uid, f.label, f.value, f.type, f.sensitive, f.orde, f.value_updated_at
+FROM item i
+LEFT JOIN itemfield f ON f.item_uuid = i.uuid AND f.deleted = 0
+WHERE i.deleted = 0
+ORDER BY i.ID, f.orde;`
+
+const tagsQuery = `
+SELECT fi.item_uuid, f.title
+FROM folder_items fi
+JOIN folder f ON f.uuid = fi.folder_uuid
+WHERE fi.deleted = 0 AND f.deleted = 0
+ORDER BY f.title;`
+
+// GetItems : return all non-deleted items of the vault with their fields decrypted
+func (v *Vault) GetItems(ctx context.Context) ([]Item, error) {
+	rows, err := v.db.QueryContext(ctx, itemsQuery)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not retrieve items")
+	}
+	defer rows.Close()
+
+	var items []Item
+	var cipher *itemCipher
+
+	for rows.Next() {
+		var item Item
+		var createdAt, updatedAt int64
+		var field struct {
+			uid       sql.NullInt64
+			label     sql.NullString
+			value     sql.NullString
+			fieldType sql.NullString
+			sensitive sql.NullBool
+			order     sql.NullInt64
+			updatedAt sql.NullInt64
+		}
+
+		if err := rows.Scan(
+			&item.UUID, &item.Title, &item.Subtitle, &item.Category, &item.Template, &item.Note, &item.Icon,
+			&createdAt, &updatedAt, &item.Favorite, &item.Trashed, &item.Archived, &item.key,
+			&field.uid, &field.label, &field.value, &field.fieldType, &field.sensitive, &field.order, &field.updatedAt,
+		); err != nil {
+			return nil, errors.Wrap(err, "could not read item")
+		}
+
+		if len(items) == 0 || items[len(items)-1].UUID != item.UUID {
+			item.CreatedAt = time.Unix(createdAt, 0)
+			item.UpdatedAt = time.Unix(updatedAt, 0)
+			items = append(items, item)
+			cipher = nil
+		}
+
+		current := &items[len(items)-1]
+
+		// item without any fields
+		if !field.uid.Valid {
+			continue
+		}
+
+		value := field.value.String
+		if field.sensitive.Bool && value != "" {
+			if cipher == nil {
+				if cipher, err = newItemCipher(current.UUID, current.key); err != nil {
+					return nil, errors.Wrapf(err, "could not load key of item %s", current.UUID)
+				}
+			}
+
+			if value, err = cipher.decrypt(value); err != nil {
+				return nil, errors.Wrapf(err, "could not decrypt field %d of item %s", field.uid.Int64, current.UUID)
+			}
+		}
+
+		current.Fields = append(current.Fields, Field{
+			UID:       int(field.uid.Int64),
+			Label:     field.label.String,
+			Type:      field.fieldType.String,
+			Value:     value,
+			Sensitive: field.sensitive.Bool,
+			Order:     int(field.order.Int64),
+			UpdatedAt: fieldUpdatedAt(field.updatedAt),
+		})
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, errors.Wrap(err, "could not retrieve items")
+	}
+
+	if err := v.fillTags(ctx, items); err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// fieldUpdatedAt : the zero time when the field has no value_updated_at, rather than 1970
+func fieldUpdatedAt(updatedAt sql.NullInt64) time.Time {
+	if !updatedAt.Valid || updatedAt.Int64 == 0 {
+		return time.Time{}
+	}
+
+	return time.Unix(updatedAt.Int64, 0)
+}
+
+// fillTags : Enpass tags are folders, linked to items in folder_items
+func (v *Vault) fillTags(ctx context.Context, items []Item) error {
+	byUUID := make(map[string]*Item, len(items))
+	for i := range items {
+		byUUID[items[i].UUID] = &items[i]
+	}
+
+	rows, err := v.db.QueryContext(ctx, tagsQuery)
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve tags")
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var itemUUID, tag string
+		if err := rows.Scan(&itemUUID, &tag); err != nil {
+			return errors.Wrap(err, "could not read tag")
+		}
+
+		if item, ok := byUUID[itemUUID]; ok {
+			item.Tags = append(item.Tags, tag)
+		}
+	}
+
+	return errors.Wrap(rows.Err(), "could not retrieve tags")
+}
@@ -0,0 +1,39 @@
+package enpasscli
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestGetItemsFieldUpdatedAt(t *testing.T) {
+	vault := openTestVault(t)
+
+	// field 10 was never changed, field 11 has no change time at all, field 12 was changed in 2021
+	if _, err := vault.db.Exec(`
+UPDATE itemfield SET value_updated_at = CASE item_field_uid WHEN 10 THEN 0 WHEN 11 THEN NULL ELSE 1620000000 END
+WHERE item_uuid = ?;`, testItemUUID); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := vault.FindItem(context.Background(), testItemUUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checked := 0
+	for _, field := range item.Fields {
+		want := time.Unix(1620000000, 0)
+		if field.UID == 10 || field.UID == 11 {
+			want = time.Time{}
+		}
+
+		if !field.UpdatedAt.Equal(want) || field.UpdatedAt.IsZero() != want.IsZero() {
+			t.Errorf("field %d: UpdatedAt = %v, want %v", field.UID, field.UpdatedAt, want)
+		}
+		checked++
+	}
+	if checked < 3 {
+		t.Fatalf("item has %d fields, want at least 3", checked)
+	}
+}
@@ -0,0 +1,105 @@
+package enpasscli
+
+import (
+	"crypto/aes"
+	cryptocipher "crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// item.key : AES-256 key followed by the GCM nonce
+	itemKeyLength   = 32
+	itemNonceLength = 12
+)
+
+// itemCipher : the key schedule of one item, used for all of its sensitive field values
+type itemCipher struct {
+	aead  cryptocipher.AEAD
+	nonce []byte
+	// the raw bytes of the item uuid are authenticated along with every value
+	additionalData []byte
+}
+
+// newItemCipher : build the AES-256-GCM cipher from the item.key column
+func newItemCipher(itemUUID string, itemKey []byte) (*itemCipher, error) {
+	if len(itemKey) != itemKeyLength+itemNonceLength {
+		return nil, errors.Errorf(
+			"item key is %d bytes long, expected %d", len(itemKey), itemKeyLength+itemNonceLength,
+		)
+	}
+
+	additionalData, err := hex.DecodeString(strings.Replace(itemUUID, "-", "", -1))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode item uuid")
+	}
+
+	block, err := aes.NewCipher(itemKey[:itemKeyLength])
+	if err != nil {
+		return nil, errors.Wrap(err, "could not initialize item cipher")
+	}
+
+	aead, err := cryptocipher.NewGCMWithNonceSize(block, itemNonceLength)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not initialize item cipher")
+	}
+
+	return &itemCipher{
+		aead:           aead,
+		nonce:          itemKey[itemKeyLength:],
+		additionalData: additionalData,
+	}, nil
+}
+
+// decrypt : values are stored as hex encoded ciphertext followed by the GCM tag
+func (c *itemCipher) decrypt(value string) (string, error) {
+	ciphertext, err := hex.DecodeString(value)
+	if err != nil {
+		return "", errors.Wrap(err, "could not decode field value")
+	}
+
+	plaintext, err := c.open(ciphertext)
+	if err != nil {
+		return "", err
+	}
+
+	return string(plaintext), nil
+}
+
+// open : authenticate and decrypt raw ciphertext followed by the GCM tag
+func (c *itemCipher) open(ciphertext []byte) ([]byte, error) {
+	if len(ciphertext) < c.aead.Overhead() {
+		return nil, errors.Errorf("ciphertext is too short to hold a %d byte tag", c.aead.Overhead())
+	}
+
+	plaintext, err := c.aead.Open(nil, c.nonce, ciphertext, c.additionalData)
+	if err != nil {
+		return nil, ErrTampered
+	}
+
+	return plaintext, nil
+}
+
+// newItemKey : a fresh random item.key, for a new item or for every update of one
+func newItemKey() ([]byte, error) {
+	key := make([]byte, itemKeyLength+itemNonceLength)
+	if _, err := rand.Read(key); err != nil {
+		return nil, errors.Wrap(err, "could not generate item key")
+	}
+
+	return key, nil
+}
+
+// encrypt : the inverse of decrypt. Enpass uses the item nonce for every value of the item, so a key must
+// never seal two versions of a value: UpdateItem writes every item with a new key.
+func (c *itemCipher) encrypt(value string) string {
+	return hex.EncodeToString(c.seal([]byte(value)))
+}
+
+// seal : the inverse of open
+func (c *itemCipher) seal(plaintext []byte) []byte {
+	return c.aead.Seal(nil, c.nonce, plaintext, c.additionalData)
+}
@@ -0,0 +1,131 @@
+package enpasscli
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"encoding/hex"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+const testItemUUID = "9b07477b-5da3-4242-b6de-d2f9d123ceeb"
+
+// errAny : in a test table, any error will do
+var errAny = errors.New("any error")
+
+// testItemKey : a fixed item.key, 32 bytes of key followed by a 12 byte nonce
+func testItemKey() []byte {
+	key := make([]byte, itemKeyLength+itemNonceLength)
+	for i := range key {
+		key[i] = byte(i)
+	}
+
+	return key
+}
+
+// sealWithGCM : value sealed the way Enpass stores it, built with crypto/cipher directly
+func sealWithGCM(t *testing.T, itemUUID string, itemKey []byte, value string) string {
+	t.Helper()
+
+	block, err := aes.NewCipher(itemKey[:32])
+	if err != nil {
+		t.Fatal(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	additionalData, err := hex.DecodeString(strings.Replace(itemUUID, "-", "", -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return hex.EncodeToString(aead.Seal(nil, itemKey[32:], []byte(value), additionalData))
+}
+
+func TestNewItemCipherKeyLength(t *testing.T) {
+	for _, length := range []int{0, 12, 32, 43, 45, 64} {
+		if _, err := newItemCipher(testItemUUID, make([]byte, length)); err == nil {
+			t.Errorf("%d byte item key accepted", length)
+		}
+	}
+
+	if _, err := newItemCipher(testItemUUID, testItemKey()); err != nil {
+		t.Errorf("44 byte item key rejected: %v", err)
+	}
+	if _, err := newItemCipher("not-a-uuid", testItemKey()); err == nil {
+		t.Error("item uuid that is not hex accepted")
+	}
+}
+
+func TestItemCipherDecrypt(t *testing.T) {
+	sealed := sealWithGCM(t, testItemUUID, testItemKey(), "mypassword")
+
+	otherKey := testItemKey()
+	otherKey[0] ^= 1
+	otherNonce := testItemKey()
+	otherNonce[itemKeyLength] ^= 1
+	flipped := []byte(sealed)
+	flipped[0] ^= 1
+
+	tests := []struct {
+		name     string
+		itemUUID string
+		itemKey  []byte
+		value    string
+		want     string
+		wantErr  error
+	}{
+		{name: "sealed value", itemUUID: testItemUUID, itemKey: testItemKey(), value: sealed, want: "mypassword"},
+		{name: "empty value", itemUUID: testItemUUID, itemKey: testItemKey(), value: sealWithGCM(t, testItemUUID, testItemKey(), "")},
+		{name: "upper case hex", itemUUID: testItemUUID, itemKey: testItemKey(), value: strings.ToUpper(sealed), want: "mypassword"},
+		{name: "uuid is the additional data", itemUUID: "00000000-5da3-4242-b6de-d2f9d123ceeb", itemKey: testItemKey(), value: sealed, wantErr: ErrTampered},
+		{name: "other key", itemUUID: testItemUUID, itemKey: otherKey, value: sealed, wantErr: ErrTampered},
+		{name: "other nonce", itemUUID: testItemUUID, itemKey: otherNonce, value: sealed, wantErr: ErrTampered},
+		{name: "changed ciphertext", itemUUID: testItemUUID, itemKey: testItemKey(), value: string(flipped), wantErr: ErrTampered},
+		{name: "tag cut off", itemUUID: testItemUUID, itemKey: testItemKey(), value: sealed[:len(sealed)-2], wantErr: ErrTampered},
+		{name: "shorter than the tag", itemUUID: testItemUUID, itemKey: testItemKey(), value: sealed[:30], wantErr: errAny},
+		{name: "not hex", itemUUID: testItemUUID, itemKey: testItemKey(), value: "mypassword", wantErr: errAny},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, err := newItemCipher(tt.itemUUID, tt.itemKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := c.decrypt(tt.value)
+			switch {
+			case tt.wantErr == nil && err != nil:
+				t.Fatalf("decrypt: %v", err)
+			case tt.wantErr != nil && err == nil:
+				t.Fatalf("decrypt = %q, want an error", got)
+			case tt.wantErr != nil && tt.wantErr != errAny && !errors.Is(err, tt.wantErr):
+				t.Fatalf("decrypt error %v, want %v", err, tt.wantErr)
+			case tt.wantErr == nil && got != tt.want:
+				t.Fatalf("decrypt = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestItemCipherEncryptRoundTrip(t *testing.T) {
+	c, err := newItemCipher(testItemUUID, testItemKey())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Enpass opens what encrypt writes, so it has to match the stored format exactly
+	if got, want := c.encrypt("mypassword"), sealWithGCM(t, testItemUUID, testItemKey(), "mypassword"); got != want {
+		t.Errorf("encrypt = %s, want %s", got, want)
+	}
+
+	plaintext, err := c.open(c.seal([]byte{0, 1, 2}))
+	if err != nil || !bytes.Equal(plaintext, []byte{0, 1, 2}) {
+		t.Errorf("open(seal) = %v, %v", plaintext, err)
+	}
+}
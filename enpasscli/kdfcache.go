@@ -0,0 +1,86 @@
+package enpasscli
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"sync"
+)
+
+// KDFCache : derived keys in memory, for services that open the same vault again and again.
+// Entries are found by an HMAC of the secret under a random key of the cache, the secret itself is not kept.
+type KDFCache struct {
+	mu      sync.Mutex
+	hmacKey []byte
+	keys    map[string][]byte
+}
+
+// NewKDFCache : an empty cache
+func NewKDFCache() *KDFCache {
+	hmacKey := make([]byte, sha256.Size)
+	if _, err := rand.Read(hmacKey); err != nil {
+		// without randomness nothing is cached, every Open derives the key
+		hmacKey = nil
+	}
+
+	return &KDFCache{hmacKey: hmacKey, keys: make(map[string][]byte)}
+}
+
+// Purge : wipe and forget every cached key
+func (c *KDFCache) Purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for id, key := range c.keys {
+		wipeBytes(key)
+		delete(c.keys, id)
+	}
+}
+
+func (c *KDFCache) id(algo string, secret []byte, salt []byte, params KDFParams) string {
+	mac := hmac.New(sha256.New, c.hmacKey)
+
+	// length prefixes, so no two inputs share an encoding
+	for _, part := range [][]byte{[]byte(algo), salt, secret} {
+		var length [8]byte
+		binary.BigEndian.PutUint64(length[:], uint64(len(part)))
+		mac.Write(length[:])
+		mac.Write(part)
+	}
+
+	var iterations [8]byte
+	binary.BigEndian.PutUint64(iterations[:], uint64(params.Iterations))
+	mac.Write(iterations[:])
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// get : a copy of the cached key, the caller may wipe it
+func (c *KDFCache) get(algo string, secret []byte, salt []byte, params KDFParams) ([]byte, bool) {
+	if c == nil || c.hmacKey == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key, ok := c.keys[c.id(algo, secret, salt, params)]
+	if !ok {
+		return nil, false
+	}
+
+	return append([]byte{}, key...), true
+}
+
+func (c *KDFCache) put(algo string, secret []byte, salt []byte, params KDFParams, key []byte) {
+	if c == nil || c.hmacKey == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.keys[c.id(algo, secret, salt, params)] = append([]byte{}, key...)
+}
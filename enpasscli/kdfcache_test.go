@@ -0,0 +1,166 @@
+package enpasscli
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// countDerivations : count the keys derived for vaults with kdf_algo pbkdf2 until the test ends
+func countDerivations(t *testing.T) *int {
+	t.Helper()
+
+	registryMu.RLock()
+	kdf := kdfs["pbkdf2"]
+	registryMu.RUnlock()
+	t.Cleanup(func() { RegisterKDF("pbkdf2", kdf) })
+
+	var derivations int
+	RegisterKDF("pbkdf2", func(ctx context.Context, secret []byte, salt []byte, params KDFParams) ([]byte, error) {
+		derivations++
+		return kdf(ctx, secret, salt, params)
+	})
+
+	return &derivations
+}
+
+func TestPBKDF2Key(t *testing.T) {
+	// RFC 6070
+	key, err := pbkdf2Key(context.Background(), []byte("password"), []byte("salt"), 4096, 20, sha1.New)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := hex.EncodeToString(key), "4b007901b765489abead49d926f721d065a429c1"; got != want {
+		t.Errorf("derived %s, want %s", got, want)
+	}
+}
+
+func TestPBKDF2KeyCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		_, err := pbkdf2Key(ctx, []byte(testPassword), []byte("salt"), 1<<40, masterKeyLength, sha1.New)
+		done <- err
+	}()
+
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("error %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("derivation went on after the context was canceled")
+	}
+}
+
+func TestOpenKDFCache(t *testing.T) {
+	ctx := context.Background()
+	derivations := countDerivations(t)
+	cache := NewKDFCache()
+	dir := copyTestVault(t)
+
+	open := func(password string) error {
+		vault, err := Open(ctx, dir, WithPassword([]byte(password)), WithKDFCache(cache))
+		if err != nil {
+			return err
+		}
+
+		_, err = vault.FindItem(ctx, testItemUUID)
+		vault.Close()
+		return err
+	}
+
+	tests := []struct {
+		name     string
+		password string
+		want     error
+		// derivations so far
+		derived int
+	}{
+		{name: "first open", password: testPassword, derived: 1},
+		{name: "second open", password: testPassword, derived: 1},
+		// keys that do not unlock the vault are not cached
+		{name: "wrong password", password: "notmymasterpassword", want: ErrWrongPassword, derived: 2},
+		{name: "wrong password again", password: "notmymasterpassword", want: ErrWrongPassword, derived: 3},
+		{name: "third open", password: testPassword, derived: 3},
+	}
+
+	for _, tt := range tests {
+		if err := open(tt.password); !errors.Is(err, tt.want) {
+			t.Fatalf("%s: error %v, want %v", tt.name, err, tt.want)
+		}
+		if *derivations != tt.derived {
+			t.Errorf("%s: %d keys derived, want %d", tt.name, *derivations, tt.derived)
+		}
+	}
+
+	cache.Purge()
+	if err := open(testPassword); err != nil {
+		t.Fatal(err)
+	}
+	if *derivations != 4 {
+		t.Errorf("open after Purge: %d keys derived, want 4", *derivations)
+	}
+
+	// without a cache every open derives the key
+	vault, err := Open(ctx, dir, WithPassword([]byte(testPassword)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vault.Close()
+	if *derivations != 5 {
+		t.Errorf("open without a cache: %d keys derived, want 5", *derivations)
+	}
+}
+
+func TestKDFCacheKey(t *testing.T) {
+	cache := NewKDFCache()
+	params := KDFParams{Iterations: 100000}
+	key := []byte("0123456789abcdef0123456789abcdef")
+	cache.put("pbkdf2", []byte(testPassword), []byte("salt"), params, key)
+
+	cached, ok := cache.get("pbkdf2", []byte(testPassword), []byte("salt"), params)
+	if !ok || !bytes.Equal(cached, key) {
+		t.Fatalf("cached %x, %v, want %x", cached, ok, key)
+	}
+
+	// the cache hands out copies
+	wipeBytes(cached)
+	if cached, _ := cache.get("pbkdf2", []byte(testPassword), []byte("salt"), params); !bytes.Equal(cached, key) {
+		t.Errorf("wiping a cached key changed the cache to %x", cached)
+	}
+
+	misses := []struct {
+		name     string
+		algo     string
+		password string
+		salt     string
+		params   KDFParams
+	}{
+		{name: "other password", algo: "pbkdf2", password: "notmymasterpassword", salt: "salt", params: params},
+		{name: "other salt", algo: "pbkdf2", password: testPassword, salt: "pepper", params: params},
+		{name: "other iterations", algo: "pbkdf2", password: testPassword, salt: "salt", params: KDFParams{Iterations: 200000}},
+		{name: "other algorithm", algo: "pbkdf2-sha256", password: testPassword, salt: "salt", params: params},
+		// the lengths are part of the entry, so moving bytes from the salt to the password is another entry
+		{name: "shifted salt", algo: "pbkdf2", password: "t" + testPassword, salt: "sal", params: params},
+	}
+	for _, miss := range misses {
+		if cached, ok := cache.get(miss.algo, []byte(miss.password), []byte(miss.salt), miss.params); ok {
+			t.Errorf("%s: found %x in the cache", miss.name, cached)
+		}
+	}
+
+	var none *KDFCache
+	none.put("pbkdf2", []byte(testPassword), []byte("salt"), params, key)
+	if _, ok := none.get("pbkdf2", []byte(testPassword), []byte("salt"), params); ok {
+		t.Error("a nil cache returned a key")
+	}
+}
@@ -2,24 +2,152 @@ package enpasscli
 
 import (
 	"bufio"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"crypto/sha512"
+	"encoding/binary"
+	"hash"
 	"os"
+	"sync"
+	"time"
 
 	"github.com/pkg/errors"
-	"golang.org/x/crypto/pbkdf2"
 )
 
 const (
-	// current key derivation algo
-	keyDerivationAlgo = "pbkdf2"
-	// current database encryption algo
-	dbEncryptionAlgo = "aes-256-cbc"
 	// database key salt length
 	saltLength = 16
 	// length of the database master key (capped)
 	masterKeyLength = 64
+	// PBKDF2 rounds between checks whether the caller gave up
+	kdfCheckInterval = 1000
 )
 
+// KDFParams : the key derivation settings from vault.json
+type KDFParams struct {
+	Iterations int
+}
+
+// KDF : derives the database key from the unlock secret, the master password followed by the keyfile key.
+// It should give up with ctx.Err() once ctx is done.
+type KDF func(ctx context.Context, secret []byte, salt []byte, params KDFParams) ([]byte, error)
+
+// CipherParams : the SQLCipher settings a vault database was written with
+type CipherParams struct {
+	// SQLCipher major version whose defaults apply, 3 or 4
+	Compatibility int
+	// page size in bytes, the default of Compatibility when zero
+	PageSize int
+}
+
+type cipherVersion struct {
+	algo    string
+	version int
+}
+
+var (
+	registryMu sync.RWMutex
+
+	// keyed by vault.json kdf_algo, Argon2id can be added with RegisterKDF
+	kdfs = map[string]KDF{
+		// Enpass 6 writes PBKDF2-HMAC-SHA512 as plain "pbkdf2"
+		"pbkdf2":        pbkdf2KDF(sha512.New, sha512.Size),
+		"pbkdf2-sha512": pbkdf2KDF(sha512.New, sha512.Size),
+		"pbkdf2-sha256": pbkdf2KDF(sha256.New, sha256.Size),
+	}
+
+	// keyed by vault.json encryption_algo and version
+	ciphers = map[cipherVersion]CipherParams{
+		{"aes-256-cbc", 6}: {Compatibility: 3, PageSize: 1024},
+	}
+)
+
+// RegisterKDF : make vaults with kdf_algo name openable, replacing any KDF registered under it
+func RegisterKDF(name string, kdf KDF) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	kdfs[name] = kdf
+}
+
+// RegisterCipher : the SQLCipher settings of vaults with encryption_algo algo and the given version
+func RegisterCipher(algo string, version int, params CipherParams) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	ciphers[cipherVersion{algo, version}] = params
+}
+
+// lookupScheme : the KDF and SQLCipher settings for a vault, ErrUnsupportedVault for unknown combinations
+func lookupScheme(info VaultInfo) (KDF, CipherParams, error) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	kdf, kdfOK := kdfs[info.KDFAlgo]
+	params, cipherOK := ciphers[cipherVersion{info.EncryptionAlgo, info.VaultVersion}]
+	if !kdfOK || !cipherOK {
+		return nil, CipherParams{}, &ErrUnsupportedVault{
+			KDFAlgo:        info.KDFAlgo,
+			EncryptionAlgo: info.EncryptionAlgo,
+			Version:        info.VaultVersion,
+		}
+	}
+
+	return kdf, params, nil
+}
+
+func pbkdf2KDF(h func() hash.Hash, keyLength int) KDF {
+	return func(ctx context.Context, secret []byte, salt []byte, params KDFParams) ([]byte, error) {
+		if params.Iterations <= 0 {
+			return nil, errors.Errorf("invalid key derivation iteration count %d", params.Iterations)
+		}
+
+		return pbkdf2Key(ctx, secret, salt, params.Iterations, keyLength, h)
+	}
+}
+
+// pbkdf2Key : RFC 8018 PBKDF2 like x/crypto/pbkdf2, but checking ctx between rounds
+func pbkdf2Key(ctx context.Context, password []byte, salt []byte, iterations int, keyLength int, h func() hash.Hash) ([]byte, error) {
+	prf := hmac.New(h, password)
+	hashLength := prf.Size()
+	blocks := (keyLength + hashLength - 1) / hashLength
+
+	var index [4]byte
+	key := make([]byte, 0, blocks*hashLength)
+	u := make([]byte, hashLength)
+	defer wipeBytes(u)
+
+	for block := 1; block <= blocks; block++ {
+		prf.Reset()
+		prf.Write(salt)
+		binary.BigEndian.PutUint32(index[:], uint32(block))
+		prf.Write(index[:])
+		key = prf.Sum(key)
+
+		t := key[len(key)-hashLength:]
+		copy(u, t)
+
+		for n := 2; n <= iterations; n++ {
+			if n%kdfCheckInterval == 0 {
+				if err := ctx.Err(); err != nil {
+					wipeBytes(key)
+					return nil, err
+				}
+			}
+
+			prf.Reset()
+			prf.Write(u)
+			u = prf.Sum(u[:0])
+			for i := range u {
+				t[i] ^= u[i]
+			}
+		}
+	}
+
+	return key[:keyLength], nil
+}
+
 // extractSalt : extract the encryption salt stored in the database
 func (v *Vault) extractSalt(databasePath string) ([]byte, error) {
 	f, err := os.OpenFile(databasePath, os.O_RDONLY, 0)
@@ -36,16 +164,25 @@ func (v *Vault) extractSalt(databasePath string) ([]byte, error) {
 	return bytesSalt, nil
 }
 
-// deriveKey : generate the SQLCipher crypto key, possibly with the 64-bit Keyfile
-func (v *Vault) deriveKey(masterPassword []byte, salt []byte) ([]byte, error) {
-	if v.vaultInfo.KDFAlgo != keyDerivationAlgo {
-		return nil, errors.New("key derivation algo has changed, open up a github issue")
+// deriveKey : generate the SQLCipher crypto key with the KDF of the vault, or take it from the KDF cache
+func (v *Vault) deriveKey(ctx context.Context, masterPassword []byte, salt []byte, iterations int) ([]byte, error) {
+	params := KDFParams{Iterations: iterations}
+	if key, ok := v.kdfCache.get(v.vaultInfo.KDFAlgo, masterPassword, salt, params); ok {
+		v.log.Debugf("using cached key of vault %s", v.vaultInfo.VaultName)
+		return key, nil
+	}
+
+	start := time.Now()
+	key, err := v.kdf(ctx, masterPassword, salt, params)
+	if err != nil {
+		return nil, err
 	}
+	v.log.Debugf("derived key with %s and %d iterations in %s", v.vaultInfo.KDFAlgo, iterations, time.Since(start).Round(time.Millisecond))
 
-	if v.vaultInfo.EncryptionAlgo != dbEncryptionAlgo {
-		return nil, errors.New("database encryption algo has changed, open up a github issue")
+	// the raw SQLCipher key is the first masterKeyLength hex characters
+	if len(key)*2 < masterKeyLength {
+		return nil, errors.Errorf("derived key is %d bytes, at least %d are required", len(key), masterKeyLength/2)
 	}
 
-	// PBKDF2- HMAC-SHA256
-	return pbkdf2.Key(masterPassword, salt, v.vaultInfo.KDFIterations, sha512.Size, sha512.New), nil
+	return key, nil
 }
@@ -1,12 +1,22 @@
 package enpasscli
 
 import (
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/xml"
-	"errors"
 	"fmt"
 	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// minimum length of the decoded keyfile key
+	keyfileMinKeyLength = 32
 )
 
+// Keyfile : the XML keyfile Enpass generates, e.g. <Key>a1b2...</Key>
 type Keyfile struct {
 	Key string `xml:",innerxml"`
 }
@@ -14,13 +24,49 @@ type Keyfile struct {
 func loadKeyFile(path string) (*Keyfile, error) {
 	bytes, err := ioutil.ReadFile(path)
 	if err != nil {
-		return nil, errors.New(fmt.Sprintf("could not load keyfile: %v", err))
+		return nil, &KeyfileError{Path: path, Reason: fmt.Sprintf("could not load keyfile: %v", err)}
 	}
 
 	var kf Keyfile
 	if err := xml.Unmarshal(bytes, &kf); err != nil {
-		return nil, errors.New(fmt.Sprintf("could not parse keyfile: %v", err))
+		return nil, &KeyfileError{Path: path, Reason: fmt.Sprintf("could not parse keyfile: %v", err)}
 	}
 
 	return &kf, nil
 }
+
+// decodeKey : return the raw key bytes, the key is either hex or base64 encoded
+func (kf *Keyfile) decodeKey() ([]byte, error) {
+	encoded := strings.TrimSpace(kf.Key)
+	if encoded == "" {
+		return nil, errors.New("keyfile does not contain a key")
+	}
+
+	key, err := hex.DecodeString(encoded)
+	if err != nil {
+		if key, err = base64.StdEncoding.DecodeString(encoded); err != nil {
+			return nil, errors.New("key is neither hex nor base64 encoded")
+		}
+	}
+
+	if len(key) < keyfileMinKeyLength {
+		return nil, errors.Errorf("key is %d bytes long, expected at least %d", len(key), keyfileMinKeyLength)
+	}
+
+	return key, nil
+}
+
+// loadKeyfileKey : load the keyfile at path and return its decoded key
+func loadKeyfileKey(path string) ([]byte, error) {
+	kf, err := loadKeyFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := kf.decodeKey()
+	if err != nil {
+		return nil, &KeyfileError{Path: path, Reason: err.Error()}
+	}
+
+	return key, nil
+}
@@ -0,0 +1,98 @@
+package enpasscli
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestDecodeKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0xa5}, keyfileMinKeyLength)
+	short := key[:keyfileMinKeyLength-1]
+	// valid hex and valid base64 at once, hex is tried first
+	hexAndBase64 := strings.Repeat("ab", keyfileMinKeyLength)
+
+	tests := []struct {
+		name    string
+		encoded string
+		want    []byte
+	}{
+		{name: "hex", encoded: hex.EncodeToString(key), want: key},
+		{name: "upper case hex", encoded: strings.ToUpper(hex.EncodeToString(key)), want: key},
+		{name: "hex in whitespace", encoded: "\n  " + hex.EncodeToString(key) + "\n", want: key},
+		{name: "longer hex", encoded: hex.EncodeToString(append(key, key...)), want: append(key, key...)},
+		{name: "base64", encoded: base64.StdEncoding.EncodeToString(key), want: key},
+		{name: "hex before base64", encoded: hexAndBase64, want: bytes.Repeat([]byte{0xab}, keyfileMinKeyLength)},
+		{name: "short hex", encoded: hex.EncodeToString(short)},
+		{name: "short base64", encoded: base64.StdEncoding.EncodeToString(short)},
+		{name: "neither", encoded: "not a key!"},
+		{name: "empty", encoded: " \n "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := (&Keyfile{Key: tt.encoded}).decodeKey()
+			if tt.want == nil {
+				if err == nil {
+					t.Fatalf("decodeKey = %x, want an error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("decodeKey: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("decodeKey = %x, want %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoadKeyfileKey(t *testing.T) {
+	key := bytes.Repeat([]byte{0x5a}, keyfileMinKeyLength)
+	dir := t.TempDir()
+
+	tests := []struct {
+		name     string
+		contents string
+		want     []byte
+	}{
+		{name: "enpass keyfile", contents: `<?xml version="1.0" encoding="UTF-8"?><Key>` + hex.EncodeToString(key) + `</Key>`, want: key},
+		{name: "short key", contents: `<Key>` + hex.EncodeToString(key[:16]) + `</Key>`},
+		{name: "not xml", contents: hex.EncodeToString(key)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, strings.Replace(tt.name, " ", "-", -1)+".enpasskey")
+			if err := ioutil.WriteFile(path, []byte(tt.contents), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			got, err := loadKeyfileKey(path)
+			if tt.want == nil {
+				var keyfileErr *KeyfileError
+				if !errors.As(err, &keyfileErr) || keyfileErr.Path != path || !errors.Is(err, ErrWrongKeyfile) {
+					t.Fatalf("loadKeyfileKey error %v, want a KeyfileError for %s", err, path)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadKeyfileKey: %v", err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Fatalf("loadKeyfileKey = %x, want %x", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := loadKeyfileKey(filepath.Join(dir, "missing.enpasskey")); !errors.Is(err, ErrWrongKeyfile) {
+		t.Errorf("missing keyfile: error %v, want %v", err, ErrWrongKeyfile)
+	}
+}
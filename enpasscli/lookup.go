@@ -0,0 +1,111 @@
+package enpasscli
+
+import (
+	"context"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// FindItem : resolve a query to one item, by uuid, by exact title or by a unique partial match; items in
+// the trash are only found by uuid
+func (v *Vault) FindItem(ctx context.Context, query string) (Item, error) {
+	items, err := v.GetItems(ctx)
+	if err != nil {
+		return Item{}, err
+	}
+
+	return matchItem(items, query)
+}
+
+// GetField : the value of one field of the item matching query, see FindItem and Item.Field
+func (v *Vault) GetField(query string, fieldLabel string) (string, error) {
+	item, err := v.FindItem(context.Background(), query)
+	if err != nil {
+		return "", err
+	}
+
+	field, err := item.Field(fieldLabel)
+	if err != nil {
+		return "", err
+	}
+
+	return field.Value, nil
+}
+
+func matchItem(items []Item, query string) (Item, error) {
+	var exact, partial []Item
+	lowerQuery := strings.ToLower(query)
+
+	for _, item := range items {
+		if item.UUID == query {
+			return item, nil
+		}
+		if item.Trashed {
+			continue
+		}
+
+		lowerTitle := strings.ToLower(item.Title)
+		if lowerTitle == lowerQuery {
+			exact = append(exact, item)
+		} else if strings.Contains(lowerTitle, lowerQuery) || strings.Contains(strings.ToLower(item.Subtitle), lowerQuery) {
+			partial = append(partial, item)
+		}
+	}
+
+	// an exact title match wins over partial matches
+	candidates := exact
+	if len(candidates) == 0 {
+		candidates = partial
+	}
+
+	switch len(candidates) {
+	case 0:
+		return Item{}, errors.Wrapf(ErrItemNotFound, "%q", query)
+	case 1:
+		return candidates[0], nil
+	default:
+		return Item{}, &AmbiguousMatchError{Query: query, Candidates: candidates}
+	}
+}
+
+// Field : the first field with a value whose label, or else type, equals name (case-insensitive)
+func (i Item) Field(name string) (Field, error) {
+	for _, matchLabel := range []bool{true, false} {
+		for _, field := range i.Fields {
+			key := field.Type
+			if matchLabel {
+				key = field.Label
+			}
+
+			if field.Value != "" && strings.EqualFold(key, name) {
+				return field, nil
+			}
+		}
+	}
+
+	return Field{}, errors.Wrapf(ErrFieldNotFound, "%q in item %q", name, i.Title)
+}
+
+// Name : the label of the field, default fields have none so fall back to the type
+func (f Field) Name() string {
+	if f.Label != "" {
+		return f.Label
+	}
+
+	return f.Type
+}
+
+// String : never print sensitive values when a field ends up in a log line
+func (f Field) String() string {
+	if f.Sensitive {
+		return f.Name() + "=<sensitive>"
+	}
+
+	return f.Name() + "=" + f.Value
+}
+
+// GoString : like String, for %#v
+func (f Field) GoString() string {
+	return "enpasscli.Field{" + f.String() + "}"
+}
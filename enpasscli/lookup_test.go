@@ -0,0 +1,60 @@
+package enpasscli
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestMatchItem(t *testing.T) {
+	items := []Item{
+		{UUID: "u1", Title: "GitHub", Subtitle: "octo"},
+		{UUID: "u2", Title: "GitHub Enterprise", Subtitle: "work"},
+		{UUID: "u3", Title: "Mail", Subtitle: "me@example.com"},
+		{UUID: "u4", Title: "Mail archive", Subtitle: "old@example.com"},
+		{UUID: "u5", Title: "Bank", Subtitle: "me"},
+		{UUID: "u6", Title: "Bank", Subtitle: "joint"},
+		{UUID: "u7", Title: "Old router", Trashed: true},
+		{UUID: "u8", Title: "Mail", Subtitle: "trashed copy", Trashed: true},
+	}
+
+	tests := []struct {
+		name  string
+		query string
+		want  string
+		err   error
+	}{
+		{name: "uuid", query: "u2", want: "u2"},
+		{name: "exact title", query: "github", want: "u1"},
+		{name: "exact title over partial matches", query: "Mail", want: "u3"},
+		{name: "partial title", query: "enterprise", want: "u2"},
+		{name: "partial subtitle", query: "octo", want: "u1"},
+		{name: "ambiguous exact titles", query: "bank", err: ErrAmbiguousMatch},
+		{name: "ambiguous partial matches", query: "example.com", err: ErrAmbiguousMatch},
+		{name: "no match", query: "nothing", err: ErrItemNotFound},
+		{name: "trashed by uuid", query: "u7", want: "u7"},
+		{name: "trashed by title", query: "old router", err: ErrItemNotFound},
+		{name: "trashed by partial title", query: "router", err: ErrItemNotFound},
+		{name: "trashed subtitle", query: "trashed copy", err: ErrItemNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			item, err := matchItem(items, tt.query)
+			if tt.err != nil {
+				if !errors.Is(err, tt.err) {
+					t.Fatalf("matchItem(%q) = %s, %v, want %v", tt.query, item.UUID, err, tt.err)
+				}
+				return
+			}
+			if err != nil || item.UUID != tt.want {
+				t.Fatalf("matchItem(%q) = %s, %v, want %s", tt.query, item.UUID, err, tt.want)
+			}
+		})
+	}
+
+	var ambiguous *AmbiguousMatchError
+	if _, err := matchItem(items, "bank"); !errors.As(err, &ambiguous) || len(ambiguous.Candidates) != 2 {
+		t.Errorf("ambiguous match error %v, want both banks as candidates", err)
+	}
+}
@@ -0,0 +1,62 @@
+package enpasscli
+
+import (
+	"os"
+	"sync"
+	"syscall"
+	"unsafe"
+)
+
+// mlock works on whole pages and does not count, so one munlock unlocks a page for every buffer on it.
+// lockedPages counts the locked buffers on each page, a page is only unlocked once none is left.
+var lockedPages = struct {
+	sync.Mutex
+	count map[uintptr]int
+}{count: make(map[uintptr]int)}
+
+var pageSize = uintptr(os.Getpagesize())
+
+// lockMemory : keep the pages of b out of swap until unlockMemory(b)
+func lockMemory(b []byte) error {
+	first, end := pageRange(b)
+
+	lockedPages.Lock()
+	defer lockedPages.Unlock()
+
+	if _, _, errno := syscall.Syscall(syscall.SYS_MLOCK, first, end-first, 0); errno != 0 {
+		return errno
+	}
+	for page := first; page < end; page += pageSize {
+		lockedPages.count[page]++
+	}
+
+	return nil
+}
+
+// unlockMemory : unlock the pages of b no other locked buffer is on
+func unlockMemory(b []byte) error {
+	first, end := pageRange(b)
+
+	lockedPages.Lock()
+	defer lockedPages.Unlock()
+
+	var err error
+	for page := first; page < end; page += pageSize {
+		if lockedPages.count[page]--; lockedPages.count[page] > 0 {
+			continue
+		}
+		delete(lockedPages.count, page)
+
+		if _, _, errno := syscall.Syscall(syscall.SYS_MUNLOCK, page, pageSize, 0); errno != 0 && err == nil {
+			err = errno
+		}
+	}
+
+	return err
+}
+
+// pageRange : start of the first page of b and end of its last page
+func pageRange(b []byte) (uintptr, uintptr) {
+	start := uintptr(unsafe.Pointer(&b[0]))
+	return start &^ (pageSize - 1), (start + uintptr(len(b)) + pageSize - 1) &^ (pageSize - 1)
+}
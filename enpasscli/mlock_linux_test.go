@@ -0,0 +1,65 @@
+package enpasscli
+
+import (
+	"bufio"
+	"os"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// lockedKB : VmLck of this process
+func lockedKB(t *testing.T) string {
+	t.Helper()
+
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if strings.HasPrefix(scanner.Text(), "VmLck:") {
+			return strings.TrimSpace(strings.TrimPrefix(scanner.Text(), "VmLck:"))
+		}
+	}
+	t.Skip("no VmLck in /proc/self/status")
+	return ""
+}
+
+func TestWipeKeepsSharedPageLocked(t *testing.T) {
+	unlocked := lockedKB(t)
+
+	// two secrets on one page, like the vault key and an attachment key
+	buf := make([]byte, 2*pageSize)
+	offset := pageSize - uintptr(unsafe.Pointer(&buf[0]))&(pageSize-1)
+	first := newSecret(buf[offset:offset+32], true)
+	second := newSecret(buf[offset+64:offset+96], true)
+	if !first.locked || !second.locked {
+		first.wipe()
+		second.wipe()
+		t.Skip("could not lock memory, RLIMIT_MEMLOCK too low?")
+	}
+
+	locked := lockedKB(t)
+	if locked == unlocked {
+		t.Fatalf("VmLck is %s with two locked secrets", locked)
+	}
+
+	first.wipe()
+	if got := lockedKB(t); got != locked {
+		t.Errorf("VmLck is %s after wiping one secret, want %s while the other is on the page", got, locked)
+	}
+	if lockedPages.count[uintptr(unsafe.Pointer(&buf[offset]))] != 1 {
+		t.Errorf("page count is %d, want 1", lockedPages.count[uintptr(unsafe.Pointer(&buf[offset]))])
+	}
+
+	second.wipe()
+	if got := lockedKB(t); got != unlocked {
+		t.Errorf("VmLck is %s after wiping both secrets, want %s", got, unlocked)
+	}
+	if len(lockedPages.count) != 0 {
+		t.Errorf("%d pages still counted", len(lockedPages.count))
+	}
+}
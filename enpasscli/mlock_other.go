@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package enpasscli
+
+import (
+	"github.com/pkg/errors"
+)
+
+func lockMemory(b []byte) error {
+	return errors.New("memory locking is not supported on this platform")
+}
+
+func unlockMemory(b []byte) error {
+	return nil
+}
@@ -0,0 +1,111 @@
+package enpasscli
+
+import (
+	"context"
+	"path/filepath"
+)
+
+// Option : configures how Open unlocks a vault
+type Option func(*openConfig)
+
+// Logger : where Open and the vault report what they are doing, at debug level
+type Logger interface {
+	Debugf(format string, args ...interface{})
+}
+
+// PasswordSource : asked for the master password once Open knows the vault exists and is supported.
+// Open wipes the returned slice after use.
+type PasswordSource func(ctx context.Context) ([]byte, error)
+
+type openConfig struct {
+	keyfilePath       string
+	password          []byte
+	passwordSource    PasswordSource
+	readOnly          bool
+	snapshot          bool
+	lockMemory        bool
+	vaultInfoFilename string
+	log               Logger
+	kdfCache          *KDFCache
+}
+
+type nopLogger struct{}
+
+func (nopLogger) Debugf(string, ...interface{}) {}
+
+// WithKeyfile : the keyfile of a vault that uses one
+func WithKeyfile(path string) Option {
+	return func(c *openConfig) {
+		c.keyfilePath = path
+	}
+}
+
+// WithPassword : the master password, it stays owned by the caller and is not wiped
+func WithPassword(password []byte) Option {
+	return func(c *openConfig) {
+		c.password = password
+		c.passwordSource = nil
+	}
+}
+
+// WithPasswordSource : read the master password only when it is needed, e.g. from a prompt
+func WithPasswordSource(source PasswordSource) Option {
+	return func(c *openConfig) {
+		c.passwordSource = source
+		c.password = nil
+	}
+}
+
+// WithReadOnly : never write to the vault files, not even a journal, and refuse every write with ErrReadOnly
+func WithReadOnly() Option {
+	return func(c *openConfig) {
+		c.readOnly = true
+	}
+}
+
+// WithSnapshot : read-only, on a private copy of the database, so another app can keep writing the vault
+func WithSnapshot() Option {
+	return func(c *openConfig) {
+		c.readOnly = true
+		c.snapshot = true
+	}
+}
+
+// WithLockedMemory : lock the raw vault key into memory so it is never written to swap, only on Linux
+// and within RLIMIT_MEMLOCK. The hex key statements SQLCipher is keyed with are not locked.
+func WithLockedMemory() Option {
+	return func(c *openConfig) {
+		c.lockMemory = true
+	}
+}
+
+// WithVaultInfo : read vault.json from path instead of next to the database
+func WithVaultInfo(path string) Option {
+	return func(c *openConfig) {
+		c.vaultInfoFilename = path
+	}
+}
+
+// WithLogger : report key derivation and database setup to log
+func WithLogger(log Logger) Option {
+	return func(c *openConfig) {
+		c.log = log
+	}
+}
+
+// WithKDFCache : reuse keys derived by an earlier Open with the same cache
+func WithKDFCache(cache *KDFCache) Option {
+	return func(c *openConfig) {
+		c.kdfCache = cache
+	}
+}
+
+// Open : unlock the vault stored in dir. Cancelling ctx stops the key derivation.
+func Open(ctx context.Context, dir string, opts ...Option) (*Vault, error) {
+	config := openConfig{log: nopLogger{}}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	return openVault(ctx, filepath.Join(dir, vaultDatabaseFileName), config)
+}
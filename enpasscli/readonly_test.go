@@ -0,0 +1,120 @@
+package enpasscli
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestReadOnlyRefusesWrites(t *testing.T) {
+	ctx := context.Background()
+
+	for name, option := range map[string]Option{"read-only": WithReadOnly(), "snapshot": WithSnapshot()} {
+		t.Run(name, func(t *testing.T) {
+			dir := copyTestVault(t)
+			database := filepath.Join(dir, vaultDatabaseFileName)
+			before, err := os.Stat(database)
+			if err != nil {
+				t.Fatal(err)
+			}
+			contents, err := ioutil.ReadFile(database)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			vault, err := Open(ctx, dir, WithPassword([]byte(testPassword)), option)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			item, err := vault.FindItem(ctx, testItemUUID)
+			if err != nil {
+				vault.Close()
+				t.Fatal(err)
+			}
+
+			writes := map[string]func() error{
+				"CreateItem": func() error {
+					_, err := vault.CreateItem(ctx, Item{Title: "new"})
+					return err
+				},
+				"UpdateItem": func() error {
+					_, err := vault.UpdateItem(ctx, item)
+					return err
+				},
+				"TrashItem":  func() error { return vault.TrashItem(ctx, item.UUID) },
+				"DeleteItem": func() error { return vault.DeleteItem(ctx, item.UUID) },
+				"ChangeMasterPassword": func() error {
+					return vault.ChangeMasterPassword([]byte(testPassword), []byte(newTestPassword), ChangePasswordOptions{})
+				},
+			}
+			for write, fn := range writes {
+				if err := fn(); !errors.Is(err, ErrReadOnly) {
+					t.Errorf("%s: error %v, want %v", write, err, ErrReadOnly)
+				}
+			}
+			vault.Close()
+
+			after, err := os.Stat(database)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if after.Size() != before.Size() || !after.ModTime().Equal(before.ModTime()) {
+				t.Errorf("database changed from %d bytes at %v to %d bytes at %v",
+					before.Size(), before.ModTime(), after.Size(), after.ModTime())
+			}
+			if now, err := ioutil.ReadFile(database); err != nil || !bytes.Equal(now, contents) {
+				t.Errorf("database contents changed, %v", err)
+			}
+
+			for _, suffix := range []string{"-journal", "-wal", "-shm"} {
+				if _, err := os.Stat(database + suffix); !os.IsNotExist(err) {
+					t.Errorf("%s exists after a read-only open, %v", filepath.Base(database+suffix), err)
+				}
+			}
+		})
+	}
+}
+
+func TestSnapshotReadsWAL(t *testing.T) {
+	ctx := context.Background()
+	dir := copyTestVault(t)
+
+	writer, err := Open(ctx, dir, WithPassword([]byte(testPassword)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	// one connection, so the WAL is not checkpointed into the database while the writer is open
+	writer.db.SetMaxOpenConns(1)
+	if _, err := writer.db.ExecContext(ctx, "PRAGMA journal_mode = WAL;"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := writer.db.ExecContext(ctx, "PRAGMA wal_autocheckpoint = 0;"); err != nil {
+		t.Fatal(err)
+	}
+
+	created, err := writer.CreateItem(ctx, Item{Title: "only in the WAL"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, vaultDatabaseFileName+"-wal")); err != nil {
+		t.Fatalf("no WAL next to the database: %v", err)
+	}
+
+	snapshot, err := Open(ctx, dir, WithPassword([]byte(testPassword)), WithSnapshot())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer snapshot.Close()
+
+	if _, err := snapshot.FindItem(ctx, created.UUID); err != nil {
+		t.Errorf("snapshot misses the item committed to the WAL: %v", err)
+	}
+}
@@ -0,0 +1,227 @@
+package enpasscli
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"os"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// lowest PBKDF2 iteration count ChangeMasterPassword accepts, the Enpass default
+	minKDFIterations = 100000
+	// suffix of the copies ChangeMasterPassword restores when re-keying fails
+	backupSuffix = ".bak"
+)
+
+// afterRekey : nil except in tests, which fail a password change once the database is re-keyed
+var afterRekey func() error
+
+// ChangePasswordOptions : settings for ChangeMasterPassword
+type ChangePasswordOptions struct {
+	// keyfile of the vault, combined with both the old and the new password
+	KeyfilePath string
+	// PBKDF2 iterations of the new key, the current count is kept when zero
+	KDFIterations int
+	// recorded as last_password_changing_device, the hostname when empty
+	Device string
+}
+
+// ChangeMasterPassword : re-key the database under the new password with a new random salt
+func (v *Vault) ChangeMasterPassword(oldPassword []byte, newPassword []byte, opts ChangePasswordOptions) error {
+	if v.readOnly {
+		return ErrReadOnly
+	}
+
+	if len(newPassword) == 0 {
+		return errors.New("empty new master password provided")
+	}
+
+	iterations := opts.KDFIterations
+	if iterations == 0 {
+		iterations = v.vaultInfo.KDFIterations
+	}
+	if iterations < minKDFIterations {
+		return errors.Errorf("at least %d key derivation iterations are required", minKDFIterations)
+	}
+
+	device := opts.Device
+	if device == "" {
+		device, _ = os.Hostname()
+	}
+
+	oldKey, err := v.keyFromPassword(oldPassword, opts.KeyfilePath)
+	if err != nil {
+		return err
+	}
+	// whichever key is not in use by the vault afterwards is wiped
+	defer v.wipeUnused(oldKey)
+
+	oldDB := openSQLCipher(v.databaseFilename, oldKey, v.cipherParams, readWrite)
+	err = checkDatabaseKey(context.Background(), oldDB)
+	oldDB.Close()
+	if err != nil {
+		return errors.Wrap(err, "could not verify the current master password")
+	}
+
+	newSecret, err := generateMasterPassword(newPassword, opts.KeyfilePath)
+	if err != nil {
+		return errors.Wrap(err, "could not generate new vault unlock key")
+	}
+	defer wipeBytes(newSecret)
+
+	newSalt := make([]byte, saltLength)
+	if _, err := rand.Read(newSalt); err != nil {
+		return errors.Wrap(err, "could not generate salt")
+	}
+
+	derived, err := v.deriveKey(context.Background(), newSecret, newSalt, iterations)
+	if err != nil {
+		return errors.Wrap(err, "could not derive new master key")
+	}
+	newKey := v.databaseKey(derived)
+	defer v.wipeUnused(newKey)
+
+	files := []string{v.databaseFilename, v.vaultInfoFilename}
+	if err := backupFiles(files); err != nil {
+		return errors.Wrap(err, "could not back up vault")
+	}
+
+	if err := v.rekey(oldKey, newKey, newSalt, iterations, device); err != nil {
+		if restoreErr := restoreFiles(files); restoreErr != nil {
+			return errors.Wrapf(err, "could not change master password, restoring the backup (%s) failed: %v", backupSuffix, restoreErr)
+		}
+
+		if reopenErr := v.reopen(oldKey); reopenErr != nil {
+			return errors.Wrapf(err, "could not change master password, reopening the vault failed: %v", reopenErr)
+		}
+
+		return errors.Wrap(err, "could not change master password, the vault was restored")
+	}
+
+	removeBackups(files)
+	return nil
+}
+
+// keyFromPassword : the database key for a password, with the current salt and iterations
+func (v *Vault) keyFromPassword(password []byte, keyfilePath string) (*secret, error) {
+	masterPassword, err := generateMasterPassword(password, keyfilePath)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not generate vault unlock key")
+	}
+	defer wipeBytes(masterPassword)
+
+	salt, err := v.extractSalt(v.databaseFilename)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get master password salt")
+	}
+
+	derived, err := v.deriveKey(context.Background(), masterPassword, salt, v.vaultInfo.KDFIterations)
+	if err != nil {
+		return nil, err
+	}
+
+	return v.databaseKey(derived), nil
+}
+
+// rekey : SQLCipher takes a raw key followed by a salt and writes that salt to the file header
+func (v *Vault) rekey(oldKey *secret, newKey *secret, newSalt []byte, iterations int, device string) error {
+	v.db.Close()
+
+	db := openSQLCipher(v.databaseFilename, oldKey, v.cipherParams, readWrite)
+
+	// PRAGMA rekey has to run on the connection that was keyed
+	conn, err := db.Conn(context.Background())
+	if err != nil {
+		db.Close()
+		return errors.Wrap(err, "could not connect to database")
+	}
+
+	_, err = conn.ExecContext(context.Background(), keyStatement("rekey", newKey, newSalt))
+	conn.Close()
+	db.Close()
+	if err != nil {
+		return errors.Wrap(err, "could not re-key database")
+	}
+
+	salt, err := v.extractSalt(v.databaseFilename)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(salt, newSalt) {
+		return errors.New("database salt header was not updated")
+	}
+
+	if err := v.reopen(newKey); err != nil {
+		return err
+	}
+
+	if afterRekey != nil {
+		if err := afterRekey(); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now().Unix()
+	if err := updateVaultInfo(v.vaultInfoFilename, map[string]interface{}{
+		"kdf_iter":                      iterations,
+		"last_password_changed_time":    now,
+		"last_password_changing_device": device,
+	}); err != nil {
+		return err
+	}
+
+	v.vaultInfo.KDFIterations = iterations
+	return nil
+}
+
+// reopen : replace the database handle with one using key, the vault owns key from now on
+func (v *Vault) reopen(key *secret) error {
+	v.db.Close()
+	if v.key != key {
+		v.key.wipe()
+	}
+
+	if err := v.openEncryptedDatabase(v.databaseFilename, key); err != nil {
+		return err
+	}
+
+	return v.checkKey(context.Background())
+}
+
+// wipeUnused : wipe key unless the vault database is keyed with it
+func (v *Vault) wipeUnused(key *secret) {
+	if key != v.key {
+		key.wipe()
+	}
+}
+
+func backupFiles(files []string) error {
+	for _, file := range files {
+		if err := copyFile(file, file+backupSuffix); err != nil {
+			removeBackups(files)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func restoreFiles(files []string) error {
+	for _, file := range files {
+		if err := os.Rename(file+backupSuffix, file); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func removeBackups(files []string) {
+	for _, file := range files {
+		os.Remove(file + backupSuffix)
+	}
+}
@@ -0,0 +1,147 @@
+package enpasscli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const newTestPassword = "mynewmasterpassword"
+
+// noBackupsLeft : fail when ChangeMasterPassword left a backup copy in dir
+func noBackupsLeft(t *testing.T, dir string) {
+	t.Helper()
+
+	backups, err := filepath.Glob(filepath.Join(dir, "*"+backupSuffix))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 0 {
+		t.Errorf("backups left behind: %v", backups)
+	}
+}
+
+func TestChangeMasterPassword(t *testing.T) {
+	dir := copyTestVault(t)
+	vault, err := Open(context.Background(), dir, WithPassword([]byte(testPassword)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now().Unix()
+	err = vault.ChangeMasterPassword([]byte(testPassword), []byte(newTestPassword), ChangePasswordOptions{
+		KDFIterations: minKDFIterations + 1,
+		Device:        "test-device",
+	})
+	if err != nil {
+		vault.Close()
+		t.Fatal(err)
+	}
+	after := time.Now().Unix()
+
+	// the open vault keeps working under the new key
+	if _, err := vault.FindItem(context.Background(), testItemUUID); err != nil {
+		t.Errorf("FindItem after the change: %v", err)
+	}
+	vault.Close()
+	noBackupsLeft(t, dir)
+
+	reopened, err := Open(context.Background(), dir, WithPassword([]byte(newTestPassword)))
+	if err != nil {
+		t.Fatalf("new password: %v", err)
+	}
+	defer reopened.Close()
+	if password, err := reopened.GetField(testItemUUID, "password"); err != nil || password != "mypassword" {
+		t.Errorf("GetField = %q, %v, want the item's password", password, err)
+	}
+
+	if _, err := Open(context.Background(), dir, WithPassword([]byte(testPassword))); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("old password: error %v, want %v", err, ErrWrongPassword)
+	}
+
+	raw, err := ioutil.ReadFile(filepath.Join(dir, vaultInfoFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var info struct {
+		KDFIterations int    `json:"kdf_iter"`
+		ChangedTime   int64  `json:"last_password_changed_time"`
+		ChangedDevice string `json:"last_password_changing_device"`
+	}
+	if err := json.Unmarshal(raw, &info); err != nil {
+		t.Fatal(err)
+	}
+	if info.KDFIterations != minKDFIterations+1 {
+		t.Errorf("kdf_iter = %d, want %d", info.KDFIterations, minKDFIterations+1)
+	}
+	if info.ChangedTime < before || info.ChangedTime > after {
+		t.Errorf("last_password_changed_time = %d, want between %d and %d", info.ChangedTime, before, after)
+	}
+	if info.ChangedDevice != "test-device" {
+		t.Errorf("last_password_changing_device = %q, want test-device", info.ChangedDevice)
+	}
+}
+
+func TestChangeMasterPasswordRestoresOnFailure(t *testing.T) {
+	dir := copyTestVault(t)
+	files := []string{filepath.Join(dir, vaultDatabaseFileName), filepath.Join(dir, vaultInfoFileName)}
+	original := make(map[string][]byte)
+	for _, file := range files {
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		original[file] = contents
+	}
+
+	vault, err := Open(context.Background(), dir, WithPassword([]byte(testPassword)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer vault.Close()
+
+	injected := errors.New("injected failure")
+	afterRekey = func() error { return injected }
+	defer func() { afterRekey = nil }()
+
+	err = vault.ChangeMasterPassword([]byte(testPassword), []byte(newTestPassword), ChangePasswordOptions{Device: "test-device"})
+	if !errors.Is(err, injected) {
+		t.Fatalf("ChangeMasterPassword error %v, want the injected failure", err)
+	}
+
+	for _, file := range files {
+		contents, err := ioutil.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(contents, original[file]) {
+			t.Errorf("%s was not restored", filepath.Base(file))
+		}
+	}
+	noBackupsLeft(t, dir)
+
+	// the open vault is back on the old key
+	if _, err := vault.FindItem(context.Background(), testItemUUID); err != nil {
+		t.Errorf("FindItem after the failure: %v", err)
+	}
+	reopened, err := Open(context.Background(), dir, WithPassword([]byte(testPassword)))
+	if err != nil {
+		t.Fatalf("old password: %v", err)
+	}
+	reopened.Close()
+}
+
+func TestChangeMasterPasswordWrongPassword(t *testing.T) {
+	vault := openTestVault(t)
+
+	err := vault.ChangeMasterPassword([]byte("notmymasterpassword"), []byte(newTestPassword), ChangePasswordOptions{})
+	if !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("error %v, want %v", err, ErrWrongPassword)
+	}
+}
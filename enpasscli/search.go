@@ -0,0 +1,194 @@
+package enpasscli
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// StateFilter : how Search treats trashed or archived items
+type StateFilter int
+
+const (
+	// Exclude : leave the items out, the default
+	Exclude StateFilter = iota
+	// Include : return the items along with the others
+	Include
+	// Only : return nothing but these items
+	Only
+)
+
+// SortOrder : the order of the items returned by Search
+type SortOrder int
+
+const (
+	// SortNone : keep the database order
+	SortNone SortOrder = iota
+	// SortByTitle : case-insensitive title, then uuid
+	SortByTitle
+	// SortByModified : most recently modified first
+	SortByModified
+)
+
+// Query : filters for Search, the zero value matches every item that is neither trashed nor archived
+type Query struct {
+	// case-insensitive substring of the title, subtitle, urls, usernames or note
+	Text string
+	// matched against the same fields as Text, both have to match if both are set
+	Regexp *regexp.Regexp
+
+	// any of these categories, e.g. login, creditcard, identity, note
+	Categories []string
+	// any of these tags (folder titles), case-insensitive
+	Tags []string
+
+	FavoritesOnly bool
+	Trashed       StateFilter
+	Archived      StateFilter
+
+	Sort SortOrder
+}
+
+// category names people tend to use for the Enpass category identifiers
+var categoryAliases = map[string]string{
+	"card":        "creditcard",
+	"credit card": "creditcard",
+	"credit-card": "creditcard",
+	"secure note": "note",
+	"notes":       "note",
+	"logins":      "login",
+}
+
+// NormalizeCategory : map a user supplied category name to the Enpass identifier
+func NormalizeCategory(category string) string {
+	category = strings.ToLower(strings.TrimSpace(category))
+	if alias, ok := categoryAliases[category]; ok {
+		return alias
+	}
+
+	return category
+}
+
+// Search : return the items matching query
+func (v *Vault) Search(ctx context.Context, query Query) ([]Item, error) {
+	items, err := v.GetItems(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return query.Filter(items), nil
+}
+
+// Filter : the items matching the query, sorted according to it
+func (q Query) Filter(items []Item) []Item {
+	lowerText := strings.ToLower(q.Text)
+
+	matches := items[:0:0]
+	for _, item := range items {
+		if q.matchState(item) && q.matchCategory(item) && q.matchTags(item) && q.matchText(item, lowerText) {
+			matches = append(matches, item)
+		}
+	}
+
+	switch q.Sort {
+	case SortByTitle:
+		sort.SliceStable(matches, func(i, j int) bool {
+			ti, tj := strings.ToLower(matches[i].Title), strings.ToLower(matches[j].Title)
+			if ti != tj {
+				return ti < tj
+			}
+			return matches[i].UUID < matches[j].UUID
+		})
+	case SortByModified:
+		sort.SliceStable(matches, func(i, j int) bool {
+			return matches[i].UpdatedAt.After(matches[j].UpdatedAt)
+		})
+	}
+
+	return matches
+}
+
+func (q Query) matchState(item Item) bool {
+	if q.FavoritesOnly && !item.Favorite {
+		return false
+	}
+
+	return matchStateFilter(q.Trashed, item.Trashed) && matchStateFilter(q.Archived, item.Archived)
+}
+
+func matchStateFilter(filter StateFilter, state bool) bool {
+	switch filter {
+	case Include:
+		return true
+	case Only:
+		return state
+	default:
+		return !state
+	}
+}
+
+func (q Query) matchCategory(item Item) bool {
+	if len(q.Categories) == 0 {
+		return true
+	}
+
+	for _, category := range q.Categories {
+		if NormalizeCategory(category) == item.Category {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (q Query) matchTags(item Item) bool {
+	if len(q.Tags) == 0 {
+		return true
+	}
+
+	for _, wanted := range q.Tags {
+		for _, tag := range item.Tags {
+			if strings.EqualFold(wanted, tag) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (q Query) matchText(item Item, lowerText string) bool {
+	if q.Text == "" && q.Regexp == nil {
+		return true
+	}
+
+	textMatched, regexpMatched := q.Text == "", q.Regexp == nil
+	for _, value := range searchableValues(item) {
+		if !textMatched && strings.Contains(strings.ToLower(value), lowerText) {
+			textMatched = true
+		}
+		if !regexpMatched && q.Regexp.MatchString(value) {
+			regexpMatched = true
+		}
+	}
+
+	return textMatched && regexpMatched
+}
+
+// searchableValues : title, subtitle, urls, usernames and note, never sensitive values
+func searchableValues(item Item) []string {
+	values := []string{item.Title, item.Subtitle, item.Note}
+	for _, field := range item.Fields {
+		if field.Sensitive {
+			continue
+		}
+
+		switch field.Type {
+		case "url", "username", "email":
+			values = append(values, field.Value)
+		}
+	}
+
+	return values
+}
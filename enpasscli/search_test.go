@@ -0,0 +1,144 @@
+package enpasscli
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestQueryFilter(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC) }
+	items := []Item{
+		{UUID: "u1", Title: "GitHub", Subtitle: "octo", Category: "login", Tags: []string{"Work"}, Favorite: true, UpdatedAt: day(3),
+			Fields: []Field{
+				{Type: "username", Value: "octocat"},
+				{Type: "url", Value: "https://github.com"},
+				{Type: "password", Value: "hunter2", Sensitive: true},
+			}},
+		{UUID: "u2", Title: "bank", Category: "creditcard", Tags: []string{"Finance"}, UpdatedAt: day(5),
+			Fields: []Field{{Type: "email", Value: "me@example.com"}}},
+		{UUID: "u3", Title: "Recipes", Category: "note", Note: "grandma's pie", UpdatedAt: day(1)},
+		{UUID: "u4", Title: "Bank", Category: "login", Tags: []string{"finance", "Shared"}, UpdatedAt: day(4),
+			Fields: []Field{
+				{Type: "url", Value: "https://bank.example.com"},
+				// sensitive values are never searched, whatever their type
+				{Type: "username", Value: "hidden-user", Sensitive: true},
+			}},
+		{UUID: "u5", Title: "Old router", Category: "login", Trashed: true, UpdatedAt: day(6)},
+		{UUID: "u6", Title: "Old mail", Category: "login", Archived: true, Favorite: true, UpdatedAt: day(2)},
+	}
+
+	tests := []struct {
+		name  string
+		query Query
+		want  string
+	}{
+		{name: "zero query", want: "u1 u2 u3 u4"},
+
+		{name: "text in title", query: Query{Text: "GITHUB"}, want: "u1"},
+		{name: "text in subtitle", query: Query{Text: "Octo"}, want: "u1"},
+		{name: "text in url", query: Query{Text: "example.com"}, want: "u2 u4"},
+		{name: "text in username", query: Query{Text: "octocat"}, want: "u1"},
+		{name: "text in note", query: Query{Text: "pie"}, want: "u3"},
+		{name: "text in a password", query: Query{Text: "hunter2"}, want: ""},
+		{name: "text in a sensitive username", query: Query{Text: "hidden-user"}, want: ""},
+		{name: "text in a trashed item", query: Query{Text: "router"}, want: ""},
+
+		{name: "regexp", query: Query{Regexp: regexp.MustCompile(`^[Bb]ank$`)}, want: "u2 u4"},
+		{name: "regexp on a url", query: Query{Regexp: regexp.MustCompile(`^https://github\.`)}, want: "u1"},
+		{name: "regexp is case-sensitive", query: Query{Regexp: regexp.MustCompile(`^Bank$`)}, want: "u4"},
+		{name: "text and regexp both match", query: Query{Text: "bank", Regexp: regexp.MustCompile(`^https://`)}, want: "u4"},
+		{name: "text and regexp in different items", query: Query{Text: "octo", Regexp: regexp.MustCompile(`pie`)}, want: ""},
+
+		{name: "category", query: Query{Categories: []string{"login"}}, want: "u1 u4"},
+		{name: "category alias", query: Query{Categories: []string{"Credit Card"}}, want: "u2"},
+		{name: "category case and spaces", query: Query{Categories: []string{" NOTE "}}, want: "u3"},
+		{name: "any of the categories", query: Query{Categories: []string{"card", "notes"}}, want: "u2 u3"},
+		{name: "unknown category", query: Query{Categories: []string{"identity"}}, want: ""},
+
+		{name: "tag", query: Query{Tags: []string{"work"}}, want: "u1"},
+		{name: "tag case", query: Query{Tags: []string{"FINANCE"}}, want: "u2 u4"},
+		{name: "any of the tags", query: Query{Tags: []string{"shared", "work"}}, want: "u1 u4"},
+		{name: "unknown tag", query: Query{Tags: []string{"Personal"}}, want: ""},
+
+		{name: "favorites", query: Query{FavoritesOnly: true}, want: "u1"},
+		{name: "archived favorites", query: Query{FavoritesOnly: true, Archived: Include}, want: "u1 u6"},
+
+		{name: "include trashed", query: Query{Trashed: Include}, want: "u1 u2 u3 u4 u5"},
+		{name: "only trashed", query: Query{Trashed: Only}, want: "u5"},
+		{name: "include archived", query: Query{Archived: Include}, want: "u1 u2 u3 u4 u6"},
+		{name: "only archived", query: Query{Archived: Only}, want: "u6"},
+		{name: "only trashed and archived", query: Query{Trashed: Only, Archived: Only}, want: ""},
+		{name: "include both", query: Query{Trashed: Include, Archived: Include}, want: "u1 u2 u3 u4 u5 u6"},
+
+		{name: "combined filters", query: Query{Text: "bank", Categories: []string{"logins"}, Tags: []string{"finance"}}, want: "u4"},
+
+		{name: "sort by title", query: Query{Sort: SortByTitle}, want: "u2 u4 u1 u3"},
+		{name: "sort by title with trashed", query: Query{Sort: SortByTitle, Trashed: Include}, want: "u2 u4 u1 u5 u3"},
+		{name: "sort by modified", query: Query{Sort: SortByModified}, want: "u2 u4 u1 u3"},
+		{name: "sort by modified with archived", query: Query{Sort: SortByModified, Archived: Include}, want: "u2 u4 u1 u6 u3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, item := range tt.query.Filter(items) {
+				got = append(got, item.UUID)
+			}
+			if strings.Join(got, " ") != tt.want {
+				t.Errorf("matched %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if items[0].UUID != "u1" || items[1].UUID != "u2" {
+		t.Error("Filter reordered the items it was given")
+	}
+}
+
+func TestNormalizeCategory(t *testing.T) {
+	tests := map[string]string{
+		"login":        "login",
+		"Logins":       "login",
+		"card":         "creditcard",
+		"credit-card":  "creditcard",
+		" Secure Note": "note",
+		"identity":     "identity",
+		"":             "",
+	}
+
+	for category, want := range tests {
+		if got := NormalizeCategory(category); got != want {
+			t.Errorf("NormalizeCategory(%q) = %q, want %q", category, got, want)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	vault := openTestVault(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		query Query
+		want  int
+	}{
+		{query: Query{}, want: 1},
+		{query: Query{Text: "myusername"}, want: 1},
+		{query: Query{Text: "mypassword"}, want: 0},
+		{query: Query{Categories: []string{"logins"}}, want: 1},
+		{query: Query{Categories: []string{"note"}}, want: 0},
+		{query: Query{Trashed: Only}, want: 0},
+	}
+
+	for _, tt := range tests {
+		items, err := vault.Search(ctx, tt.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(items) != tt.want || (tt.want == 1 && items[0].UUID != testItemUUID) {
+			t.Errorf("Search(%+v) found %d items, want %d", tt.query, len(items), tt.want)
+		}
+	}
+}
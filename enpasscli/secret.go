@@ -0,0 +1,52 @@
+package enpasscli
+
+import (
+	"encoding/hex"
+)
+
+// secret : key material in a buffer of its own, locked into memory if asked to and wiped by wipe
+type secret struct {
+	b      []byte
+	locked bool
+}
+
+// newSecret : take over b, the caller must not keep using it
+func newSecret(b []byte, lock bool) *secret {
+	s := &secret{b: b}
+	if lock && len(b) > 0 {
+		s.locked = lockMemory(b) == nil
+	}
+
+	return s
+}
+
+func (s *secret) bytes() []byte {
+	return s.b
+}
+
+// wipe : zero and release the buffer, safe to call more than once and on nil
+func (s *secret) wipe() {
+	if s == nil {
+		return
+	}
+
+	wipeBytes(s.b)
+	if s.locked {
+		unlockMemory(s.b)
+		s.locked = false
+	}
+	s.b = nil
+}
+
+// keyStatement : PRAGMA name = "x'<hex key><hex salt>'". go-sqlcipher only takes SQL as a string and
+// has no call for sqlite3_key with a byte slice, so the hex key stays in an immutable string, and in
+// the copy the driver hands to SQLite, until that memory is reused. Only the raw key is wiped.
+func keyStatement(name string, key *secret, salt []byte) string {
+	return "PRAGMA " + name + " = \"x'" + hex.EncodeToString(key.bytes()) + hex.EncodeToString(salt) + "'\";"
+}
+
+func wipeBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
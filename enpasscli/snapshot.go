@@ -0,0 +1,99 @@
+package enpasscli
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// copies of a database that changed while it was copied are thrown away and taken again
+const snapshotAttempts = 3
+
+// snapshotSuffixes : the database and the WAL and shared memory files next to it, committed changes
+// may only be in the WAL
+var snapshotSuffixes = []string{"", "-wal", "-shm"}
+
+// fileState : what tells whether a file changed while it was copied
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// snapshotDatabase : copy the database at path into a new private directory, returns the directory and the copy
+func snapshotDatabase(path string) (string, string, error) {
+	dir, err := ioutil.TempDir("", "enpass-snapshot")
+	if err != nil {
+		return "", "", errors.Wrap(err, "could not create snapshot directory")
+	}
+
+	snapshot := filepath.Join(dir, vaultDatabaseFileName)
+	for attempt := 0; attempt < snapshotAttempts; attempt++ {
+		before, err := databaseState(path)
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", "", errors.Wrap(err, "could not snapshot vault database")
+		}
+
+		for _, suffix := range snapshotSuffixes {
+			if _, ok := before[suffix]; !ok {
+				// left over from an earlier attempt
+				os.Remove(snapshot + suffix)
+				continue
+			}
+
+			if err := copyFile(path+suffix, snapshot+suffix); err != nil {
+				os.RemoveAll(dir)
+				return "", "", errors.Wrap(err, "could not snapshot vault database")
+			}
+		}
+
+		after, err := databaseState(path)
+		if err != nil {
+			os.RemoveAll(dir)
+			return "", "", errors.Wrap(err, "could not snapshot vault database")
+		}
+
+		if sameState(before, after) {
+			return dir, snapshot, nil
+		}
+	}
+
+	os.RemoveAll(dir)
+	return "", "", errors.Errorf("vault database kept changing during %d attempts to snapshot it", snapshotAttempts)
+}
+
+// databaseState : the state of the database at path and of those of its WAL files that exist, by suffix
+func databaseState(path string) (map[string]fileState, error) {
+	states := make(map[string]fileState)
+	for _, suffix := range snapshotSuffixes {
+		info, err := os.Stat(path + suffix)
+		if os.IsNotExist(err) && suffix != "" {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		states[suffix] = fileState{size: info.Size(), modTime: info.ModTime()}
+	}
+
+	return states, nil
+}
+
+func sameState(a map[string]fileState, b map[string]fileState) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for suffix, state := range a {
+		other, ok := b[suffix]
+		if !ok || other.size != state.size || !other.modTime.Equal(state.modTime) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,167 @@
+package enpasscli
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// field type of one-time password secrets
+	totpFieldType = "totp"
+	// RFC 6238 defaults, used when the otpauth:// URI does not say otherwise
+	totpDefaultDigits    = 6
+	totpDefaultPeriod    = 30 * time.Second
+	totpDefaultAlgorithm = "SHA1"
+)
+
+var totpAlgorithms = map[string]func() hash.Hash{
+	"SHA1":   sha1.New,
+	"SHA256": sha256.New,
+	"SHA512": sha512.New,
+}
+
+// TOTPParams : the parameters of a time-based one-time password (RFC 6238)
+type TOTPParams struct {
+	Secret []byte
+	// SHA1, SHA256 or SHA512
+	Algorithm string
+	// 6 or 8
+	Digits int
+	Period time.Duration
+}
+
+// ParseTOTP : parse a totp field value, a plain base32 secret or an otpauth://totp/ URI
+func ParseTOTP(value string) (TOTPParams, error) {
+	params := TOTPParams{
+		Algorithm: totpDefaultAlgorithm,
+		Digits:    totpDefaultDigits,
+		Period:    totpDefaultPeriod,
+	}
+
+	value = strings.TrimSpace(value)
+	secret := value
+
+	if strings.HasPrefix(strings.ToLower(value), "otpauth://") {
+		uri, err := url.Parse(value)
+		if err != nil {
+			return TOTPParams{}, errors.Wrap(err, "could not parse otpauth uri")
+		}
+
+		if !strings.EqualFold(uri.Host, "totp") {
+			return TOTPParams{}, errors.Errorf("unsupported one-time password type %q, only totp is supported", uri.Host)
+		}
+
+		query := uri.Query()
+		secret = query.Get("secret")
+
+		if algorithm := query.Get("algorithm"); algorithm != "" {
+			params.Algorithm = strings.ToUpper(algorithm)
+		}
+
+		if digits := query.Get("digits"); digits != "" {
+			if params.Digits, err = strconv.Atoi(digits); err != nil {
+				return TOTPParams{}, errors.Errorf("invalid totp digits %q", digits)
+			}
+		}
+
+		if period := query.Get("period"); period != "" {
+			seconds, err := strconv.Atoi(period)
+			if err != nil || seconds <= 0 {
+				return TOTPParams{}, errors.Errorf("invalid totp period %q", period)
+			}
+			params.Period = time.Duration(seconds) * time.Second
+		}
+	}
+
+	if _, ok := totpAlgorithms[params.Algorithm]; !ok {
+		return TOTPParams{}, errors.Errorf("unsupported totp algorithm %q", params.Algorithm)
+	}
+
+	if params.Digits != 6 && params.Digits != 8 {
+		return TOTPParams{}, errors.Errorf("unsupported number of totp digits %d", params.Digits)
+	}
+
+	// secrets are often shown lower case, grouped with spaces and without padding
+	secret = strings.ToUpper(strings.Replace(strings.Replace(secret, " ", "", -1), "-", "", -1))
+	decoded, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(decoded) == 0 {
+		return TOTPParams{}, errors.New("totp secret is not valid base32")
+	}
+	params.Secret = decoded
+
+	return params, nil
+}
+
+// Code : the one-time password at now and how long it remains valid
+func (p TOTPParams) Code(now time.Time) (string, time.Duration, error) {
+	newHash, ok := totpAlgorithms[p.Algorithm]
+	if !ok {
+		return "", 0, errors.Errorf("unsupported totp algorithm %q", p.Algorithm)
+	}
+
+	// the counter counts whole seconds, a shorter period would divide by zero
+	if p.Period < time.Second || p.Period%time.Second != 0 {
+		return "", 0, errors.Errorf("invalid totp period %s, it has to be a whole number of seconds", p.Period)
+	}
+
+	period := int64(p.Period / time.Second)
+	counter := now.Unix() / period
+	remaining := time.Unix((counter+1)*period, 0).Sub(now)
+
+	var message [8]byte
+	binary.BigEndian.PutUint64(message[:], uint64(counter))
+
+	mac := hmac.New(newHash, p.Secret)
+	mac.Write(message[:])
+	sum := mac.Sum(nil)
+
+	// dynamic truncation, RFC 4226 section 5.3
+	offset := sum[len(sum)-1] & 0x0f
+	binCode := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	modulo := uint32(1)
+	for i := 0; i < p.Digits; i++ {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", p.Digits, binCode%modulo), remaining, nil
+}
+
+// TOTP : the current one-time password of the item's first totp field
+func (i Item) TOTP(now time.Time) (code string, remaining time.Duration, err error) {
+	field, err := i.Field(totpFieldType)
+	if err != nil {
+		return "", 0, err
+	}
+
+	params, err := ParseTOTP(field.Value)
+	if err != nil {
+		return "", 0, errors.Wrapf(err, "could not parse totp of item %q", i.Title)
+	}
+
+	return params.Code(now)
+}
+
+// URI : the otpauth://totp/ form of the parameters, for authenticator apps and other password managers
+func (p TOTPParams) URI(label string) string {
+	query := url.Values{}
+	query.Set("secret", base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(p.Secret))
+	query.Set("algorithm", p.Algorithm)
+	query.Set("digits", strconv.Itoa(p.Digits))
+	query.Set("period", strconv.Itoa(int(p.Period/time.Second)))
+
+	uri := url.URL{Scheme: "otpauth", Host: totpFieldType, Path: "/" + label, RawQuery: query.Encode()}
+	return uri.String()
+}
@@ -0,0 +1,98 @@
+package enpasscli
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B, the secret is the ASCII seed of the length of each hash
+var rfc6238Secrets = map[string][]byte{
+	"SHA1":   []byte("12345678901234567890"),
+	"SHA256": []byte("12345678901234567890123456789012"),
+	"SHA512": []byte("1234567890123456789012345678901234567890123456789012345678901234"),
+}
+
+func TestTOTPCodeRFC6238(t *testing.T) {
+	tests := []struct {
+		unix int64
+		want map[string]string
+	}{
+		{59, map[string]string{"SHA1": "94287082", "SHA256": "46119246", "SHA512": "90693936"}},
+		{1111111109, map[string]string{"SHA1": "07081804", "SHA256": "68084774", "SHA512": "25091201"}},
+		{1111111111, map[string]string{"SHA1": "14050471", "SHA256": "67062674", "SHA512": "99943326"}},
+		{1234567890, map[string]string{"SHA1": "89005924", "SHA256": "91819424", "SHA512": "93441116"}},
+		{2000000000, map[string]string{"SHA1": "69279037", "SHA256": "90698825", "SHA512": "38618901"}},
+		{20000000000, map[string]string{"SHA1": "65353130", "SHA256": "77737706", "SHA512": "47863826"}},
+	}
+
+	for _, tt := range tests {
+		for algorithm, want := range tt.want {
+			now := time.Unix(tt.unix, 0)
+
+			params := TOTPParams{Secret: rfc6238Secrets[algorithm], Algorithm: algorithm, Digits: 8, Period: 30 * time.Second}
+			code, remaining, err := params.Code(now)
+			if err != nil {
+				t.Fatalf("%s at %d: %v", algorithm, tt.unix, err)
+			}
+			if code != want {
+				t.Errorf("%s at %d: code %s, want %s", algorithm, tt.unix, code, want)
+			}
+			if wantRemaining := time.Duration(30-tt.unix%30) * time.Second; remaining != wantRemaining {
+				t.Errorf("%s at %d: remaining %s, want %s", algorithm, tt.unix, remaining, wantRemaining)
+			}
+
+			// six digits are the last six of the eight
+			params.Digits = 6
+			if code, _, _ := params.Code(now); code != want[2:] {
+				t.Errorf("%s at %d: six digit code %s, want %s", algorithm, tt.unix, code, want[2:])
+			}
+		}
+	}
+}
+
+func TestTOTPCodeRejectsPeriod(t *testing.T) {
+	for _, period := range []time.Duration{0, -30 * time.Second, time.Nanosecond, 500 * time.Millisecond, 1500 * time.Millisecond} {
+		params := TOTPParams{Secret: rfc6238Secrets["SHA1"], Algorithm: "SHA1", Digits: 6, Period: period}
+		if code, _, err := params.Code(time.Unix(59, 0)); err == nil {
+			t.Errorf("period %s: code %s, want an error", period, code)
+		}
+	}
+}
+
+func TestParseTOTP(t *testing.T) {
+	// base32 of the RFC 6238 SHA1 secret
+	const secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	tests := []struct {
+		value string
+		want  TOTPParams
+		fails bool
+	}{
+		{value: secret, want: TOTPParams{Algorithm: "SHA1", Digits: 6, Period: 30 * time.Second}},
+		{value: " gezd gnbv gy3t qojq gezd gnbv gy3t qojq ", want: TOTPParams{Algorithm: "SHA1", Digits: 6, Period: 30 * time.Second}},
+		{value: "otpauth://totp/Example:me?secret=" + secret + "&algorithm=sha256&digits=8&period=60", want: TOTPParams{Algorithm: "SHA256", Digits: 8, Period: time.Minute}},
+		{value: "otpauth://totp/Example:me?secret=" + secret + "&period=0", fails: true},
+		{value: "otpauth://totp/Example:me?secret=" + secret + "&digits=7", fails: true},
+		{value: "otpauth://totp/Example:me?secret=" + secret + "&algorithm=MD5", fails: true},
+		{value: "otpauth://hotp/Example:me?secret=" + secret, fails: true},
+		{value: "not base32!", fails: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseTOTP(tt.value)
+		if tt.fails {
+			if err == nil {
+				t.Errorf("ParseTOTP(%q) = %+v, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseTOTP(%q): %v", tt.value, err)
+			continue
+		}
+		if string(got.Secret) != string(rfc6238Secrets["SHA1"]) || got.Algorithm != tt.want.Algorithm ||
+			got.Digits != tt.want.Digits || got.Period != tt.want.Period {
+			t.Errorf("ParseTOTP(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
@@ -1,182 +1,388 @@
 package enpasscli
 
 import (
-	"crypto/aes"
-	cryptocipher "crypto/cipher"
-	"crypto/sha256"
+	"context"
 	"database/sql"
-	"encoding/hex"
+	"database/sql/driver"
 	"fmt"
-	"log"
+	"io"
+	"net/url"
+	"os"
 	"path/filepath"
 
-	_ "github.com/mutecomm/go-sqlcipher/v4"
+	sqlcipher "github.com/mutecomm/go-sqlcipher/v4"
 	"github.com/pkg/errors"
-	"golang.org/x/crypto/pbkdf2"
 )
 
 const (
 	// contains info about your vault
 	vaultInfoFileName = "vault.json"
-	// PBKDF iterations for row key
-	rowKeyIterations = 2
+	// the SQLCipher database of the vault
+	vaultDatabaseFileName = "vault.enpassdb"
+	// first bytes of an unencrypted SQLite database
+	sqliteHeader = "SQLite format 3\x00"
 )
 
+var sqlcipherDriver = &sqlcipher.SQLiteDriver{}
+
 type Vault struct {
-	// vault.enpassdb : SQLCipher database
+	// the vault directory, attachment files are read from here
+	dir string
+
+	// vault.enpassdb : SQLCipher database, a copy in snapshotDir for snapshots
 	databaseFilename string
+	snapshotDir      string
 
 	// vault.json
 	vaultInfoFilename string
 
-	// <uuid>.enpassattach : SQLCipher database files for attachments >1KB
-	attachments []string
-
 	// pointer to our opened database
 	db *sql.DB
 
 	// vault.json : contains info about your vault for synchronizing
 	vaultInfo VaultInfo
-}
 
-func (v *Vault) openEncryptedDatabase(path string, dbKey []byte) (err error) {
-	dbName := fmt.Sprintf(
-		"%s?_pragma_key=x'%s'&_pragma_cipher_page_size=4096&_pragma_cipher_compatibility=3",
-		path,
-		hex.EncodeToString(dbKey),
-	)
+	// key derivation and SQLCipher settings for kdf_algo, encryption_algo and version of vault.json
+	kdf          KDF
+	cipherParams CipherParams
 
-	v.db, err = sql.Open("sqlite3", dbName)
-	if err != nil {
-		return errors.Wrap(err, "could not open database")
-	}
+	// the raw SQLCipher key of db, wiped by Close, the hex copies in key statements are not
+	key *secret
+	// lock key material into memory, so it is not swapped out
+	lockMemory bool
+
+	// writes fail with ErrReadOnly
+	readOnly bool
 
+	log      Logger
+	kdfCache *KDFCache
+}
+
+func (v *Vault) openEncryptedDatabase(path string, key *secret) error {
+	v.db = openSQLCipher(path, key, v.cipherParams, v.databaseMode())
+	v.key = key
 	return nil
 }
 
-func generateMasterPassword(password []byte, keyfilePath string) ([]byte, error) {
-	if keyfilePath == "" {
-		if password == nil || len(password) == 0 {
-			return nil, errors.New("empty master password provided")
-		}
+// databaseKey : the raw SQLCipher key is the first 32 bytes of the derived key, which is wiped
+func (v *Vault) databaseKey(derived []byte) *secret {
+	key := make([]byte, masterKeyLength/2)
+	copy(key, derived)
+	wipeBytes(derived)
 
-		return password, nil
+	s := newSecret(key, v.lockMemory)
+	if v.lockMemory && !s.locked {
+		v.log.Debugf("could not lock the vault key into memory")
 	}
 
-	// TODO: implement keyfile
-	return nil, errors.New("keyfile not implemented yet")
+	return s
 }
 
-func OpenVault(databasePath string, keyfilePath string, password []byte) (Vault, error) {
-	vault := Vault{
-		databaseFilename:  databasePath,
-		vaultInfoFilename: filepath.Join(filepath.Dir(databasePath), vaultInfoFileName),
-	}
+// ways openSQLCipher opens a database, as query parameters of its file: URI
+const (
+	readWrite = ""
+	// SQLite neither takes locks nor creates a journal, WAL or shared memory file next to the database,
+	// and so never reads a WAL either
+	readOnlyImmutable = "mode=ro&immutable=1"
+	// for a private snapshot, SQLite reads its WAL and may create a shared memory file next to it
+	readOnlySnapshot = "mode=ro"
+)
 
-	vaultInfo, err := loadVaultInfo(vault.vaultInfoFilename)
-	if err != nil {
-		return Vault{}, err
+// databaseMode : how the vault database is opened
+func (v *Vault) databaseMode() string {
+	switch {
+	case v.snapshotDir != "":
+		return readOnlySnapshot
+	case v.readOnly:
+		return readOnlyImmutable
 	}
 
-	vault.vaultInfo = vaultInfo
+	return readWrite
+}
 
-	if keyfilePath == "" && vaultInfo.HasKeyfile == 1 {
-		return Vault{}, errors.New("you should specify a keyfile")
-	} else if keyfilePath != "" && vaultInfo.HasKeyfile == 0 {
-		return Vault{}, errors.New("you are not currently using a keyfile")
-	}
+// openSQLCipher : database handle for a SQLCipher file with a raw key, the key stays owned by the caller
+func openSQLCipher(path string, key *secret, params CipherParams, mode string) *sql.DB {
+	return sql.OpenDB(&connector{
+		dsn:    sqlcipherDSN(path, mode),
+		key:    key,
+		params: params,
+	})
+}
 
-	masterPassword, err := generateMasterPassword(password, keyfilePath)
-	if err != nil {
-		return Vault{}, errors.Wrap(err, "could not generate vault unlock key")
+// sqlcipherDSN : a file: URI, so any path works
+func sqlcipherDSN(path string, mode string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
 	}
 
-	keySalt, err := vault.extractSalt(databasePath)
+	uri := url.URL{Scheme: "file", Path: filepath.ToSlash(path), RawQuery: mode}
+	return uri.String()
+}
+
+// connector : keys every new connection with PRAGMA key, so the key never is part of a DSN
+// string that database/sql keeps, then applies the cipher settings go-sqlcipher has no DSN
+// parameters for in the order SQLCipher needs
+type connector struct {
+	dsn    string
+	key    *secret
+	params CipherParams
+}
+
+func (c *connector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := sqlcipherDriver.Open(c.dsn)
 	if err != nil {
-		return Vault{}, errors.Wrap(err, "could not get master password salt")
+		return nil, err
 	}
+	sqlConn := conn.(*sqlcipher.SQLiteConn)
 
-	fullKey, err := vault.deriveKey(masterPassword, keySalt)
+	_, err = sqlConn.Exec(keyStatement("key", c.key, nil), nil)
 	if err != nil {
-		return Vault{}, errors.Wrap(err, "could not derive master key from master password")
+		conn.Close()
+		return nil, errors.Wrap(err, "could not apply database key")
 	}
 
-	if err := vault.openEncryptedDatabase(databasePath, fullKey); err != nil {
-		return Vault{}, errors.Wrap(err, "could not open vault")
+	for _, pragma := range c.params.pragmas() {
+		if _, err := sqlConn.Exec(pragma, nil); err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "could not apply cipher settings")
+		}
 	}
 
-	return vault, nil
+	return conn, nil
 }
 
-func (v *Vault) Close() {
-	v.db.Close()
+func (c *connector) Driver() driver.Driver {
+	return sqlcipherDriver
 }
 
-func (v *Vault) generateRowKey(hash []byte, salt []byte) []byte {
-	// PBKDF2- HMAC-SHA256
-	return pbkdf2.Key(hash, salt, rowKeyIterations, sha256.Size, sha256.New)
+// pragmas : the compatibility pragma resets all other cipher settings, so it comes first
+func (p CipherParams) pragmas() []string {
+	var pragmas []string
+	if p.Compatibility != 0 {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA cipher_compatibility = %d;", p.Compatibility))
+	}
+	if p.PageSize != 0 {
+		pragmas = append(pragmas, fmt.Sprintf("PRAGMA cipher_page_size = %d;", p.PageSize))
+	}
+
+	return pragmas
 }
 
-func (v *Vault) getCryptoParameters() (iv []byte, key []byte, err error) {
-	var info []byte
-	var hash []byte
+// checkKey : sql.Open is lazy, so read the schema to find out if the key is right
+func (v *Vault) checkKey(ctx context.Context) error {
+	return checkDatabaseKey(ctx, v.db)
+}
+
+// checkDatabaseKey : SQLCipher reports a wrong key as "file is not a database" on the first read
+func checkDatabaseKey(ctx context.Context, db *sql.DB) error {
+	err := db.PingContext(ctx)
+	if err == nil {
+		var tables int
+		err = db.QueryRowContext(ctx, "SELECT count(*) FROM sqlite_master;").Scan(&tables)
+	}
+	if err == nil {
+		return nil
+	}
+
+	var sqlErr sqlcipher.Error
+	if errors.As(err, &sqlErr) {
+		switch sqlErr.Code {
+		case sqlcipher.ErrNotADB:
+			return ErrWrongPassword
+		case sqlcipher.ErrCorrupt:
+			return errors.Wrapf(ErrCorruptVault, "%v", err)
+		}
+	}
+
+	return errors.Wrap(err, "could not read database schema")
+}
 
-	row := v.db.QueryRow("SELECT i.title, i.uuid, i.key, if.value, if.hash FROM item i, itemfield if")
-	if err := row.Scan(&info, &hash); err != nil {
-		return nil, nil, errors.Wrap(err, "could not query crypto parameters")
+// checkDatabaseFile : catch files that no key can open before spending time on the KDF
+func checkDatabaseFile(path string, params CipherParams) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return errors.Wrapf(ErrVaultNotFound, "%s does not exist", path)
+	} else if err != nil {
+		return errors.Wrap(err, "could not open database")
 	}
 
-	//if len(info) != 47 {
-	//	return nil, nil, errors.New(fmt.Sprintf("row encryption info is not 47 bytes long but %d bytes", len(info)))
-	//}
+	if info.Size() < saltLength {
+		return errors.Wrapf(ErrCorruptVault, "%s is %d bytes long", path, info.Size())
+	}
+	if params.PageSize != 0 && info.Size()%int64(params.PageSize) != 0 {
+		return errors.Wrapf(ErrCorruptVault, "%s is %d bytes long, not a multiple of the %d byte page size", path, info.Size(), params.PageSize)
+	}
 
-	// First 16 bytes are for "mHashData", which is unused
-	iv = info[17:31]
-	salt := info[32:]
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "could not open database")
+	}
+	defer f.Close()
 
-	key = v.generateRowKey(hash, salt)
+	// the salt takes the place of the plain SQLite header
+	header := make([]byte, len(sqliteHeader))
+	if _, err := io.ReadFull(f, header); err == nil && string(header) == sqliteHeader {
+		return errors.Wrapf(ErrCorruptVault, "%s is not encrypted", path)
+	}
 
-	return iv, key, nil
+	return nil
 }
 
-func (v *Vault) decrypt(input []byte, key []byte, iv []byte) (output []byte, err error) {
-	cipher, err := aes.NewCipher(key)
+// generateMasterPassword : the Enpass unlock secret is the master password followed by the keyfile key,
+// in a new buffer the caller wipes
+func generateMasterPassword(password []byte, keyfilePath string) ([]byte, error) {
+	if keyfilePath == "" {
+		if password == nil || len(password) == 0 {
+			return nil, errors.New("empty master password provided")
+		}
+
+		// a copy, the caller's password is the caller's to wipe
+		return append([]byte{}, password...), nil
+	}
+
+	keyfileKey, err := loadKeyfileKey(keyfilePath)
 	if err != nil {
 		return nil, err
 	}
 
-	decrypter := cryptocipher.NewCBCDecrypter(cipher, iv)
-	decrypter.CryptBlocks(output, input)
+	defer wipeBytes(keyfileKey)
 
-	return output, nil
+	masterPassword := make([]byte, 0, len(password)+len(keyfileKey))
+	masterPassword = append(masterPassword, password...)
+	masterPassword = append(masterPassword, keyfileKey...)
+
+	return masterPassword, nil
 }
 
-func (v *Vault) GetCards() error {
-	decIv, decKey, err := v.getCryptoParameters()
+// OpenVault : open the vault with the database at databasePath, see Open
+func OpenVault(databasePath string, keyfilePath string, password []byte) (Vault, error) {
+	vault, err := openVault(context.Background(), databasePath, openConfig{
+		keyfilePath: keyfilePath,
+		password:    password,
+		log:         nopLogger{},
+	})
+	if err != nil {
+		return Vault{}, err
+	}
+
+	return *vault, nil
+}
+
+func openVault(ctx context.Context, databasePath string, config openConfig) (*Vault, error) {
+	vault := &Vault{
+		dir:               filepath.Dir(databasePath),
+		databaseFilename:  databasePath,
+		vaultInfoFilename: config.vaultInfoFilename,
+		readOnly:          config.readOnly,
+		lockMemory:        config.lockMemory,
+		log:               config.log,
+		kdfCache:          config.kdfCache,
+	}
+	if vault.vaultInfoFilename == "" {
+		vault.vaultInfoFilename = filepath.Join(vault.dir, vaultInfoFileName)
+	}
+
+	vaultInfo, err := loadVaultInfo(vault.vaultInfoFilename)
 	if err != nil {
-		return errors.Wrap(err, "could not retrieve crypto parameters")
+		return nil, err
 	}
 
-	rows, err := v.db.Query("SELECT title, key FROM item;")
+	vault.vaultInfo = vaultInfo
+
+	vault.kdf, vault.cipherParams, err = lookupScheme(vaultInfo)
 	if err != nil {
-		return errors.Wrap(err, "could not retrieve cards")
+		return nil, err
+	}
+
+	keyfilePath := config.keyfilePath
+	if keyfilePath == "" && vaultInfo.HasKeyfile == 1 {
+		return nil, &KeyfileError{Reason: "vault requires a keyfile, you should specify one"}
+	} else if keyfilePath != "" && vaultInfo.HasKeyfile == 0 {
+		return nil, &KeyfileError{Path: keyfilePath, Reason: "vault is not using a keyfile"}
+	}
+
+	if err := checkDatabaseFile(databasePath, vault.cipherParams); err != nil {
+		return nil, err
 	}
 
-	for rows.Next() {
-		var title string
-		var key []byte
+	if config.snapshot {
+		if vault.snapshotDir, vault.databaseFilename, err = snapshotDatabase(databasePath); err != nil {
+			return nil, err
+		}
+		vault.log.Debugf("reading a snapshot of the vault database in %s", vault.snapshotDir)
+	}
+
+	if err := vault.unlock(ctx, config); err != nil {
+		vault.removeSnapshot()
+		return nil, err
+	}
+
+	vault.log.Debugf("opened vault %s at %s", vaultInfo.VaultName, vault.dir)
+	return vault, nil
+}
 
-		if err := rows.Scan(&title, &key); err != nil {
-			log.Fatal(err)
+// unlock : derive the key from the master password and open the database with it
+func (v *Vault) unlock(ctx context.Context, config openConfig) error {
+	password := config.password
+	if config.passwordSource != nil {
+		var err error
+		if password, err = config.passwordSource(ctx); err != nil {
+			return err
 		}
+		defer wipeBytes(password)
+	}
+
+	masterPassword, err := generateMasterPassword(password, config.keyfilePath)
+	if err != nil {
+		return errors.Wrap(err, "could not generate vault unlock key")
+	}
+	defer wipeBytes(masterPassword)
+
+	keySalt, err := v.extractSalt(v.databaseFilename)
+	if err != nil {
+		return errors.Wrap(err, "could not get master password salt")
+	}
 
-		decrypted, err := v.decrypt(key, decKey, decIv)
-		if err != nil {
-			log.Fatal(err)
+	derived, err := v.deriveKey(ctx, masterPassword, keySalt, v.vaultInfo.KDFIterations)
+	if err != nil {
+		return errors.Wrap(err, "could not derive master key from master password")
+	}
+	key := v.databaseKey(derived)
+
+	if err := v.openEncryptedDatabase(v.databaseFilename, key); err != nil {
+		key.wipe()
+		return errors.Wrap(err, "could not open vault")
+	}
+
+	if err := v.checkKey(ctx); err != nil {
+		v.db.Close()
+		key.wipe()
+
+		if err == ErrWrongPassword && config.keyfilePath != "" {
+			return errors.Wrap(err, "master password or keyfile does not unlock the vault")
 		}
 
-		log.Printf("%v", decrypted)
+		return errors.Wrap(err, "could not unlock vault")
 	}
 
+	v.kdfCache.put(v.vaultInfo.KDFAlgo, masterPassword, keySalt, KDFParams{Iterations: v.vaultInfo.KDFIterations}, key.bytes())
 	return nil
 }
+
+// Info : the vault.json contents the vault was opened with
+func (v *Vault) Info() VaultInfo {
+	return v.vaultInfo
+}
+
+// Close : close the database and wipe the raw key, copies made by the driver and SQLite are not reached
+func (v *Vault) Close() {
+	v.db.Close()
+	v.key.wipe()
+	v.removeSnapshot()
+}
+
+func (v *Vault) removeSnapshot() {
+	if v.snapshotDir != "" {
+		os.RemoveAll(v.snapshotDir)
+	}
+}
@@ -0,0 +1,121 @@
+package enpasscli
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+// master password of the sample vault at the repository root
+const testPassword = "mymasterpassword"
+
+// copyTestVault : a copy of the sample vault that a test may change
+func copyTestVault(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, name := range []string{vaultDatabaseFileName, vaultInfoFileName} {
+		if err := copyFile(filepath.Join("..", name), filepath.Join(dir, name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+// openTestVault : unlock a copy of the sample vault, closed when the test ends
+func openTestVault(t *testing.T, opts ...Option) *Vault {
+	t.Helper()
+
+	opts = append([]Option{WithPassword([]byte(testPassword))}, opts...)
+	vault, err := Open(context.Background(), copyTestVault(t), opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(vault.Close)
+
+	return vault
+}
+
+func TestOpenErrors(t *testing.T) {
+	writeFile := func(name string, contents []byte) func(t *testing.T, dir string) {
+		return func(t *testing.T, dir string) {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), contents, 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	editVaultInfo := func(old, new string) func(t *testing.T, dir string) {
+		return func(t *testing.T, dir string) {
+			path := filepath.Join(dir, vaultInfoFileName)
+			info, err := ioutil.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ioutil.WriteFile(path, []byte(strings.Replace(string(info), old, new, 1)), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	// a plain SQLite database of one 1024 byte page, which no key opens
+	plainDatabase := append([]byte(sqliteHeader), make([]byte, 1024-len(sqliteHeader))...)
+
+	tests := []struct {
+		name     string
+		password string
+		change   func(t *testing.T, dir string)
+		want     error
+	}{
+		{name: "wrong password", password: "notmymasterpassword", want: ErrWrongPassword},
+		{name: "no vault.json", password: testPassword, change: func(t *testing.T, dir string) {
+			os.Remove(filepath.Join(dir, vaultInfoFileName))
+		}, want: ErrVaultNotFound},
+		{name: "no database", password: testPassword, change: func(t *testing.T, dir string) {
+			os.Remove(filepath.Join(dir, vaultDatabaseFileName))
+		}, want: ErrVaultNotFound},
+		{name: "vault.json is not json", password: testPassword, change: writeFile(vaultInfoFileName, []byte("<vault/>")), want: ErrCorruptVault},
+		{name: "database shorter than the salt", password: testPassword, change: writeFile(vaultDatabaseFileName, []byte("enpass")), want: ErrCorruptVault},
+		{name: "database cut off mid page", password: testPassword, change: writeFile(vaultDatabaseFileName, bytes.Repeat([]byte{1}, 1500)), want: ErrCorruptVault},
+		{name: "database not encrypted", password: testPassword, change: writeFile(vaultDatabaseFileName, plainDatabase), want: ErrCorruptVault},
+		{name: "unknown kdf", password: testPassword, change: editVaultInfo(`"pbkdf2"`, `"argon2id"`)},
+		{name: "unknown version", password: testPassword, change: editVaultInfo(`"version": 6`, `"version": 99`)},
+		{name: "keyfile required", password: testPassword, change: editVaultInfo(`"have_keyfile": 0`, `"have_keyfile": 1`), want: ErrWrongKeyfile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := copyTestVault(t)
+			if tt.change != nil {
+				tt.change(t, dir)
+			}
+
+			vault, err := Open(context.Background(), dir, WithPassword([]byte(tt.password)))
+			if err == nil {
+				vault.Close()
+				t.Fatal("Open succeeded")
+			}
+
+			if tt.want == nil {
+				var unsupportedErr *ErrUnsupportedVault
+				if !errors.As(err, &unsupportedErr) {
+					t.Fatalf("Open error %v, want an ErrUnsupportedVault", err)
+				}
+				return
+			}
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("Open error %v, want %v", err, tt.want)
+			}
+			for _, other := range []error{ErrWrongPassword, ErrVaultNotFound, ErrCorruptVault, ErrWrongKeyfile} {
+				if other != tt.want && errors.Is(err, other) {
+					t.Fatalf("Open error %v also matches %v", err, other)
+				}
+			}
+		})
+	}
+}
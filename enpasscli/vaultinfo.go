@@ -1,32 +1,67 @@
 package enpasscli
 
 import (
+	"bytes"
 	"encoding/json"
 	"io/ioutil"
+	"os"
 
 	"github.com/pkg/errors"
 )
 
 type VaultInfo struct {
-	EncryptionAlgo string `json:"encryption_algo"`
-	HasKeyfile     int    `json:"have_keyfile"`
-	KDFAlgo        string `json:"kdf_algo"`
-	KDFIterations  int    `json:"kdf_iter"`
-	VaultNumItems  int    `json:"vault_items_count"`
-	VaultName      string `json:"vault_name"`
-	VaultVersion   int    `json:"version"`
+	EncryptionAlgo   string `json:"encryption_algo"`
+	HasKeyfile       int    `json:"have_keyfile"`
+	KDFAlgo          string `json:"kdf_algo"`
+	KDFIterations    int    `json:"kdf_iter"`
+	LastModifiedTime int64  `json:"last_modified_time"`
+	VaultNumItems    int    `json:"vault_items_count"`
+	// attachments, inline and in .enpassattach files
+	VaultNumAttachments int    `json:"vault_att_count"`
+	VaultName           string `json:"vault_name"`
+	VaultUUID           string `json:"vault_uuid"`
+	VaultVersion        int    `json:"version"`
 }
 
 func loadVaultInfo(path string) (VaultInfo, error) {
 	vaultInfoBytes, err := ioutil.ReadFile(path)
-	if err != nil {
+	if os.IsNotExist(err) {
+		return VaultInfo{}, errors.Wrapf(ErrVaultNotFound, "%s does not exist", path)
+	} else if err != nil {
 		return VaultInfo{}, errors.Wrap(err, "could not read vault info")
 	}
 
 	var vaultInfo VaultInfo
 	if err := json.Unmarshal(vaultInfoBytes, &vaultInfo); err != nil {
-		return VaultInfo{}, errors.Wrap(err, "could not parse vault info")
+		return VaultInfo{}, errors.Wrapf(ErrCorruptVault, "could not parse vault info: %v", err)
 	}
 
 	return vaultInfo, nil
 }
+
+// updateVaultInfo : set keys of vault.json, keeping the keys VaultInfo does not know about
+func updateVaultInfo(path string, updates map[string]interface{}) error {
+	vaultInfoBytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "could not read vault info")
+	}
+
+	var raw map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(vaultInfoBytes))
+	// keep large integers such as timestamps exact
+	decoder.UseNumber()
+	if err := decoder.Decode(&raw); err != nil {
+		return errors.Wrap(err, "could not parse vault info")
+	}
+
+	for key, value := range updates {
+		raw[key] = value
+	}
+
+	updated, err := json.MarshalIndent(raw, "", "    ")
+	if err != nil {
+		return errors.Wrap(err, "could not encode vault info")
+	}
+
+	return errors.Wrap(writeFileAtomic(path, append(updated, '\n'), 0600), "could not write vault info")
+}
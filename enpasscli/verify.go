@@ -0,0 +1,305 @@
+package enpasscli
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ProblemKind : what Verify found wrong with a vault
+type ProblemKind string
+
+const (
+	// PRAGMA cipher_integrity_check failed a page, the database file was modified or damaged
+	ProblemCipherIntegrity ProblemKind = "cipher_integrity"
+	// PRAGMA integrity_check found a damaged table or index
+	ProblemIntegrity ProblemKind = "integrity"
+	// the item key is malformed
+	ProblemItemKey ProblemKind = "item_key"
+	// a sensitive field value does not decrypt under its item key
+	ProblemField ProblemKind = "field"
+	// vault_items_count or vault_att_count of vault.json differs from the database
+	ProblemCount ProblemKind = "count"
+	// an itemfield row of an item that is gone
+	ProblemOrphanedField ProblemKind = "orphaned_field"
+	// an attachment row of an item that is gone
+	ProblemOrphanedAttachment ProblemKind = "orphaned_attachment"
+	// an attachment stored outside the database has no .enpassattach file
+	ProblemMissingAttachmentFile ProblemKind = "missing_attachment_file"
+	// an attachment does not decrypt, or its content differs from the recorded size or hash
+	ProblemAttachment ProblemKind = "attachment"
+)
+
+// Problem : one thing Verify found wrong, UUID is the item or attachment it concerns, if any
+type Problem struct {
+	Kind   ProblemKind
+	UUID   string
+	Detail string
+}
+
+// Report : everything Verify found wrong, a healthy vault has no problems
+type Report struct {
+	Items       int
+	Attachments int
+	Problems    []Problem
+}
+
+// OK : whether Verify found nothing wrong
+func (r Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+func (r *Report) add(kind ProblemKind, uuid string, format string, args ...interface{}) {
+	r.Problems = append(r.Problems, Problem{Kind: kind, UUID: uuid, Detail: fmt.Sprintf(format, args...)})
+}
+
+// Verify : check the database pages and tables, the item keys, every sensitive field and
+// every attachment, and that vault.json agrees with the database. An error means the checks could not run.
+func (v *Vault) Verify(ctx context.Context) (Report, error) {
+	var report Report
+
+	checks := []func(context.Context, *Report) error{
+		v.verifyPages,
+		v.verifyItems,
+		v.verifyOrphans,
+		v.verifyAttachments,
+		v.verifyCounts,
+	}
+	for _, check := range checks {
+		damaged := !report.OK()
+
+		if err := check(ctx, &report); err != nil {
+			// on damaged pages the later queries fail too, that is part of the finding
+			if !damaged || ctx.Err() != nil {
+				return Report{}, err
+			}
+			report.add(ProblemIntegrity, "", "%v", err)
+		}
+	}
+
+	return report, nil
+}
+
+// verifyPages : both pragmas return one row per problem, integrity_check a single "ok" otherwise
+func (v *Vault) verifyPages(ctx context.Context, report *Report) error {
+	pragmas := []struct {
+		query string
+		kind  ProblemKind
+	}{
+		{"PRAGMA cipher_integrity_check;", ProblemCipherIntegrity},
+		{"PRAGMA integrity_check;", ProblemIntegrity},
+	}
+
+	for _, pragma := range pragmas {
+		rows, err := v.db.QueryContext(ctx, pragma.query)
+		if err != nil {
+			return errors.Wrapf(err, "could not run %s", pragma.query)
+		}
+
+		// both stop with an error at pages that do not decrypt
+		var results []string
+
+		for rows.Next() {
+			var result string
+			if err := rows.Scan(&result); err != nil {
+				rows.Close()
+				return errors.Wrapf(err, "could not read %s", pragma.query)
+			}
+
+			// integrity_check may return several problems in one row
+			for _, line := range strings.Split(result, "\n") {
+				if line != "ok" && line != "" && !strings.HasPrefix(line, "***") {
+					results = append(results, line)
+				}
+			}
+		}
+
+		err = rows.Err()
+		rows.Close()
+		if err != nil && ctx.Err() != nil {
+			return err
+		}
+		if err != nil {
+			results = append(results, err.Error())
+		}
+
+		for _, result := range results {
+			report.add(pragma.kind, "", "%s", result)
+		}
+	}
+
+	return nil
+}
+
+// verifyItems : decrypt every sensitive field, like GetItems but without stopping at the first failure
+func (v *Vault) verifyItems(ctx context.Context, report *Report) error {
+	rows, err := v.db.QueryContext(ctx, `
+SELECT i.uuid, i.key, f.item_field_uid, f.value
+FROM item i
+LEFT JOIN itemfield f ON f.item_uuid = i.uuid AND f.deleted = 0 AND f.sensitive = 1 AND f.value != ''
+WHERE i.deleted = 0
+ORDER BY i.ID, f.orde;`)
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve items")
+	}
+	defer rows.Close()
+
+	var current string
+	var cipher *itemCipher
+	var keyErr error
+
+	for rows.Next() {
+		var uuid string
+		var key []byte
+		var uid sql.NullInt64
+		var value sql.NullString
+
+		if err := rows.Scan(&uuid, &key, &uid, &value); err != nil {
+			return errors.Wrap(err, "could not read item")
+		}
+
+		if uuid != current {
+			current = uuid
+			report.Items++
+
+			if cipher, keyErr = newItemCipher(uuid, key); keyErr != nil {
+				report.add(ProblemItemKey, uuid, "%v", keyErr)
+			}
+		}
+
+		if !uid.Valid || keyErr != nil {
+			continue
+		}
+
+		if _, err := cipher.decrypt(value.String); err != nil {
+			report.add(ProblemField, uuid, "field %d: %v", uid.Int64, err)
+		}
+	}
+
+	return errors.Wrap(rows.Err(), "could not retrieve items")
+}
+
+func (v *Vault) verifyOrphans(ctx context.Context, report *Report) error {
+	orphans := []struct {
+		query  string
+		kind   ProblemKind
+		detail string
+	}{
+		{`
+SELECT f.item_uuid, f.item_field_uid
+FROM itemfield f
+LEFT JOIN item i ON i.uuid = f.item_uuid AND i.deleted = 0
+WHERE f.deleted = 0 AND i.uuid IS NULL;`, ProblemOrphanedField, "field %s of a missing or deleted item"},
+		{`
+SELECT a.uuid, a.item_uuid
+FROM attachment a
+LEFT JOIN item i ON i.uuid = a.item_uuid AND i.deleted = 0
+WHERE a.deleted = 0 AND i.uuid IS NULL;`, ProblemOrphanedAttachment, "attachment of missing or deleted item %s"},
+	}
+
+	for _, orphan := range orphans {
+		if err := v.eachRow(ctx, orphan.query, func(uuid string, detail string) {
+			report.add(orphan.kind, uuid, orphan.detail, detail)
+		}); err != nil {
+			return errors.Wrap(err, "could not look for orphaned rows")
+		}
+	}
+
+	return nil
+}
+
+// verifyAttachments : decrypt every attachment, those of missing items are already orphans
+func (v *Vault) verifyAttachments(ctx context.Context, report *Report) error {
+	rows, err := v.db.QueryContext(ctx,
+		"SELECT "+attachmentColumns+", a.password, a.data, COALESCE(a.extra, '') FROM attachment a WHERE a.deleted = 0;")
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve attachments")
+	}
+
+	// read them all first, decrypting queries the database again
+	var attachments []attachmentRow
+	for rows.Next() {
+		var row attachmentRow
+		if row.Attachment, err = scanAttachment(rows, &row.password, &row.data, &row.extra); err != nil {
+			rows.Close()
+			return errors.Wrap(err, "could not read attachment")
+		}
+		attachments = append(attachments, row)
+	}
+	err = rows.Err()
+	rows.Close()
+	if err != nil {
+		return errors.Wrap(err, "could not retrieve attachments")
+	}
+
+	for _, row := range attachments {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		report.Attachments++
+
+		if !row.Inline {
+			if _, err := os.Stat(v.attachmentPath(row.UUID)); os.IsNotExist(err) {
+				report.add(ProblemMissingAttachmentFile, row.UUID, "%s%s does not exist", row.UUID, attachmentFileExtension)
+				continue
+			} else if err != nil {
+				report.add(ProblemMissingAttachmentFile, row.UUID, "%v", err)
+				continue
+			}
+		}
+
+		content, err := v.attachmentContent(row)
+		if errors.Is(err, ErrItemNotFound) {
+			continue
+		}
+		if err != nil {
+			report.add(ProblemAttachment, row.UUID, "%v", err)
+			continue
+		}
+		wipeBytes(content)
+	}
+
+	return nil
+}
+
+// verifyCounts : vault.json is read again, it may have changed since the vault was opened
+func (v *Vault) verifyCounts(ctx context.Context, report *Report) error {
+	vaultInfo, err := loadVaultInfo(v.vaultInfoFilename)
+	if err != nil {
+		return err
+	}
+
+	if vaultInfo.VaultNumItems != report.Items {
+		report.add(ProblemCount, "", "vault.json has vault_items_count %d, the database holds %d items",
+			vaultInfo.VaultNumItems, report.Items)
+	}
+	if vaultInfo.VaultNumAttachments != report.Attachments {
+		report.add(ProblemCount, "", "vault.json has vault_att_count %d, the database holds %d attachments",
+			vaultInfo.VaultNumAttachments, report.Attachments)
+	}
+
+	return nil
+}
+
+// eachRow : call fn with the two text columns of every row of query
+func (v *Vault) eachRow(ctx context.Context, query string, fn func(string, string)) error {
+	rows, err := v.db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var first, second string
+		if err := rows.Scan(&first, &second); err != nil {
+			return err
+		}
+		fn(first, second)
+	}
+
+	return rows.Err()
+}
@@ -0,0 +1,164 @@
+package enpasscli
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"testing"
+)
+
+const (
+	testAttachmentUUID = "0a4bb0b8-4c2b-4b1e-9f46-4d6a6f0a0002"
+	missingItemUUID    = "5d1b3c2a-7e6f-4a8b-9c0d-1e2f3a4b5c6d"
+)
+
+// setVaultCounts : rewrite vault_items_count and vault_att_count in the vault.json of vault
+func setVaultCounts(t *testing.T, vault *Vault, items int, attachments int) {
+	t.Helper()
+
+	info, err := ioutil.ReadFile(vault.vaultInfoFilename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info = regexp.MustCompile(`"vault_items_count": \d+`).ReplaceAll(info, []byte(fmt.Sprintf(`"vault_items_count": %d`, items)))
+	info = regexp.MustCompile(`"vault_att_count": \d+`).ReplaceAll(info, []byte(fmt.Sprintf(`"vault_att_count": %d`, attachments)))
+	if err := ioutil.WriteFile(vault.vaultInfoFilename, info, 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// addAttachment : attach content to the sample item, inline or in a .enpassattach file, with the given size and extra
+func addAttachment(t *testing.T, vault *Vault, content []byte, inline bool, size int, extra string) {
+	t.Helper()
+
+	cipher, err := newItemCipher(testItemUUID, storedItemKey(t, vault, testItemUUID))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var data []byte
+	if inline {
+		data = cipher.seal(content)
+	}
+	if _, err := vault.db.Exec(`
+INSERT INTO attachment (uuid, item_uuid, name, size, orde, mime, updated_at, created_at, deleted, internal, password, data, extra)
+VALUES (?, ?, 'a.txt', ?, 1, 'text/plain', 0, 0, 0, ?, x'', ?, ?);`,
+		testAttachmentUUID, testItemUUID, size, inline, data, extra,
+	); err != nil {
+		t.Fatal(err)
+	}
+	setVaultCounts(t, vault, 1, 1)
+}
+
+func TestVerify(t *testing.T) {
+	content := []byte("attached")
+	sum := sha1.Sum(content)
+	hash := hex.EncodeToString(sum[:])
+
+	tests := []struct {
+		name    string
+		corrupt func(t *testing.T, vault *Vault)
+		// kind and uuid of every problem
+		want []string
+	}{
+		{name: "healthy vault", corrupt: func(*testing.T, *Vault) {}},
+		{
+			name: "tampered field",
+			corrupt: func(t *testing.T, vault *Vault) {
+				value := []byte(storedFieldValue(t, vault, testItemUUID, 11))
+				value[len(value)-1] ^= 1
+				if _, err := vault.db.Exec("UPDATE itemfield SET value = ? WHERE item_uuid = ? AND item_field_uid = 11;",
+					string(value), testItemUUID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"field " + testItemUUID},
+		},
+		{
+			name: "malformed item key",
+			corrupt: func(t *testing.T, vault *Vault) {
+				if _, err := vault.db.Exec("UPDATE item SET key = x'00' WHERE uuid = ?;", testItemUUID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"item_key " + testItemUUID},
+		},
+		{
+			name: "orphaned field",
+			corrupt: func(t *testing.T, vault *Vault) {
+				if _, err := vault.db.Exec(`
+INSERT INTO itemfield (item_uuid, item_field_uid, label, value, deleted, sensitive, historical, type, orde)
+VALUES (?, 10, 'Username', 'left behind', 0, 0, 0, 'username', 1);`, missingItemUUID); err != nil {
+					t.Fatal(err)
+				}
+			},
+			want: []string{"orphaned_field " + missingItemUUID},
+		},
+		{
+			name:    "wrong item count",
+			corrupt: func(t *testing.T, vault *Vault) { setVaultCounts(t, vault, 2, 0) },
+			want:    []string{"count "},
+		},
+		{
+			name:    "wrong attachment count",
+			corrupt: func(t *testing.T, vault *Vault) { setVaultCounts(t, vault, 1, 3) },
+			want:    []string{"count "},
+		},
+		{
+			name: "attachment",
+			corrupt: func(t *testing.T, vault *Vault) {
+				addAttachment(t, vault, content, true, len(content), `{"hash":"`+hash+`"}`)
+			},
+		},
+		{
+			name: "attachment hash mismatch",
+			corrupt: func(t *testing.T, vault *Vault) {
+				other := sha1.Sum([]byte("something else"))
+				addAttachment(t, vault, content, true, len(content), `{"hash":"`+hex.EncodeToString(other[:])+`"}`)
+			},
+			want: []string{"attachment " + testAttachmentUUID},
+		},
+		{
+			name: "attachment size mismatch",
+			corrupt: func(t *testing.T, vault *Vault) {
+				addAttachment(t, vault, content, true, len(content)+1, "")
+			},
+			want: []string{"attachment " + testAttachmentUUID},
+		},
+		{
+			name: "missing attachment file",
+			corrupt: func(t *testing.T, vault *Vault) {
+				addAttachment(t, vault, content, false, len(content), "")
+			},
+			want: []string{"missing_attachment_file " + testAttachmentUUID},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			vault := openTestVault(t)
+			tt.corrupt(t, vault)
+
+			report, err := vault.Verify(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, problem := range report.Problems {
+				got = append(got, string(problem.Kind)+" "+problem.UUID)
+			}
+			sort.Strings(got)
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) || report.OK() != (len(tt.want) == 0) {
+				t.Errorf("problems %q, want %q\n%+v", got, tt.want, report.Problems)
+			}
+			if report.Items != 1 {
+				t.Errorf("verified %d items, want 1", report.Items)
+			}
+		})
+	}
+}
@@ -0,0 +1,575 @@
+package enpasscli
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// default category of new items
+	defaultCategory = "login"
+	// fields of new items get uids above the ones Enpass templates use
+	firstCustomFieldUID = 1000
+	// number of leading password characters Enpass keeps in itemfield.initial
+	passwordInitialLength = 2
+)
+
+// CreateItem : add a new item with a fresh item key, returns the stored item
+func (v *Vault) CreateItem(ctx context.Context, item Item) (Item, error) {
+	created, err := v.CreateItems(ctx, []Item{item})
+	if err != nil {
+		return Item{}, err
+	}
+
+	return created[0], nil
+}
+
+// CreateItems : add new items, each with a fresh item key, in one transaction so either all of them
+// are stored or none; returns the stored items in the same order
+func (v *Vault) CreateItems(ctx context.Context, items []Item) ([]Item, error) {
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	now := time.Now()
+	created := make([]Item, len(items))
+	for i, item := range items {
+		if strings.TrimSpace(item.Title) == "" {
+			return nil, errors.New("item needs a title")
+		}
+
+		uuid, err := newUUID()
+		if err != nil {
+			return nil, err
+		}
+
+		key, err := newItemKey()
+		if err != nil {
+			return nil, err
+		}
+
+		item.UUID = uuid
+		item.key = key
+		if item.Category == "" {
+			item.Category = defaultCategory
+		}
+		if item.Template == "" {
+			item.Template = item.Category + ".default"
+		}
+		item.CreatedAt, item.UpdatedAt = now, now
+
+		created[i] = item
+	}
+
+	err := v.writeTx(ctx, func(tx *sql.Tx) error {
+		for i := range created {
+			if err := insertItem(ctx, tx, &created[i], now); err != nil {
+				return errors.Wrapf(err, "could not create item %q", created[i].Title)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return created, nil
+}
+
+// insertItem : the item row, fields and tags of a new item
+func insertItem(ctx context.Context, tx *sql.Tx, item *Item, now time.Time) error {
+	if _, err := tx.ExecContext(ctx, `
+INSERT INTO item (uuid, created_at, meta_updated_at, field_updated_at, updated_at, title, subtitle, note, icon,
+                  favorite, trashed, archived, deleted, category, template, key)
+VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, 0, ?, ?, ?);`,
+		item.UUID, now.Unix(), now.Unix(), now.Unix(), now.Unix(), item.Title, item.Subtitle, item.Note, item.Icon,
+		item.Favorite, item.Trashed, item.Archived, item.Category, item.Template, item.key,
+	); err != nil {
+		return errors.Wrap(err, "could not insert item")
+	}
+
+	if err := writeFields(ctx, tx, item, nil, now); err != nil {
+		return err
+	}
+
+	return writeTags(ctx, tx, *item, now)
+}
+
+// UpdateItem : store the item's metadata and fields, fields without a uid are added and missing fields deleted.
+// Every update gives the item a new key, see itemCipher.encrypt.
+func (v *Vault) UpdateItem(ctx context.Context, item Item) (Item, error) {
+	now := time.Now()
+
+	err := v.writeTx(ctx, func(tx *sql.Tx) error {
+		var oldKey []byte
+		if err := tx.QueryRowContext(ctx, "SELECT key FROM item WHERE uuid = ? AND deleted = 0;", item.UUID).Scan(&oldKey); err != nil {
+			if err == sql.ErrNoRows {
+				return errors.Wrapf(ErrItemNotFound, "%q", item.UUID)
+			}
+			return errors.Wrap(err, "could not read item")
+		}
+
+		stored, err := storedFields(ctx, tx, item.UUID)
+		if err != nil {
+			return err
+		}
+
+		newKey, err := newItemKey()
+		if err != nil {
+			return err
+		}
+		if err := rekeyItem(ctx, tx, item.UUID, oldKey, newKey, stored); err != nil {
+			return err
+		}
+		item.key = newKey
+
+		if _, err := tx.ExecContext(ctx, `
+UPDATE item SET title = ?, subtitle = ?, note = ?, icon = ?, favorite = ?, trashed = ?, archived = ?,
+                category = ?, template = ?, key = ?, meta_updated_at = ?, field_updated_at = ?, updated_at = ?
+WHERE uuid = ?;`,
+			item.Title, item.Subtitle, item.Note, item.Icon, item.Favorite, item.Trashed, item.Archived,
+			item.Category, item.Template, item.key, now.Unix(), now.Unix(), now.Unix(), item.UUID,
+		); err != nil {
+			return errors.Wrap(err, "could not update item")
+		}
+
+		if err := writeFields(ctx, tx, &item, stored, now); err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE folder_items SET deleted = 1, updated_at = ? WHERE item_uuid = ?;", now.Unix(), item.UUID,
+		); err != nil {
+			return errors.Wrap(err, "could not update item tags")
+		}
+
+		return writeTags(ctx, tx, item, now)
+	})
+	if err != nil {
+		return Item{}, err
+	}
+
+	item.UpdatedAt = now
+	return item, nil
+}
+
+// TrashItem : move an item to the trash, it can still be restored with UpdateItem
+func (v *Vault) TrashItem(ctx context.Context, uuid string) error {
+	return v.writeTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now().Unix()
+		return execOnItem(ctx, tx, uuid,
+			"UPDATE item SET trashed = 1, meta_updated_at = ?, updated_at = ? WHERE uuid = ? AND deleted = 0;",
+			now, now, uuid,
+		)
+	})
+}
+
+// DeleteItem : mark an item and everything attached to it as deleted, so the deletion syncs
+func (v *Vault) DeleteItem(ctx context.Context, uuid string) error {
+	return v.writeTx(ctx, func(tx *sql.Tx) error {
+		now := time.Now().Unix()
+		if err := execOnItem(ctx, tx, uuid,
+			"UPDATE item SET deleted = 1, meta_updated_at = ?, updated_at = ? WHERE uuid = ? AND deleted = 0;",
+			now, now, uuid,
+		); err != nil {
+			return err
+		}
+
+		for _, query := range []string{
+			"UPDATE itemfield SET deleted = 1, value = '', updated_at = ? WHERE item_uuid = ?;",
+			"UPDATE folder_items SET deleted = 1, updated_at = ? WHERE item_uuid = ?;",
+			"UPDATE attachment SET deleted = 1, updated_at = ? WHERE item_uuid = ?;",
+		} {
+			if _, err := tx.ExecContext(ctx, query, now, uuid); err != nil {
+				return errors.Wrap(err, "could not delete item")
+			}
+		}
+
+		return nil
+	})
+}
+
+// writeTx : run fn in a transaction and keep vault.json in line with the committed database
+func (v *Vault) writeTx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	if v.readOnly {
+		return ErrReadOnly
+	}
+
+	tx, err := v.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "could not start transaction")
+	}
+
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	var itemCount, attachmentCount int
+	if err := tx.QueryRowContext(ctx,
+		"SELECT (SELECT count(*) FROM item WHERE deleted = 0), (SELECT count(*) FROM attachment WHERE deleted = 0);",
+	).Scan(&itemCount, &attachmentCount); err != nil {
+		tx.Rollback()
+		return errors.Wrap(err, "could not count items")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return errors.Wrap(err, "could not commit transaction")
+	}
+
+	now := time.Now().Unix()
+	updates := map[string]interface{}{
+		"vault_items_count":  itemCount,
+		"vault_att_count":    attachmentCount,
+		"last_modified_time": now,
+	}
+	if hostname, err := os.Hostname(); err == nil {
+		updates["last_modified_device"] = hostname
+	}
+
+	if err := updateVaultInfo(v.vaultInfoFilename, updates); err != nil {
+		return err
+	}
+
+	v.vaultInfo.VaultNumItems = itemCount
+	v.vaultInfo.VaultNumAttachments = attachmentCount
+	v.vaultInfo.LastModifiedTime = now
+
+	return nil
+}
+
+// execOnItem : run an update that has to affect the given item
+func execOnItem(ctx context.Context, tx *sql.Tx, uuid string, query string, args ...interface{}) error {
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return errors.Wrap(err, "could not update item")
+	}
+
+	if affected, err := result.RowsAffected(); err != nil {
+		return errors.Wrap(err, "could not update item")
+	} else if affected == 0 {
+		return errors.Wrapf(ErrItemNotFound, "%q", uuid)
+	}
+
+	return nil
+}
+
+// storedField : the columns of a stored field row that writeFields compares with
+type storedField struct {
+	hash    string
+	deleted bool
+	history string
+}
+
+// storedFields : every field row of an item, deleted ones included, by uid
+func storedFields(ctx context.Context, tx *sql.Tx, itemUUID string) (map[int]storedField, error) {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT item_field_uid, COALESCE(hash, ''), COALESCE(deleted, 0), COALESCE(history, '') FROM itemfield WHERE item_uuid = ?;",
+		itemUUID,
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read item fields")
+	}
+	defer rows.Close()
+
+	fields := map[int]storedField{}
+	for rows.Next() {
+		var uid int
+		var field storedField
+		if err := rows.Scan(&uid, &field.hash, &field.deleted, &field.history); err != nil {
+			return nil, errors.Wrap(err, "could not read item field")
+		}
+		fields[uid] = field
+	}
+
+	return fields, errors.Wrap(rows.Err(), "could not read item fields")
+}
+
+// writeFields : store item.Fields in their slice order. Fields in stored are updated in place, so the columns
+// Enpass keeps, e.g. the password history, survive, the others are added.
+func writeFields(ctx context.Context, tx *sql.Tx, item *Item, stored map[int]storedField, now time.Time) error {
+	cipher, err := newItemCipher(item.UUID, item.key)
+	if err != nil {
+		return err
+	}
+
+	nextUID := firstCustomFieldUID
+	for _, field := range item.Fields {
+		if field.UID >= nextUID {
+			nextUID = field.UID + 1
+		}
+	}
+	for uid := range stored {
+		if uid >= nextUID {
+			nextUID = uid + 1
+		}
+	}
+
+	kept := map[int]bool{}
+	for i := range item.Fields {
+		field := &item.Fields[i]
+		if field.UID == 0 {
+			field.UID = nextUID
+			nextUID++
+		}
+		field.Order = i + 1
+		kept[field.UID] = true
+
+		hash := valueHash(field.Value)
+		old, exists := stored[field.UID]
+		changed := !exists || old.deleted || old.hash != hash
+		if changed || field.UpdatedAt.IsZero() {
+			field.UpdatedAt = now
+		}
+
+		value := field.Value
+		if field.Sensitive && value != "" {
+			value = cipher.encrypt(value)
+		}
+
+		initial := ""
+		if field.Type == "password" {
+			initial = valueInitial(field.Value)
+		}
+
+		if exists {
+			// strength and breach check results belong to the old value
+			if _, err := tx.ExecContext(ctx, `
+UPDATE itemfield SET label = ?, value = ?, deleted = 0, sensitive = ?, type = ?, updated_at = ?, value_updated_at = ?,
+                     orde = ?, initial = ?, hash = ?,
+                     strength = CASE WHEN ? THEN -1 ELSE strength END,
+                     pwned_check_time = CASE WHEN ? THEN 0 ELSE pwned_check_time END
+WHERE item_uuid = ? AND item_field_uid = ?;`,
+				field.Label, value, field.Sensitive, field.Type, now.Unix(), field.UpdatedAt.Unix(),
+				field.Order, initial, hash, changed, changed, item.UUID, field.UID,
+			); err != nil {
+				return errors.Wrapf(err, "could not write field %d", field.UID)
+			}
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, `
+INSERT INTO itemfield (item_uuid, item_field_uid, label, value, deleted, sensitive, historical, type, form_id,
+                       updated_at, value_updated_at, orde, wearable, history, initial, hash, strength, algo_version)
+VALUES (?, ?, ?, ?, 0, ?, 1, ?, '', ?, ?, ?, 0, '', ?, ?, -1, 1);`,
+			item.UUID, field.UID, field.Label, value, field.Sensitive, field.Type,
+			now.Unix(), field.UpdatedAt.Unix(), field.Order, initial, hash,
+		); err != nil {
+			return errors.Wrapf(err, "could not write field %d", field.UID)
+		}
+	}
+
+	for uid, field := range stored {
+		if kept[uid] || field.deleted {
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE itemfield SET deleted = 1, value = '', updated_at = ? WHERE item_uuid = ? AND item_field_uid = ?;",
+			now.Unix(), item.UUID, uid,
+		); err != nil {
+			return errors.Wrapf(err, "could not delete field %d", uid)
+		}
+	}
+
+	return nil
+}
+
+// rekeyItem : re-encrypt what the item keeps besides its field values, the field histories and the attachments,
+// from oldKey to newKey. writeFields encrypts the values themselves.
+func rekeyItem(ctx context.Context, tx *sql.Tx, itemUUID string, oldKey []byte, newKey []byte, stored map[int]storedField) error {
+	oldCipher, err := newItemCipher(itemUUID, oldKey)
+	if err != nil {
+		return err
+	}
+	newCipher, err := newItemCipher(itemUUID, newKey)
+	if err != nil {
+		return err
+	}
+
+	for uid, field := range stored {
+		history, err := reencryptHistory(field.history, oldCipher, newCipher)
+		if err != nil {
+			return errors.Wrapf(err, "field %d", uid)
+		}
+		if history == field.history {
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE itemfield SET history = ? WHERE item_uuid = ? AND item_field_uid = ?;", history, itemUUID, uid,
+		); err != nil {
+			return errors.Wrapf(err, "could not write history of field %d", uid)
+		}
+	}
+
+	return rekeyAttachments(ctx, tx, itemUUID, oldCipher, newCipher)
+}
+
+// reencryptHistory : itemfield.history is a JSON list of earlier values, every string in it that opens
+// under the old key is sealed again under the new one
+func reencryptHistory(history string, oldCipher *itemCipher, newCipher *itemCipher) (string, error) {
+	if strings.TrimSpace(history) == "" {
+		return history, nil
+	}
+
+	var entries []map[string]interface{}
+	if err := json.Unmarshal([]byte(history), &entries); err != nil {
+		return "", errors.Wrap(err, "could not parse field history")
+	}
+
+	changed := false
+	for _, entry := range entries {
+		for name, value := range entry {
+			s, ok := value.(string)
+			if !ok {
+				continue
+			}
+
+			plaintext, err := oldCipher.decrypt(s)
+			if err != nil {
+				continue
+			}
+			entry[name] = newCipher.encrypt(plaintext)
+			changed = true
+		}
+	}
+
+	if !changed {
+		return history, nil
+	}
+
+	b, err := json.Marshal(entries)
+	if err != nil {
+		return "", errors.Wrap(err, "could not write field history")
+	}
+
+	return string(b), nil
+}
+
+// rekeyAttachments : inline attachment content and the keys of attachment files are encrypted with the item key
+func rekeyAttachments(ctx context.Context, tx *sql.Tx, itemUUID string, oldCipher *itemCipher, newCipher *itemCipher) error {
+	rows, err := tx.QueryContext(ctx,
+		"SELECT uuid, COALESCE(password, x''), COALESCE(data, x'') FROM attachment WHERE item_uuid = ? AND deleted = 0;",
+		itemUUID,
+	)
+	if err != nil {
+		return errors.Wrap(err, "could not read attachments")
+	}
+
+	type attachmentKeys struct {
+		uuid     string
+		password []byte
+		data     []byte
+	}
+	var attachments []attachmentKeys
+	for rows.Next() {
+		var a attachmentKeys
+		if err := rows.Scan(&a.uuid, &a.password, &a.data); err != nil {
+			rows.Close()
+			return errors.Wrap(err, "could not read attachment")
+		}
+		attachments = append(attachments, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return errors.Wrap(err, "could not read attachments")
+	}
+
+	for _, a := range attachments {
+		for _, column := range []*[]byte{&a.password, &a.data} {
+			if len(*column) == 0 {
+				continue
+			}
+
+			plaintext, err := oldCipher.open(*column)
+			if err != nil {
+				return errors.Wrapf(err, "could not decrypt attachment %s", a.uuid)
+			}
+			*column = newCipher.seal(plaintext)
+			wipeBytes(plaintext)
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			"UPDATE attachment SET password = ?, data = ? WHERE uuid = ?;", a.password, a.data, a.uuid,
+		); err != nil {
+			return errors.Wrapf(err, "could not write attachment %s", a.uuid)
+		}
+	}
+
+	return nil
+}
+
+// writeTags : link the item to the folders named in item.Tags, creating missing folders
+func writeTags(ctx context.Context, tx *sql.Tx, item Item, now time.Time) error {
+	for _, tag := range item.Tags {
+		var folderUUID string
+		err := tx.QueryRowContext(ctx, "SELECT uuid FROM folder WHERE title = ? AND deleted = 0;", tag).Scan(&folderUUID)
+		if err == sql.ErrNoRows {
+			if folderUUID, err = newUUID(); err != nil {
+				return err
+			}
+
+			if _, err := tx.ExecContext(ctx,
+				"INSERT INTO folder (uuid, title, icon, updated_at, deleted, parent_uuid) VALUES (?, ?, '', ?, 0, '');",
+				folderUUID, tag, now.Unix(),
+			); err != nil {
+				return errors.Wrapf(err, "could not create folder %q", tag)
+			}
+		} else if err != nil {
+			return errors.Wrapf(err, "could not read folder %q", tag)
+		}
+
+		if _, err := tx.ExecContext(ctx,
+			"INSERT INTO folder_items (folder_uuid, item_uuid, updated_at, deleted) VALUES (?, ?, ?, 0);",
+			folderUUID, item.UUID, now.Unix(),
+		); err != nil {
+			return errors.Wrapf(err, "could not tag item with %q", tag)
+		}
+	}
+
+	return nil
+}
+
+// valueHash : itemfield.hash is the hex SHA-1 of the plaintext value
+func valueHash(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	sum := sha1.Sum([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func valueInitial(value string) string {
+	runes := []rune(value)
+	if len(runes) > passwordInitialLength {
+		runes = runes[:passwordInitialLength]
+	}
+
+	return string(runes)
+}
+
+// newUUID : a random (version 4) uuid
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", errors.Wrap(err, "could not generate uuid")
+	}
+
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:]), nil
+}
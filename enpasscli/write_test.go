@@ -0,0 +1,271 @@
+package enpasscli
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	"github.com/pkg/errors"
+)
+
+func TestUpdateItemReplacesKey(t *testing.T) {
+	ctx := context.Background()
+	vault := openTestVault(t)
+
+	item, err := vault.FindItem(ctx, "mylogin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey := storedItemKey(t, vault, item.UUID)
+	oldCipher, err := newItemCipher(item.UUID, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an earlier password in the history and an inline attachment, both sealed with the item key
+	history := `[{"value":"` + oldCipher.encrypt("olderpassword") + `","updated_at":1600000000}]`
+	if _, err := vault.db.Exec("UPDATE itemfield SET history = ? WHERE item_uuid = ? AND item_field_uid = 11;", history, item.UUID); err != nil {
+		t.Fatal(err)
+	}
+	content := []byte("attached")
+	if _, err := vault.db.Exec(`
+INSERT INTO attachment (uuid, item_uuid, name, size, orde, mime, updated_at, created_at, deleted, internal, password, data)
+VALUES ('0a4bb0b8-4c2b-4b1e-9f46-4d6a6f0a0001', ?, 'a.txt', ?, 1, 'text/plain', 0, 0, 0, 1, x'', ?);`,
+		item.UUID, len(content), oldCipher.seal(content),
+	); err != nil {
+		t.Fatal(err)
+	}
+
+	password, err := item.Field("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldCiphertext := storedFieldValue(t, vault, item.UUID, password.UID)
+
+	for i := range item.Fields {
+		if item.Fields[i].UID == password.UID {
+			item.Fields[i].Value = "newpassword"
+		}
+	}
+	if _, err := vault.UpdateItem(ctx, item); err != nil {
+		t.Fatal(err)
+	}
+
+	newKey := storedItemKey(t, vault, item.UUID)
+	if bytes.Equal(oldKey, newKey) {
+		t.Fatal("item key was not replaced")
+	}
+	if bytes.Equal(oldKey[itemKeyLength:], newKey[itemKeyLength:]) {
+		t.Fatal("item nonce was not replaced")
+	}
+
+	newCiphertext := storedFieldValue(t, vault, item.UUID, password.UID)
+	if _, err := oldCipher.decrypt(newCiphertext); err == nil {
+		t.Fatal("new value opens under the old item key")
+	}
+	if _, err := oldCipher.decrypt(oldCiphertext); err != nil {
+		t.Fatalf("old value does not open under the old item key: %v", err)
+	}
+
+	updated, err := vault.FindItem(ctx, item.UUID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if field, err := updated.Field("password"); err != nil || field.Value != "newpassword" {
+		t.Fatalf("password is %q, %v", field.Value, err)
+	}
+
+	newCipher, err := newItemCipher(item.UUID, newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entries []struct {
+		Value string `json:"value"`
+	}
+	if err := json.Unmarshal([]byte(storedColumn(t, vault, "history", item.UUID, password.UID)), &entries); err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("history has %d entries", len(entries))
+	}
+	if older, err := newCipher.decrypt(entries[0].Value); err != nil || older != "olderpassword" {
+		t.Fatalf("history value is %q, %v", older, err)
+	}
+
+	r, _, err := vault.OpenAttachment("0a4bb0b8-4c2b-4b1e-9f46-4d6a6f0a0001")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := ioutil.ReadAll(r); !bytes.Equal(got, content) {
+		t.Fatalf("attachment is %q", got)
+	}
+}
+
+func TestUpdateItemKeepsFieldColumns(t *testing.T) {
+	ctx := context.Background()
+	vault := openTestVault(t)
+
+	item, err := vault.FindItem(ctx, "mylogin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// columns Enpass owns, on the unchanged username (10) and the changed password (11)
+	if _, err := vault.db.Exec(`
+UPDATE itemfield SET historical = 0, extra = 'kept', expiry = 42, excluded = 1, strength = 3, pwned_check_time = 7
+WHERE item_uuid = ? AND item_field_uid IN (10, 11);`, item.UUID); err != nil {
+		t.Fatal(err)
+	}
+	var before int
+	if err := vault.db.QueryRow("SELECT ID FROM itemfield WHERE item_uuid = ? AND item_field_uid = 10;", item.UUID).Scan(&before); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range item.Fields {
+		if item.Fields[i].UID == 11 {
+			item.Fields[i].Value = "newpassword"
+		}
+	}
+	if _, err := vault.UpdateItem(ctx, item); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		uid      int
+		column   string
+		expected string
+	}{
+		{10, "ID", strconv.Itoa(before)},
+		{10, "historical", "0"},
+		{10, "extra", "kept"},
+		{10, "expiry", "42"},
+		{10, "excluded", "1"},
+		{10, "strength", "3"},
+		{10, "pwned_check_time", "7"},
+		{11, "extra", "kept"},
+		{11, "expiry", "42"},
+		{11, "excluded", "1"},
+		// results for the old password are reset
+		{11, "strength", "-1"},
+		{11, "pwned_check_time", "0"},
+	}
+	for _, test := range tests {
+		if got := storedColumn(t, vault, test.column, item.UUID, test.uid); got != test.expected {
+			t.Errorf("field %d: %s is %q, expected %q", test.uid, test.column, got, test.expected)
+		}
+	}
+}
+
+func storedItemKey(t *testing.T, vault *Vault, uuid string) []byte {
+	t.Helper()
+
+	var key []byte
+	if err := vault.db.QueryRow("SELECT key FROM item WHERE uuid = ?;", uuid).Scan(&key); err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func storedFieldValue(t *testing.T, vault *Vault, uuid string, uid int) string {
+	t.Helper()
+	return storedColumn(t, vault, "value", uuid, uid)
+}
+
+func storedColumn(t *testing.T, vault *Vault, column string, uuid string, uid int) string {
+	t.Helper()
+
+	var value string
+	if err := vault.db.QueryRow(
+		"SELECT CAST(COALESCE("+column+", '') AS TEXT) FROM itemfield WHERE item_uuid = ? AND item_field_uid = ?;", uuid, uid,
+	).Scan(&value); err != nil {
+		t.Fatal(err)
+	}
+
+	return value
+}
+
+// the sequence of the rotate command: an attacker holding both ciphertexts and the old password
+// must not learn the new one
+func TestRotatedPasswordDoesNotLeak(t *testing.T) {
+	ctx := context.Background()
+	vault := openTestVault(t)
+
+	item, err := vault.FindItem(ctx, "mylogin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	password, err := item.Field("password")
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldKey := storedItemKey(t, vault, item.UUID)
+	oldCiphertext, _ := hex.DecodeString(storedFieldValue(t, vault, item.UUID, password.UID))
+
+	// same length as the old one, so a reused nonce would leak it completely
+	newPassword := "rotatedpwd"
+	for i := range item.Fields {
+		if item.Fields[i].UID == password.UID {
+			item.Fields[i].Value = newPassword
+		}
+	}
+	rotated, err := vault.UpdateItem(ctx, item)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newKey := storedItemKey(t, vault, item.UUID)
+	if bytes.Equal(oldKey, newKey) || !bytes.Equal(rotated.key, newKey) {
+		t.Fatal("rotation kept the item key")
+	}
+
+	newCiphertext, _ := hex.DecodeString(storedFieldValue(t, vault, item.UUID, password.UID))
+	recovered := make([]byte, len(password.Value))
+	for i := range recovered {
+		recovered[i] = oldCiphertext[i] ^ newCiphertext[i] ^ password.Value[i]
+	}
+	if string(recovered) == newPassword {
+		t.Fatal("old ciphertext XOR new ciphertext XOR old password gives the new password")
+	}
+}
+
+func TestCreateItemsIsAllOrNothing(t *testing.T) {
+	ctx := context.Background()
+	vault := openTestVault(t)
+
+	// fails inside the transaction, after the first item was inserted
+	if _, err := vault.db.ExecContext(ctx, `
+CREATE TRIGGER refuse_broken BEFORE INSERT ON item WHEN NEW.title = 'broken'
+BEGIN SELECT RAISE(ABORT, 'refused'); END;`); err != nil {
+		t.Fatal(err)
+	}
+
+	good := Item{Title: "imported", Fields: []Field{{Label: "Password", Type: "password", Value: "secret", Sensitive: true}}}
+	if _, err := vault.CreateItems(ctx, []Item{good, {Title: "broken"}}); err == nil {
+		t.Fatal("CreateItems stored an item the database refused")
+	}
+	if _, err := vault.FindItem(ctx, "imported"); !errors.Is(err, ErrItemNotFound) {
+		t.Fatalf("item of a failed batch: FindItem error %v, want %v", err, ErrItemNotFound)
+	}
+
+	created, err := vault.CreateItems(ctx, []Item{good, {Title: "second"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, title := range []string{"imported", "second"} {
+		item, err := vault.FindItem(ctx, title)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if item.UUID != created[i].UUID {
+			t.Errorf("item %d is %s, stored as %s", i, created[i].UUID, item.UUID)
+		}
+	}
+	if items, _ := vault.GetItems(ctx); vault.Info().VaultNumItems != len(items) {
+		t.Errorf("vault.json counts %d items, the database holds %d", vault.Info().VaultNumItems, len(items))
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+// process exit codes, one per error class
+const (
+	exitOK = 0
+	// unexpected errors, e.g. an unreadable vault
+	exitError = 1
+	// bad flags or arguments, including a change to a vault opened with -read-only or -snapshot
+	exitUsage = 2
+	// wrong master password, keyfile or backup passphrase
+	exitAuth = 3
+	// no item, field or attachment, or more than one item, matched the query
+	exitNotFound = 4
+	// the vault is missing or damaged, or its format is not supported
+	exitUnsupported = 5
+	// audit found more problems than its -threshold, or verify found any
+	exitFindings = 6
+)
+
+// usageError : the command was called with bad flags or arguments
+type usageError struct {
+	msg string
+}
+
+func (e *usageError) Error() string {
+	return e.msg
+}
+
+func usageErrorf(format string, args ...interface{}) error {
+	return &usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// childError : an error reported by a process the command started, with the exit code it chose
+type childError struct {
+	msg  string
+	code int
+}
+
+func (e *childError) Error() string {
+	return e.msg
+}
+
+func exitCode(err error) int {
+	var childErr *childError
+	var usageErr *usageError
+	var unsupportedErr *enpasscli.ErrUnsupportedVault
+	var thresholdErr *thresholdError
+	var problemsErr *problemsError
+
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.As(err, &childErr):
+		return childErr.code
+	case err == flag.ErrHelp, errors.As(err, &usageErr), errors.Is(err, enpasscli.ErrReadOnly):
+		return exitUsage
+	case errors.Is(err, enpasscli.ErrWrongPassword), errors.Is(err, enpasscli.ErrWrongKeyfile),
+		errors.Is(err, enpasscli.ErrBackupPassphrase):
+		return exitAuth
+	case errors.Is(err, enpasscli.ErrItemNotFound), errors.Is(err, enpasscli.ErrAmbiguousMatch),
+		errors.Is(err, enpasscli.ErrFieldNotFound), errors.Is(err, enpasscli.ErrAttachmentNotFound):
+		return exitNotFound
+	case errors.As(err, &unsupportedErr), errors.Is(err, enpasscli.ErrVaultNotFound), errors.Is(err, enpasscli.ErrCorruptVault):
+		return exitUnsupported
+	case errors.As(err, &thresholdErr), errors.As(err, &problemsErr):
+		return exitFindings
+	default:
+		return exitError
+	}
+}
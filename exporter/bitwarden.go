@@ -0,0 +1,227 @@
+package exporter
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	"main/enpasscli"
+)
+
+// Bitwarden item types
+const (
+	bitwardenLogin      = 1
+	bitwardenSecureNote = 2
+	bitwardenCard       = 3
+)
+
+// Bitwarden custom field types
+const (
+	bitwardenTextField   = 0
+	bitwardenHiddenField = 1
+)
+
+// the unencrypted JSON of the Bitwarden "json" export, which its importer reads back
+
+type bitwardenExport struct {
+	Encrypted bool              `json:"encrypted"`
+	Folders   []bitwardenFolder `json:"folders"`
+	Items     []bitwardenItem   `json:"items"`
+}
+
+type bitwardenFolder struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+type bitwardenItem struct {
+	ID             string              `json:"id"`
+	OrganizationID *string             `json:"organizationId"`
+	FolderID       *string             `json:"folderId"`
+	Type           int                 `json:"type"`
+	Reprompt       int                 `json:"reprompt"`
+	Name           string              `json:"name"`
+	Notes          *string             `json:"notes"`
+	Favorite       bool                `json:"favorite"`
+	Fields         []bitwardenField    `json:"fields,omitempty"`
+	Login          *bitwardenLoginData `json:"login,omitempty"`
+	SecureNote     *bitwardenNoteData  `json:"secureNote,omitempty"`
+	Card           *bitwardenCardData  `json:"card,omitempty"`
+	CollectionIDs  []string            `json:"collectionIds"`
+	CreationDate   string              `json:"creationDate"`
+	RevisionDate   string              `json:"revisionDate"`
+}
+
+type bitwardenField struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	Type  int    `json:"type"`
+}
+
+type bitwardenLoginData struct {
+	URIs     []bitwardenURI `json:"uris"`
+	Username *string        `json:"username"`
+	Password *string        `json:"password"`
+	TOTP     *string        `json:"totp"`
+}
+
+type bitwardenURI struct {
+	Match *int   `json:"match"`
+	URI   string `json:"uri"`
+}
+
+type bitwardenNoteData struct {
+	Type int `json:"type"`
+}
+
+type bitwardenCardData struct {
+	CardholderName *string `json:"cardholderName"`
+	Brand          *string `json:"brand"`
+	Number         *string `json:"number"`
+	ExpMonth       *string `json:"expMonth"`
+	ExpYear        *string `json:"expYear"`
+	Code           *string `json:"code"`
+}
+
+func writeBitwardenJSON(w io.Writer, entries []Entry) error {
+	export := bitwardenExport{Folders: []bitwardenFolder{}, Items: make([]bitwardenItem, 0, len(entries))}
+
+	for _, tag := range allTags(entries) {
+		export.Folders = append(export.Folders, bitwardenFolder{ID: tagUUID(tag), Name: tag})
+	}
+
+	for _, entry := range entries {
+		export.Items = append(export.Items, bitwardenExportItem(entry))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(export)
+}
+
+func bitwardenExportItem(entry Entry) bitwardenItem {
+	item := entry.Item
+	std := splitFields(item)
+
+	exported := bitwardenItem{
+		ID:           item.UUID,
+		Name:         item.Title,
+		Notes:        optional(item.Note),
+		Favorite:     item.Favorite,
+		CreationDate: bitwardenTime(item.CreatedAt),
+		RevisionDate: bitwardenTime(item.UpdatedAt),
+	}
+
+	// a Bitwarden item is in one folder
+	if len(item.Tags) > 0 {
+		folder := tagUUID(item.Tags[0])
+		exported.FolderID = &folder
+	}
+
+	custom := std.custom
+	switch item.Category {
+	case "login", "password":
+		exported.Type = bitwardenLogin
+		exported.Login = &bitwardenLoginData{
+			Username: optional(std.username),
+			Password: optional(std.password),
+			TOTP:     optional(std.totp),
+		}
+		if std.url != "" {
+			exported.Login.URIs = []bitwardenURI{{URI: std.url}}
+		}
+		if std.email != "" {
+			exported.Fields = append(exported.Fields, bitwardenField{Name: "Email", Value: std.email, Type: bitwardenTextField})
+		}
+
+	case "creditcard":
+		exported.Type = bitwardenCard
+		exported.Card, custom = bitwardenCardFields(item)
+
+	default:
+		// everything else keeps all of its values as custom fields
+		exported.Type = bitwardenSecureNote
+		exported.SecureNote = &bitwardenNoteData{}
+		custom = nil
+		for _, field := range item.Fields {
+			if field.Value != "" {
+				custom = append(custom, field)
+			}
+		}
+	}
+
+	for _, field := range custom {
+		exported.Fields = append(exported.Fields, bitwardenCustomField(field))
+	}
+
+	if names := attachmentNames(entry.Attachments); len(names) > 0 {
+		exported.Fields = append(exported.Fields, bitwardenField{
+			Name:  "Attachments",
+			Value: strings.Join(names, ", "),
+			Type:  bitwardenTextField,
+		})
+	}
+
+	return exported
+}
+
+// bitwardenCardFields : the Enpass credit card fields Bitwarden has a place for, and the rest
+func bitwardenCardFields(item enpasscli.Item) (*bitwardenCardData, []enpasscli.Field) {
+	card := &bitwardenCardData{}
+	var custom []enpasscli.Field
+
+	for _, field := range item.Fields {
+		if field.Value == "" {
+			continue
+		}
+
+		switch field.Type {
+		case "ccName":
+			card.CardholderName = optional(field.Value)
+		case "ccType":
+			card.Brand = optional(field.Value)
+		case "ccNumber":
+			card.Number = optional(field.Value)
+		case "ccCvc":
+			card.Code = optional(field.Value)
+		case "ccExpiry":
+			// MM/YY or MM/YYYY
+			parts := strings.SplitN(field.Value, "/", 2)
+			card.ExpMonth = optional(strings.TrimLeft(strings.TrimSpace(parts[0]), "0"))
+			if len(parts) == 2 {
+				year := strings.TrimSpace(parts[1])
+				if len(year) == 2 {
+					year = "20" + year
+				}
+				card.ExpYear = optional(year)
+			}
+		default:
+			custom = append(custom, field)
+		}
+	}
+
+	return card, custom
+}
+
+func bitwardenCustomField(field enpasscli.Field) bitwardenField {
+	fieldType := bitwardenTextField
+	if field.Sensitive {
+		fieldType = bitwardenHiddenField
+	}
+
+	return bitwardenField{Name: field.Name(), Value: field.Value, Type: fieldType}
+}
+
+func bitwardenTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+// optional : Bitwarden writes null rather than empty strings
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
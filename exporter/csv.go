@@ -0,0 +1,57 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// csvHeader : one row per item, custom fields as "label: value" lines and lists separated by ";"
+var csvHeader = []string{
+	"uuid", "title", "subtitle", "category", "tags", "favorite",
+	"url", "username", "email", "password", "totp", "note",
+	"fields", "attachments", "created_at", "updated_at",
+}
+
+func writeCSV(w io.Writer, entries []Entry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		item := entry.Item
+		std := splitFields(item)
+
+		custom := make([]string, 0, len(std.custom))
+		for _, field := range std.custom {
+			custom = append(custom, fmt.Sprintf("%s: %s", field.Name(), field.Value))
+		}
+
+		if err := cw.Write([]string{
+			item.UUID,
+			item.Title,
+			item.Subtitle,
+			item.Category,
+			strings.Join(item.Tags, ";"),
+			fmt.Sprint(item.Favorite),
+			std.url,
+			std.username,
+			std.email,
+			std.password,
+			std.totp,
+			item.Note,
+			strings.Join(custom, "\n"),
+			strings.Join(attachmentNames(entry.Attachments), ";"),
+			item.CreatedAt.UTC().Format(time.RFC3339),
+			item.UpdatedAt.UTC().Format(time.RFC3339),
+		}); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
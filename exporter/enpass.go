@@ -0,0 +1,150 @@
+package exporter
+
+import (
+	"encoding/json"
+	"io"
+
+	"main/enpasscli"
+)
+
+// the JSON file the Enpass apps write with File > Export, times are unix seconds
+
+type enpassExport struct {
+	Folders []enpassFolder `json:"folders"`
+	Items   []enpassItem   `json:"items"`
+}
+
+type enpassFolder struct {
+	Icon       string `json:"icon"`
+	ParentUUID string `json:"parent_uuid"`
+	Title      string `json:"title"`
+	UpdatedAt  int64  `json:"updated_at"`
+	UUID       string `json:"uuid"`
+}
+
+type enpassItem struct {
+	Archived     int                `json:"archived"`
+	Attachments  []enpassAttachment `json:"attachments,omitempty"`
+	AutoSubmit   int                `json:"auto_submit"`
+	Category     string             `json:"category"`
+	CreatedAt    int64              `json:"createdAt"`
+	Favorite     int                `json:"favorite"`
+	Fields       []enpassField      `json:"fields"`
+	Folders      []string           `json:"folders,omitempty"`
+	Icon         json.RawMessage    `json:"icon,omitempty"`
+	Note         string             `json:"note"`
+	Subtitle     string             `json:"subtitle"`
+	TemplateType string             `json:"template_type"`
+	Title        string             `json:"title"`
+	Trashed      int                `json:"trashed"`
+	UpdatedAt    int64              `json:"updated_at"`
+	UUID         string             `json:"uuid"`
+}
+
+type enpassField struct {
+	Deleted        int    `json:"deleted"`
+	Label          string `json:"label"`
+	Order          int    `json:"order"`
+	Sensitive      int    `json:"sensitive"`
+	Type           string `json:"type"`
+	UID            int    `json:"uid"`
+	UpdatedAt      int64  `json:"updated_at"`
+	Value          string `json:"value"`
+	ValueUpdatedAt int64  `json:"value_updated_at"`
+}
+
+// enpassAttachment : a reference only, the content is not exported
+type enpassAttachment struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Order     int    `json:"order"`
+	Size      int64  `json:"size"`
+	UpdatedAt int64  `json:"updated_at"`
+	UUID      string `json:"uuid"`
+}
+
+func writeEnpassJSON(w io.Writer, entries []Entry) error {
+	export := enpassExport{Folders: []enpassFolder{}, Items: make([]enpassItem, 0, len(entries))}
+
+	for _, tag := range allTags(entries) {
+		export.Folders = append(export.Folders, enpassFolder{Title: tag, UUID: tagUUID(tag)})
+	}
+
+	for _, entry := range entries {
+		export.Items = append(export.Items, enpassExportItem(entry))
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+	return enc.Encode(export)
+}
+
+func enpassExportItem(entry Entry) enpassItem {
+	item := entry.Item
+	exported := enpassItem{
+		Archived:     boolInt(item.Archived),
+		AutoSubmit:   1,
+		Category:     item.Category,
+		CreatedAt:    item.CreatedAt.Unix(),
+		Favorite:     boolInt(item.Favorite),
+		Fields:       make([]enpassField, 0, len(item.Fields)),
+		Note:         item.Note,
+		Subtitle:     item.Subtitle,
+		TemplateType: item.Template,
+		Title:        item.Title,
+		Trashed:      boolInt(item.Trashed),
+		UpdatedAt:    item.UpdatedAt.Unix(),
+		UUID:         item.UUID,
+	}
+
+	if json.Valid([]byte(item.Icon)) {
+		exported.Icon = json.RawMessage(item.Icon)
+	}
+
+	for _, tag := range item.Tags {
+		exported.Folders = append(exported.Folders, tagUUID(tag))
+	}
+
+	for _, field := range item.Fields {
+		exported.Fields = append(exported.Fields, enpassExportField(item, field))
+	}
+
+	for _, attachment := range entry.Attachments {
+		exported.Attachments = append(exported.Attachments, enpassAttachment{
+			Kind:      attachment.Mime,
+			Name:      attachment.Name,
+			Order:     attachment.Order,
+			Size:      attachment.Size,
+			UpdatedAt: attachment.UpdatedAt.Unix(),
+			UUID:      attachment.UUID,
+		})
+	}
+
+	return exported
+}
+
+func enpassExportField(item enpasscli.Item, field enpasscli.Field) enpassField {
+	updatedAt := field.UpdatedAt
+	if updatedAt.IsZero() {
+		updatedAt = item.UpdatedAt
+	}
+
+	return enpassField{
+		Label:          field.Label,
+		Order:          field.Order,
+		Sensitive:      boolInt(field.Sensitive),
+		Type:           field.Type,
+		UID:            field.UID,
+		UpdatedAt:      updatedAt.Unix(),
+		Value:          field.Value,
+		ValueUpdatedAt: updatedAt.Unix(),
+	}
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+
+	return 0
+}
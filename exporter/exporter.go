@@ -0,0 +1,140 @@
+package exporter
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+// Entry : an item and the attachments it references, exports name attachments but never embed them
+type Entry struct {
+	Item        enpasscli.Item
+	Attachments []enpasscli.Attachment
+}
+
+// writer : writes entries in one export format
+type writer func(w io.Writer, entries []Entry) error
+
+var formats = map[string]writer{
+	"enpass-json": writeEnpassJSON,
+	"keepass":     writeKeePassXML,
+	"bitwarden":   writeBitwardenJSON,
+	"csv":         writeCSV,
+}
+
+// Formats : the names Write accepts
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Write : export entries in format, all secrets are written in plaintext
+func Write(w io.Writer, format string, entries []Entry) error {
+	write, ok := formats[format]
+	if !ok {
+		return errors.Errorf("unknown export format %q, use one of %s", format, strings.Join(Formats(), ", "))
+	}
+
+	return errors.Wrapf(write(w, entries), "could not write %s export", format)
+}
+
+// standardFields : the values most managers keep outside of custom fields
+type standardFields struct {
+	username string
+	email    string
+	password string
+	url      string
+	totp     string
+	// every other field with a value, in item order
+	custom []enpasscli.Field
+}
+
+// splitFields : the first non-empty username, email, password, url and totp fields are standard
+func splitFields(item enpasscli.Item) standardFields {
+	var std standardFields
+	targets := map[string]*string{
+		"username": &std.username,
+		"email":    &std.email,
+		"password": &std.password,
+		"url":      &std.url,
+		"totp":     &std.totp,
+	}
+
+	for _, field := range item.Fields {
+		if target, ok := targets[field.Type]; ok && *target == "" && field.Value != "" {
+			*target = field.Value
+			continue
+		}
+
+		// templates come with empty fields, and section fields are headings without a value
+		if field.Value == "" {
+			continue
+		}
+
+		std.custom = append(std.custom, field)
+	}
+
+	// logins without a username field often only have an email
+	if std.username == "" && std.email != "" {
+		std.username, std.email = std.email, ""
+	}
+
+	return std
+}
+
+// totpURI : the otpauth:// form of a totp field value, unchanged if it cannot be parsed
+func totpURI(item enpasscli.Item, value string) string {
+	params, err := enpasscli.ParseTOTP(value)
+	if err != nil {
+		return value
+	}
+
+	return params.URI(item.Title)
+}
+
+// attachmentNames : the names of the attachments, for formats that can only reference them
+func attachmentNames(attachments []enpasscli.Attachment) []string {
+	names := make([]string, 0, len(attachments))
+	for _, attachment := range attachments {
+		names = append(names, attachment.Name)
+	}
+
+	return names
+}
+
+// tagUUID : a stable uuid for a tag, so the same tag maps to the same folder in every export
+func tagUUID(tag string) string {
+	sum := sha256.Sum256([]byte("enpass-cli tag " + tag))
+	sum[6] = (sum[6] & 0x0f) | 0x50
+	sum[8] = (sum[8] & 0x3f) | 0x80
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", sum[0:4], sum[4:6], sum[6:8], sum[8:10], sum[10:16])
+}
+
+// allTags : every tag used by the entries, sorted
+func allTags(entries []Entry) []string {
+	seen := make(map[string]bool)
+	var tags []string
+	for _, entry := range entries {
+		for _, tag := range entry.Item.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+
+	return tags
+}
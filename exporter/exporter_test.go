@@ -0,0 +1,255 @@
+package exporter
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"main/enpasscli"
+	"main/importer"
+)
+
+var (
+	testCreatedAt = time.Unix(1600000000, 0)
+	testUpdatedAt = time.Unix(1700000000, 0)
+)
+
+func field(label string, fieldType string, value string, sensitive bool) enpasscli.Field {
+	return enpasscli.Field{Label: label, Type: fieldType, Value: value, Sensitive: sensitive}
+}
+
+// testEntries : a login with every standard field, a credit card and a note
+func testEntries() []Entry {
+	return []Entry{
+		{
+			Item: enpasscli.Item{
+				UUID: "9b07477b-5da3-4242-b6de-d2f9d123ceeb", Title: "GitHub", Subtitle: "octo",
+				Category: "login", Template: "login.default", Note: "n", Favorite: true,
+				Tags: []string{"Work", "Dev"}, CreatedAt: testCreatedAt, UpdatedAt: testUpdatedAt,
+				Fields: []enpasscli.Field{
+					field("Username", "username", "octo", false),
+					field("Password", "password", "pw", true),
+					field("Website", "url", "https://github.com", false),
+					field("One-time code", "totp", "JBSWY3DP", true),
+					field("E-mail", "email", "octo@example.com", false),
+					field("Recovery", "text", "abc", true),
+					field("More", "section", "", false),
+				},
+			},
+			Attachments: []enpasscli.Attachment{{UUID: "a1", Name: "key.pem", Mime: "application/x-pem-file", Size: 3}},
+		},
+		{
+			Item: enpasscli.Item{
+				UUID: "6c1f0f9e-0d3b-4f43-9a5e-3d5b1f6c2a10", Title: "Visa", Subtitle: "Jo Doe",
+				Category: "creditcard", Template: "creditcard.default", CreatedAt: testCreatedAt, UpdatedAt: testUpdatedAt,
+				Fields: []enpasscli.Field{
+					field("Cardholder", "ccName", "Jo Doe", false),
+					field("Number", "ccNumber", "4111", false),
+					field("CVC", "ccCvc", "123", true),
+					field("Expiry date", "ccExpiry", "01/30", false),
+					field("Bank", "text", "Acme", false),
+				},
+			},
+		},
+		{
+			Item: enpasscli.Item{
+				UUID: "0d2e6f4a-8b1c-4e7d-9f3a-2c5b8e1d4f60", Title: "Note", Category: "note",
+				Template: "note.default", Note: "text", CreatedAt: testCreatedAt, UpdatedAt: testUpdatedAt,
+				Fields: []enpasscli.Field{field("Code", "text", "42", false)},
+			},
+		},
+	}
+}
+
+// roundTrip : entries exported in format and read back by the importer of that format
+func roundTrip(t *testing.T, format string, importFormat string) []enpasscli.Item {
+	t.Helper()
+
+	var out bytes.Buffer
+	if err := Write(&out, format, testEntries()); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), "export")
+	if err := ioutil.WriteFile(path, out.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	items, err := importer.Read(importFormat, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return items
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	totp, err := enpasscli.ParseTOTP("JBSWY3DP")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// enpass-json keeps every item as it is, but for uuids, times and field ids
+	var enpassItems []enpasscli.Item
+	for _, entry := range testEntries() {
+		item := entry.Item
+		item.UUID, item.CreatedAt, item.UpdatedAt = "", time.Time{}, time.Time{}
+		enpassItems = append(enpassItems, item)
+	}
+
+	tests := []struct {
+		format string
+		want   []enpasscli.Item
+	}{
+		{format: "enpass-json", want: enpassItems},
+		{
+			format: "bitwarden",
+			want: []enpasscli.Item{
+				{
+					Title: "GitHub", Subtitle: "octo", Category: "login", Template: "login.default", Note: "n",
+					Favorite: true, Tags: []string{"Work"},
+					Fields: []enpasscli.Field{
+						field("Username", "username", "octo", false),
+						field("Password", "password", "pw", true),
+						field("Website", "url", "https://github.com", false),
+						field("One-time code", "totp", "JBSWY3DP", true),
+						field("Email", "text", "octo@example.com", false),
+						field("Recovery", "text", "abc", true),
+						field("Attachments", "text", "key.pem", false),
+					},
+				},
+				{
+					Title: "Visa", Subtitle: "Jo Doe", Category: "creditcard", Template: "creditcard.default",
+					Fields: []enpasscli.Field{
+						field("Cardholder", "ccName", "Jo Doe", false),
+						field("Number", "ccNumber", "4111", false),
+						field("CVC", "ccCvc", "123", true),
+						field("Expiry date", "ccExpiry", "01/30", false),
+						field("Bank", "text", "Acme", false),
+					},
+				},
+				{
+					Title: "Note", Category: "note", Template: "note.default", Note: "text",
+					Fields: []enpasscli.Field{field("Code", "text", "42", false)},
+				},
+			},
+		},
+		{
+			// entries without a tag are in the root group, KeePass has no categories
+			format: "keepass",
+			want: []enpasscli.Item{
+				{
+					Title: "Visa", Category: "login", Template: "login.default",
+					Fields: []enpasscli.Field{
+						field("Cardholder", "text", "Jo Doe", false),
+						field("Number", "text", "4111", false),
+						field("CVC", "text", "123", true),
+						field("Expiry date", "text", "01/30", false),
+						field("Bank", "text", "Acme", false),
+					},
+				},
+				{
+					Title: "Note", Category: "login", Template: "login.default", Note: "text",
+					Fields: []enpasscli.Field{field("Code", "text", "42", false)},
+				},
+				{
+					Title: "GitHub", Subtitle: "octo", Category: "login", Template: "login.default", Note: "n",
+					Tags: []string{"Work", "Dev"},
+					Fields: []enpasscli.Field{
+						field("Username", "username", "octo", false),
+						field("E-mail", "email", "octo@example.com", false),
+						field("Password", "password", "pw", true),
+						field("Website", "url", "https://github.com", false),
+						field("One-time code", "totp", totp.URI("GitHub"), true),
+						field("Recovery", "text", "abc", true),
+						field("Attachments", "text", "key.pem", false),
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got := roundTrip(t, tt.format, tt.format)
+			if len(got) != len(tt.want) {
+				t.Fatalf("read back %d items, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if !reflect.DeepEqual(got[i], tt.want[i]) {
+					t.Errorf("item %d:\n got %+v\nwant %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestWriteCSV(t *testing.T) {
+	var out bytes.Buffer
+	if err := Write(&out, "csv", testEntries()); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := csv.NewReader(&out).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const created, updated = "2020-09-13T12:26:40Z", "2023-11-14T22:13:20Z"
+	want := [][]string{
+		csvHeader,
+		{"9b07477b-5da3-4242-b6de-d2f9d123ceeb", "GitHub", "octo", "login", "Work;Dev", "true", "https://github.com",
+			"octo", "octo@example.com", "pw", "JBSWY3DP", "n", "Recovery: abc", "key.pem", created, updated},
+		{"6c1f0f9e-0d3b-4f43-9a5e-3d5b1f6c2a10", "Visa", "Jo Doe", "creditcard", "", "false", "", "", "", "", "", "",
+			"Cardholder: Jo Doe\nNumber: 4111\nCVC: 123\nExpiry date: 01/30\nBank: Acme", "", created, updated},
+		{"0d2e6f4a-8b1c-4e7d-9f3a-2c5b8e1d4f60", "Note", "", "note", "", "false", "", "", "", "", "", "text",
+			"Code: 42", "", created, updated},
+	}
+	if !reflect.DeepEqual(records, want) {
+		t.Errorf("records:\n got %q\nwant %q", records, want)
+	}
+
+	// only the header without entries
+	out.Reset()
+	if err := Write(&out, "csv", nil); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := csv.NewReader(&out).ReadAll(); err != nil || !reflect.DeepEqual(records, [][]string{csvHeader}) {
+		t.Errorf("no entries: records %q, %v", records, err)
+	}
+}
+
+func TestWriteUnknownFormat(t *testing.T) {
+	if err := Write(ioutil.Discard, "roboform", testEntries()); err == nil {
+		t.Error("no error for an unknown format")
+	}
+}
+
+func TestTagUUID(t *testing.T) {
+	uuid := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-5[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+	for _, tag := range []string{"", "Work", "Dev"} {
+		if got := tagUUID(tag); !uuid.MatchString(got) {
+			t.Errorf("tagUUID(%q) = %s, not a version 5 uuid", tag, got)
+		}
+		if tagUUID(tag) != tagUUID(tag) {
+			t.Errorf("tagUUID(%q) changes", tag)
+		}
+	}
+	if tagUUID("Work") == tagUUID("Dev") {
+		t.Error("two tags have the same uuid")
+	}
+}
+
+func TestKeePassItemUUID(t *testing.T) {
+	if got, want := keepassItemUUID("9b07477b-5da3-4242-b6de-d2f9d123ceeb"), "mwdHe12jQkK23tL50SPO6w=="; got != want {
+		t.Errorf("keepassItemUUID = %s, want %s", got, want)
+	}
+	if got := keepassItemUUID("not a uuid"); got != keepassUUID("item not a uuid") {
+		t.Errorf("keepassItemUUID of an invalid uuid = %s", got)
+	}
+}
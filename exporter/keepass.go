@@ -0,0 +1,181 @@
+package exporter
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	// KeePass 2 XML times, always UTC
+	keepassTimeFormat = "2006-01-02T15:04:05Z"
+	// group of items without a tag
+	keepassRootGroup = "Enpass"
+	// KeePassXC keeps one-time passwords in this string field
+	keepassOTPKey = "otp"
+)
+
+// the unencrypted KeePass 2 XML that KeePass and KeePassXC import, one group per first tag
+
+type keepassFile struct {
+	XMLName xml.Name    `xml:"KeePassFile"`
+	Meta    keepassMeta `xml:"Meta"`
+	Root    keepassRoot `xml:"Root"`
+}
+
+type keepassMeta struct {
+	Generator    string `xml:"Generator"`
+	DatabaseName string `xml:"DatabaseName"`
+}
+
+type keepassRoot struct {
+	Group keepassGroup `xml:"Group"`
+}
+
+type keepassGroup struct {
+	UUID    string         `xml:"UUID"`
+	Name    string         `xml:"Name"`
+	Entries []keepassEntry `xml:"Entry"`
+	Groups  []keepassGroup `xml:"Group"`
+}
+
+type keepassEntry struct {
+	UUID    string          `xml:"UUID"`
+	Tags    string          `xml:"Tags,omitempty"`
+	Times   keepassTimes    `xml:"Times"`
+	Strings []keepassString `xml:"String"`
+}
+
+type keepassTimes struct {
+	CreationTime         string `xml:"CreationTime"`
+	LastModificationTime string `xml:"LastModificationTime"`
+}
+
+type keepassString struct {
+	Key   string       `xml:"Key"`
+	Value keepassValue `xml:"Value"`
+}
+
+type keepassValue struct {
+	Protected string `xml:"ProtectInMemory,attr,omitempty"`
+	Text      string `xml:",chardata"`
+}
+
+func writeKeePassXML(w io.Writer, entries []Entry) error {
+	root := keepassGroup{UUID: keepassUUID("group " + keepassRootGroup), Name: keepassRootGroup}
+	groups := make(map[string]int)
+
+	for _, entry := range entries {
+		exported := keepassExportEntry(entry)
+
+		if len(entry.Item.Tags) == 0 {
+			root.Entries = append(root.Entries, exported)
+			continue
+		}
+
+		// a KeePass entry is in one group, all tags are kept in Tags
+		tag := entry.Item.Tags[0]
+		index, ok := groups[tag]
+		if !ok {
+			index = len(root.Groups)
+			groups[tag] = index
+			root.Groups = append(root.Groups, keepassGroup{UUID: keepassUUID("group " + tag), Name: tag})
+		}
+		root.Groups[index].Entries = append(root.Groups[index].Entries, exported)
+	}
+
+	file := keepassFile{
+		Meta: keepassMeta{Generator: "enpass-cli", DatabaseName: keepassRootGroup},
+		Root: keepassRoot{Group: root},
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "\t")
+	if err := enc.Encode(file); err != nil {
+		return err
+	}
+
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+func keepassExportEntry(entry Entry) keepassEntry {
+	item := entry.Item
+	std := splitFields(item)
+
+	exported := keepassEntry{
+		UUID: keepassItemUUID(item.UUID),
+		Tags: strings.Join(item.Tags, ";"),
+		Times: keepassTimes{
+			CreationTime:         keepassTime(item.CreatedAt),
+			LastModificationTime: keepassTime(item.UpdatedAt),
+		},
+	}
+
+	keys := make(map[string]bool)
+	add := func(key string, value string, protected bool) {
+		// string keys are unique within an entry
+		unique := key
+		for n := 2; keys[unique]; n++ {
+			unique = fmt.Sprintf("%s (%d)", key, n)
+		}
+		keys[unique] = true
+
+		str := keepassString{Key: unique, Value: keepassValue{Text: value}}
+		if protected {
+			str.Value.Protected = "True"
+		}
+		exported.Strings = append(exported.Strings, str)
+	}
+
+	add("Title", item.Title, false)
+	add("UserName", std.username, false)
+	add("Password", std.password, true)
+	add("URL", std.url, false)
+	add("Notes", item.Note, false)
+
+	if std.totp != "" {
+		add(keepassOTPKey, totpURI(item, std.totp), true)
+	}
+	if std.email != "" {
+		add("Email", std.email, false)
+	}
+
+	for _, field := range std.custom {
+		add(field.Name(), field.Value, field.Sensitive)
+	}
+
+	if names := attachmentNames(entry.Attachments); len(names) > 0 {
+		add("Attachments", strings.Join(names, "\n"), false)
+	}
+
+	return exported
+}
+
+// keepassItemUUID : KeePass uuids are 16 base64 encoded bytes, Enpass uuids convert directly
+func keepassItemUUID(uuid string) string {
+	raw, err := hex.DecodeString(strings.Replace(uuid, "-", "", -1))
+	if err != nil || len(raw) != 16 {
+		return keepassUUID("item " + uuid)
+	}
+
+	return base64.StdEncoding.EncodeToString(raw)
+}
+
+func keepassUUID(name string) string {
+	sum := sha256.Sum256([]byte("enpass-cli " + name))
+	return base64.StdEncoding.EncodeToString(sum[:16])
+}
+
+func keepassTime(t time.Time) string {
+	return t.UTC().Format(keepassTimeFormat)
+}
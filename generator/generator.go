@@ -0,0 +1,213 @@
+package generator
+
+import (
+	"crypto/rand"
+	"math"
+	"math/big"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// character classes of PasswordOptions
+const (
+	lowerChars  = "abcdefghijklmnopqrstuvwxyz"
+	upperChars  = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digitChars  = "0123456789"
+	symbolChars = "!\"#$%&'()*+,-./:;<=>?@[\\]^_`{|}~"
+	// characters that are easily confused when read or typed
+	ambiguousChars = "Il1|O0o`'\""
+)
+
+const (
+	// default PasswordOptions.Length
+	DefaultLength = 20
+	// default PassphraseOptions.Words, about 77 bits
+	DefaultWords = 6
+	// default PassphraseOptions.Separator
+	DefaultSeparator = "-"
+)
+
+// Generated : a generated secret and its estimated entropy in bits
+type Generated struct {
+	Value   string
+	Entropy float64
+}
+
+// PasswordOptions : settings for Password, a class with a minimum count is always used
+type PasswordOptions struct {
+	Length int
+
+	Lower   bool
+	Upper   bool
+	Digits  bool
+	Symbols bool
+
+	MinLower   int
+	MinUpper   int
+	MinDigits  int
+	MinSymbols int
+
+	// leave out characters like l, 1, O and 0
+	ExcludeAmbiguous bool
+}
+
+// DefaultPasswordOptions : DefaultLength characters from all classes
+func DefaultPasswordOptions() PasswordOptions {
+	return PasswordOptions{Length: DefaultLength, Lower: true, Upper: true, Digits: true, Symbols: true}
+}
+
+type charClass struct {
+	chars string
+	used  bool
+	min   int
+}
+
+// Password : a random password, Entropy assumes every character is drawn from all classes in use
+func Password(opts PasswordOptions) (Generated, error) {
+	if opts.Length <= 0 {
+		return Generated{}, errors.New("password length must be positive")
+	}
+
+	classes := []charClass{
+		{lowerChars, opts.Lower, opts.MinLower},
+		{upperChars, opts.Upper, opts.MinUpper},
+		{digitChars, opts.Digits, opts.MinDigits},
+		{symbolChars, opts.Symbols, opts.MinSymbols},
+	}
+
+	var pool strings.Builder
+	required := 0
+	for i := range classes {
+		if classes[i].min < 0 {
+			return Generated{}, errors.New("minimum character counts cannot be negative")
+		}
+		if opts.ExcludeAmbiguous {
+			classes[i].chars = removeChars(classes[i].chars, ambiguousChars)
+		}
+		if classes[i].used || classes[i].min > 0 {
+			pool.WriteString(classes[i].chars)
+			required += classes[i].min
+		}
+	}
+
+	if pool.Len() == 0 {
+		return Generated{}, errors.New("no character class selected")
+	}
+	if required > opts.Length {
+		return Generated{}, errors.Errorf("minimum character counts add up to %d, more than the length of %d", required, opts.Length)
+	}
+
+	password := make([]byte, 0, opts.Length)
+	for _, class := range classes {
+		for i := 0; i < class.min; i++ {
+			c, err := randomChar(class.chars)
+			if err != nil {
+				return Generated{}, err
+			}
+			password = append(password, c)
+		}
+	}
+
+	all := pool.String()
+	for len(password) < opts.Length {
+		c, err := randomChar(all)
+		if err != nil {
+			return Generated{}, err
+		}
+		password = append(password, c)
+	}
+
+	// the required characters went first, so shuffle them into random positions
+	for i := len(password) - 1; i > 0; i-- {
+		j, err := randomInt(i + 1)
+		if err != nil {
+			return Generated{}, err
+		}
+		password[i], password[j] = password[j], password[i]
+	}
+
+	return Generated{
+		Value:   string(password),
+		Entropy: float64(opts.Length) * math.Log2(float64(len(all))),
+	}, nil
+}
+
+// PassphraseOptions : settings for Passphrase
+type PassphraseOptions struct {
+	Words     int
+	Separator string
+	// upper case the first letter of every word, it adds no entropy
+	Capitalize bool
+}
+
+// DefaultPassphraseOptions : DefaultWords words joined by DefaultSeparator
+func DefaultPassphraseOptions() PassphraseOptions {
+	return PassphraseOptions{Words: DefaultWords, Separator: DefaultSeparator}
+}
+
+// Passphrase : diceware words drawn from the embedded EFF wordlist
+func Passphrase(opts PassphraseOptions) (Generated, error) {
+	if opts.Words <= 0 {
+		return Generated{}, errors.New("passphrase word count must be positive")
+	}
+
+	words := make([]string, opts.Words)
+	for i := range words {
+		n, err := randomInt(len(wordlist))
+		if err != nil {
+			return Generated{}, err
+		}
+
+		words[i] = wordlist[n]
+		if opts.Capitalize {
+			words[i] = capitalize(words[i])
+		}
+	}
+
+	return Generated{
+		Value:   strings.Join(words, opts.Separator),
+		Entropy: float64(opts.Words) * math.Log2(float64(len(wordlist))),
+	}, nil
+}
+
+// Words : a copy of the passphrase wordlist, e.g. for estimating the entropy of passphrases
+func Words() []string {
+	return append([]string(nil), wordlist...)
+}
+
+func randomInt(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, errors.Wrap(err, "could not read random numbers")
+	}
+
+	return int(i.Int64()), nil
+}
+
+func randomChar(chars string) (byte, error) {
+	i, err := randomInt(len(chars))
+	if err != nil {
+		return 0, err
+	}
+
+	return chars[i], nil
+}
+
+func removeChars(chars string, remove string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(remove, r) {
+			return -1
+		}
+		return r
+	}, chars)
+}
+
+func capitalize(word string) string {
+	if word == "" {
+		return word
+	}
+
+	return string(unicode.ToUpper(rune(word[0]))) + word[1:]
+}
@@ -0,0 +1,186 @@
+package generator
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// runs of each randomized test, enough to hit a wrong class or position by chance
+const runs = 200
+
+func countChars(value string, chars string) int {
+	count := 0
+	for _, r := range value {
+		if strings.ContainsRune(chars, r) {
+			count++
+		}
+	}
+
+	return count
+}
+
+func TestPasswordLength(t *testing.T) {
+	for _, length := range []int{1, 8, DefaultLength, 128} {
+		generated, err := Password(PasswordOptions{Length: length, Lower: true, Digits: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(generated.Value) != length {
+			t.Errorf("length %d: password %q has %d characters", length, generated.Value, len(generated.Value))
+		}
+		if want := float64(length) * math.Log2(36); math.Abs(generated.Entropy-want) > 1e-9 {
+			t.Errorf("length %d: entropy %.1f, want %.1f", length, generated.Entropy, want)
+		}
+	}
+}
+
+func TestPasswordClasses(t *testing.T) {
+	tests := []struct {
+		name string
+		opts PasswordOptions
+		// every character is one of these
+		pool string
+		// at least this many of each class
+		min map[string]int
+	}{
+		{name: "lower only", opts: PasswordOptions{Length: 16, Lower: true}, pool: lowerChars},
+		{name: "digits only", opts: PasswordOptions{Length: 16, Digits: true}, pool: digitChars},
+		{
+			name: "minimum of every class",
+			opts: PasswordOptions{Length: 12, Lower: true, MinLower: 3, MinUpper: 3, MinDigits: 3, MinSymbols: 3},
+			pool: lowerChars + upperChars + digitChars + symbolChars,
+			min:  map[string]int{lowerChars: 3, upperChars: 3, digitChars: 3, symbolChars: 3},
+		},
+		{
+			// a minimum count adds its class to the pool
+			name: "minimum of an unselected class",
+			opts: PasswordOptions{Length: 8, Lower: true, MinDigits: 2},
+			pool: lowerChars + digitChars,
+			min:  map[string]int{digitChars: 2},
+		},
+		{
+			name: "minimums fill the password",
+			opts: PasswordOptions{Length: 4, MinUpper: 2, MinSymbols: 2},
+			pool: upperChars + symbolChars,
+			min:  map[string]int{upperChars: 2, symbolChars: 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for i := 0; i < runs; i++ {
+				generated, err := Password(tt.opts)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if other := len(generated.Value) - countChars(generated.Value, tt.pool); other != 0 {
+					t.Fatalf("password %q has %d characters outside its classes", generated.Value, other)
+				}
+				for chars, min := range tt.min {
+					if got := countChars(generated.Value, chars); got < min {
+						t.Fatalf("password %q has %d of %q, want at least %d", generated.Value, got, chars, min)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestPasswordExcludeAmbiguous(t *testing.T) {
+	opts := DefaultPasswordOptions()
+	opts.Length = 64
+	opts.ExcludeAmbiguous = true
+
+	for i := 0; i < runs; i++ {
+		generated, err := Password(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.ContainsAny(generated.Value, ambiguousChars) {
+			t.Fatalf("password %q contains one of %q", generated.Value, ambiguousChars)
+		}
+	}
+
+	// the pool shrinks, so does the entropy
+	generated, err := Password(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := len(removeChars(lowerChars+upperChars+digitChars+symbolChars, ambiguousChars))
+	if want := 64 * math.Log2(float64(pool)); math.Abs(generated.Entropy-want) > 1e-9 {
+		t.Errorf("entropy %.1f, want %.1f", generated.Entropy, want)
+	}
+}
+
+func TestPasswordRejectsImpossibleOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts PasswordOptions
+	}{
+		{name: "zero length", opts: PasswordOptions{Lower: true}},
+		{name: "negative length", opts: PasswordOptions{Length: -1, Lower: true}},
+		{name: "no class", opts: PasswordOptions{Length: 8}},
+		{name: "negative minimum", opts: PasswordOptions{Length: 8, Lower: true, MinDigits: -1}},
+		{name: "minimums longer than the password", opts: PasswordOptions{Length: 4, MinLower: 2, MinDigits: 3}},
+	}
+
+	for _, tt := range tests {
+		if generated, err := Password(tt.opts); err == nil {
+			t.Errorf("%s: generated %q, want an error", tt.name, generated.Value)
+		}
+	}
+}
+
+func TestPassphrase(t *testing.T) {
+	words := make(map[string]bool, len(wordlist))
+	for _, word := range wordlist {
+		words[word] = true
+	}
+
+	// a few words contain "-", the default separator, so the words are split at other separators
+	tests := []PassphraseOptions{
+		{Words: DefaultWords, Separator: "_"},
+		{Words: 1, Separator: " "},
+		{Words: 4, Separator: ".", Capitalize: true},
+		{Words: 10, Separator: "::"},
+	}
+
+	for _, opts := range tests {
+		for i := 0; i < runs; i++ {
+			generated, err := Passphrase(opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			got := strings.Split(generated.Value, opts.Separator)
+			if len(got) != opts.Words {
+				t.Fatalf("%+v: passphrase %q has %d words", opts, generated.Value, len(got))
+			}
+			for _, word := range got {
+				if opts.Capitalize && !unicode.IsUpper(rune(word[0])) {
+					t.Fatalf("%+v: word %q is not capitalized", opts, word)
+				}
+				if !words[strings.ToLower(word)] {
+					t.Fatalf("%+v: %q is not in the wordlist", opts, word)
+				}
+			}
+		}
+
+		generated, err := Passphrase(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := float64(opts.Words) * math.Log2(float64(len(wordlist))); math.Abs(generated.Entropy-want) > 1e-9 {
+			t.Errorf("%+v: entropy %.1f, want %.1f", opts, generated.Entropy, want)
+		}
+	}
+
+	for _, count := range []int{0, -1} {
+		if generated, err := Passphrase(PassphraseOptions{Words: count, Separator: "-"}); err == nil {
+			t.Errorf("%d words: generated %q, want an error", count, generated.Value)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package importer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+// the unencrypted Bitwarden JSON export, folders become tags
+
+type bitwardenExport struct {
+	Encrypted bool `json:"encrypted"`
+	Folders   []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"folders"`
+	Items []bitwardenItem `json:"items"`
+}
+
+type bitwardenItem struct {
+	FolderID string `json:"folderId"`
+	Type     int    `json:"type"`
+	Name     string `json:"name"`
+	Notes    string `json:"notes"`
+	Favorite bool   `json:"favorite"`
+	Fields   []struct {
+		Name  string `json:"name"`
+		Value string `json:"value"`
+		Type  int    `json:"type"`
+	} `json:"fields"`
+	Login *struct {
+		URIs []struct {
+			URI string `json:"uri"`
+		} `json:"uris"`
+		Username string `json:"username"`
+		Password string `json:"password"`
+		TOTP     string `json:"totp"`
+	} `json:"login"`
+	Card *struct {
+		CardholderName string `json:"cardholderName"`
+		Brand          string `json:"brand"`
+		Number         string `json:"number"`
+		ExpMonth       string `json:"expMonth"`
+		ExpYear        string `json:"expYear"`
+		Code           string `json:"code"`
+	} `json:"card"`
+	Identity map[string]*string `json:"identity"`
+}
+
+// Bitwarden item and custom field types
+const (
+	bitwardenLogin       = 1
+	bitwardenCard        = 3
+	bitwardenIdentity    = 4
+	bitwardenHiddenField = 1
+)
+
+// bitwardenIdentityFields : identity keys in the order they are imported, with their Enpass label and type
+var bitwardenIdentityFields = []struct {
+	key, label, fieldType string
+}{
+	{"title", "Title", "text"},
+	{"firstName", "First name", "text"},
+	{"middleName", "Middle name", "text"},
+	{"lastName", "Last name", "text"},
+	{"username", "Username", "username"},
+	{"company", "Company", "text"},
+	{"email", "E-mail", "email"},
+	{"phone", "Phone", "phone"},
+	{"address1", "Address", "text"},
+	{"address2", "Address 2", "text"},
+	{"address3", "Address 3", "text"},
+	{"city", "City", "text"},
+	{"state", "State", "text"},
+	{"postalCode", "Postal code", "text"},
+	{"country", "Country", "text"},
+	{"ssn", "Social security number", "text"},
+	{"passportNumber", "Passport number", "text"},
+	{"licenseNumber", "License number", "text"},
+}
+
+func readBitwardenJSON(path string) ([]enpasscli.Item, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var export bitwardenExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, errors.Wrap(err, "not a Bitwarden JSON export")
+	}
+	if export.Encrypted {
+		return nil, errors.New("encrypted Bitwarden exports are not supported, export as unencrypted JSON")
+	}
+
+	folders := make(map[string]string)
+	for _, folder := range export.Folders {
+		folders[folder.ID] = folder.Name
+	}
+
+	items := make([]enpasscli.Item, 0, len(export.Items))
+	for _, bwItem := range export.Items {
+		item := bitwardenToItem(bwItem)
+		item.Note = bwItem.Notes
+		item.Favorite = bwItem.Favorite
+		item.Tags = appendTag(nil, folders[bwItem.FolderID])
+
+		for _, field := range bwItem.Fields {
+			addFieldSensitive(&item, field.Name, "text", field.Value, field.Type == bitwardenHiddenField)
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func bitwardenToItem(bwItem bitwardenItem) enpasscli.Item {
+	switch {
+	case bwItem.Type == bitwardenLogin && bwItem.Login != nil:
+		login := bwItem.Login
+		var uri string
+		if len(login.URIs) > 0 {
+			uri = login.URIs[0].URI
+		}
+
+		item := newLogin(bwItem.Name, login.Username, "", login.Password, uri, login.TOTP)
+		for i := 1; i < len(login.URIs); i++ {
+			addField(&item, "Website", "url", login.URIs[i].URI)
+		}
+		return item
+
+	case bwItem.Type == bitwardenCard && bwItem.Card != nil:
+		card := bwItem.Card
+		item := enpasscli.Item{Title: bwItem.Name, Category: "creditcard", Template: "creditcard.default"}
+		addField(&item, "Cardholder", "ccName", card.CardholderName)
+		addField(&item, "Type", "ccType", card.Brand)
+		addField(&item, "Number", "ccNumber", card.Number)
+		addField(&item, "CVC", "ccCvc", card.Code)
+		if card.ExpMonth != "" || card.ExpYear != "" {
+			addField(&item, "Expiry date", "ccExpiry", expiry(card.ExpMonth, card.ExpYear))
+		}
+		item.Subtitle = card.CardholderName
+		return item
+
+	case bwItem.Type == bitwardenIdentity && bwItem.Identity != nil:
+		item := enpasscli.Item{Title: bwItem.Name, Category: "identity", Template: "identity.default"}
+		for _, field := range bitwardenIdentityFields {
+			if value := bwItem.Identity[field.key]; value != nil {
+				addField(&item, field.label, field.fieldType, *value)
+			}
+		}
+		return item
+
+	default:
+		return enpasscli.Item{Title: bwItem.Name, Category: "note", Template: "note.default"}
+	}
+}
+
+// expiry : the MM/YY form Enpass uses for credit cards
+func expiry(month string, year string) string {
+	if len(month) == 1 {
+		month = "0" + month
+	}
+	if len(year) == 4 {
+		year = year[2:]
+	}
+
+	return strings.TrimSpace(month + "/" + year)
+}
@@ -0,0 +1,95 @@
+package importer
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"sort"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+// the JSON file the Enpass apps write with File > Export, and enpass export -to enpass-json
+
+type enpassExport struct {
+	Folders []struct {
+		Title string `json:"title"`
+		UUID  string `json:"uuid"`
+	} `json:"folders"`
+	Items []struct {
+		Archived     int      `json:"archived"`
+		Category     string   `json:"category"`
+		Favorite     int      `json:"favorite"`
+		Folders      []string `json:"folders"`
+		Note         string   `json:"note"`
+		Subtitle     string   `json:"subtitle"`
+		TemplateType string   `json:"template_type"`
+		Title        string   `json:"title"`
+		Trashed      int      `json:"trashed"`
+		Fields       []struct {
+			Deleted   int    `json:"deleted"`
+			Label     string `json:"label"`
+			Order     int    `json:"order"`
+			Sensitive int    `json:"sensitive"`
+			Type      string `json:"type"`
+			Value     string `json:"value"`
+		} `json:"fields"`
+	} `json:"items"`
+}
+
+func readEnpassJSON(path string) ([]enpasscli.Item, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var export enpassExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, errors.Wrap(err, "not an Enpass JSON export")
+	}
+
+	folders := make(map[string]string)
+	for _, folder := range export.Folders {
+		folders[folder.UUID] = folder.Title
+	}
+
+	items := make([]enpasscli.Item, 0, len(export.Items))
+	for _, exported := range export.Items {
+		item := enpasscli.Item{
+			Title:    exported.Title,
+			Subtitle: exported.Subtitle,
+			Category: exported.Category,
+			Template: exported.TemplateType,
+			Note:     exported.Note,
+			Favorite: exported.Favorite == 1,
+			Archived: exported.Archived == 1,
+			Trashed:  exported.Trashed == 1,
+		}
+
+		for _, uuid := range exported.Folders {
+			item.Tags = appendTag(item.Tags, folders[uuid])
+		}
+
+		fields := exported.Fields
+		sort.SliceStable(fields, func(i, j int) bool { return fields[i].Order < fields[j].Order })
+
+		// empty fields are kept, they are the template of the item
+		for _, field := range fields {
+			if field.Deleted == 1 {
+				continue
+			}
+
+			item.Fields = append(item.Fields, enpasscli.Field{
+				Label:     field.Label,
+				Type:      field.Type,
+				Value:     field.Value,
+				Sensitive: field.Sensitive == 1,
+			})
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
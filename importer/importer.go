@@ -0,0 +1,258 @@
+package importer
+
+import (
+	"context"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+// reader : parses the export file at path into new items, without uuids
+type reader func(path string) ([]enpasscli.Item, error)
+
+var formats = map[string]reader{
+	"keepass":        readKeePassXML,
+	"bitwarden":      readBitwardenJSON,
+	"1password-1pux": read1PUX,
+	"lastpass-csv":   readLastPassCSV,
+	"enpass-json":    readEnpassJSON,
+}
+
+// Formats : the names Read accepts
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for name := range formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// Read : the items of an export file of another password manager, mapped to Enpass categories and field types
+func Read(format string, path string) ([]enpasscli.Item, error) {
+	read, ok := formats[format]
+	if !ok {
+		return nil, errors.Errorf("unknown import format %q, use one of %s", format, strings.Join(Formats(), ", "))
+	}
+
+	items, err := read(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "could not read %s import", format)
+	}
+
+	return items, nil
+}
+
+// Action : what Apply does with an imported item
+type Action string
+
+const (
+	ActionCreate Action = "create"
+	ActionSkip   Action = "skip"
+)
+
+// Step : one imported item and what happens to it
+type Step struct {
+	Action Action
+	Item   enpasscli.Item
+	Reason string
+	// uuid of the existing item a skipped item duplicates, empty for duplicates within the import
+	DuplicateOf string
+}
+
+// Plan : the steps of an import, it changes nothing until applied
+type Plan struct {
+	Steps []Step
+}
+
+// NewPlan : create every imported item unless an existing or earlier imported item has the same url and
+// username, items in the trash are not duplicates
+func NewPlan(imported []enpasscli.Item, existing []enpasscli.Item) Plan {
+	seen := make(map[string]string)
+	for _, item := range existing {
+		if item.Trashed {
+			continue
+		}
+		if key := dedupeKey(item); key != "" {
+			seen[key] = item.UUID
+		}
+	}
+
+	plan := Plan{Steps: make([]Step, 0, len(imported))}
+	for _, item := range imported {
+		step := Step{Action: ActionCreate, Item: item}
+
+		if strings.TrimSpace(item.Title) == "" {
+			step.Action, step.Reason = ActionSkip, "no title"
+		} else if key := dedupeKey(item); key != "" {
+			if uuid, ok := seen[key]; ok {
+				step.Action, step.DuplicateOf = ActionSkip, uuid
+				step.Reason = "same url and username as an existing item"
+				if uuid == "" {
+					step.Reason = "same url and username as an earlier imported item"
+				}
+			} else {
+				seen[key] = ""
+			}
+		}
+
+		plan.Steps = append(plan.Steps, step)
+	}
+
+	return plan
+}
+
+// Count : the number of steps with action
+func (p Plan) Count(action Action) int {
+	count := 0
+	for _, step := range p.Steps {
+		if step.Action == action {
+			count++
+		}
+	}
+
+	return count
+}
+
+// ItemCreator : the part of the vault write API an import needs
+type ItemCreator interface {
+	CreateItems(ctx context.Context, items []enpasscli.Item) ([]enpasscli.Item, error)
+}
+
+// Apply : create the items of the plan in one transaction, so a failure leaves the vault as it was;
+// the created items are returned in plan order
+func (p Plan) Apply(ctx context.Context, vault ItemCreator) ([]enpasscli.Item, error) {
+	var items []enpasscli.Item
+	for _, step := range p.Steps {
+		if step.Action == ActionCreate {
+			items = append(items, step.Item)
+		}
+	}
+
+	return vault.CreateItems(ctx, items)
+}
+
+// dedupeKey : normalized url and username, empty unless the item has both
+func dedupeKey(item enpasscli.Item) string {
+	var rawURL, username string
+	for _, field := range item.Fields {
+		switch {
+		case field.Type == "url" && rawURL == "":
+			rawURL = field.Value
+		case field.Type == "username" && username == "":
+			username = field.Value
+		}
+	}
+	if username == "" {
+		for _, field := range item.Fields {
+			if field.Type == "email" && field.Value != "" {
+				username = field.Value
+				break
+			}
+		}
+	}
+
+	normalized := normalizeURL(rawURL)
+	username = strings.ToLower(strings.TrimSpace(username))
+	if normalized == "" || username == "" {
+		return ""
+	}
+
+	return normalized + "\x00" + username
+}
+
+// normalizeURL : host and path in lower case, so http/https, www. and trailing slashes do not matter
+func normalizeURL(rawURL string) string {
+	rawURL = strings.TrimSpace(rawURL)
+	if rawURL == "" {
+		return ""
+	}
+	if !strings.Contains(rawURL, "://") {
+		rawURL = "https://" + rawURL
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return strings.ToLower(rawURL)
+	}
+
+	host := strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+	return host + strings.TrimRight(strings.ToLower(u.EscapedPath()), "/")
+}
+
+// sensitiveTypes : Enpass field types whose values are encrypted and masked
+var sensitiveTypes = map[string]bool{
+	"password":      true,
+	"totp":          true,
+	"ccCvc":         true,
+	"ccPin":         true,
+	"ccTxnpassword": true,
+}
+
+// addField : append a field unless its value is empty
+func addField(item *enpasscli.Item, label string, fieldType string, value string) {
+	addFieldSensitive(item, label, fieldType, value, sensitiveTypes[fieldType])
+}
+
+func addFieldSensitive(item *enpasscli.Item, label string, fieldType string, value string, sensitive bool) {
+	if strings.TrimSpace(value) == "" {
+		return
+	}
+
+	item.Fields = append(item.Fields, enpasscli.Field{
+		Label:     label,
+		Type:      fieldType,
+		Value:     value,
+		Sensitive: sensitive || sensitiveTypes[fieldType],
+	})
+}
+
+// newLogin : a login item, Enpass shows the username, or else the email, as subtitle
+func newLogin(title string, username string, email string, password string, rawURL string, totp string) enpasscli.Item {
+	item := enpasscli.Item{Title: title, Category: "login", Template: "login.default"}
+	addField(&item, "Username", "username", username)
+	addField(&item, "E-mail", "email", email)
+	addField(&item, "Password", "password", password)
+	addField(&item, "Website", "url", rawURL)
+	addField(&item, "One-time code", "totp", totp)
+
+	item.Subtitle = username
+	if item.Subtitle == "" {
+		item.Subtitle = email
+	}
+
+	return item
+}
+
+// splitTags : tags separated by commas or semicolons, without empty entries
+func splitTags(value string) []string {
+	var tags []string
+	for _, tag := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == ';' }) {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = appendTag(tags, tag)
+		}
+	}
+
+	return tags
+}
+
+// appendTag : add tag unless it is empty or already present
+func appendTag(tags []string, tag string) []string {
+	tag = strings.TrimSpace(tag)
+	if tag == "" {
+		return tags
+	}
+
+	for _, existing := range tags {
+		if existing == tag {
+			return tags
+		}
+	}
+
+	return append(tags, tag)
+}
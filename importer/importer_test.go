@@ -0,0 +1,384 @@
+package importer
+
+import (
+	"archive/zip"
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"main/enpasscli"
+)
+
+// writeImport : an export file with contents, removed when the test ends
+func writeImport(t *testing.T, name string, contents string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	if err := ioutil.WriteFile(path, []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+// write1PUX : a .1pux archive holding data as its export.data
+func write1PUX(t *testing.T, data string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "export.1pux")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	archive := zip.NewWriter(f)
+	w, err := archive.Create(onePuxDataFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return path
+}
+
+func field(label string, fieldType string, value string, sensitive bool) enpasscli.Field {
+	return enpasscli.Field{Label: label, Type: fieldType, Value: value, Sensitive: sensitive}
+}
+
+const bitwardenFixture = `{"encrypted": false, "folders": [{"id": "f1", "name": "Work"}], "items": [
+{"folderId": "f1", "type": 1, "name": "GitHub", "notes": "recovery codes in the safe", "favorite": true,
+ "fields": [{"name": "Recovery", "value": "abc", "type": 1}, {"name": "Team", "value": "core", "type": 0}],
+ "login": {"uris": [{"uri": "https://github.com"}, {"uri": "https://gist.github.com"}], "username": "octo",
+  "password": "pw", "totp": "JBSWY3DP"}},
+{"type": 3, "name": "Visa", "card": {"cardholderName": "Jo Doe", "brand": "Visa", "number": "4111",
+ "expMonth": "1", "expYear": "2030", "code": "123"}},
+{"type": 4, "name": "Me", "identity": {"firstName": "Jo", "email": "jo@example.com", "phone": null}},
+{"type": 2, "name": "Secure note", "notes": "text"}]}`
+
+const enpassFixture = `{"folders": [{"title": "Personal", "uuid": "u1"}], "items": [
+{"archived": 1, "category": "login", "favorite": 1, "folders": ["u1"], "note": "n", "subtitle": "me",
+ "template_type": "login.default", "title": "Mail", "trashed": 0, "fields": [
+  {"deleted": 0, "label": "Password", "order": 2, "sensitive": 1, "type": "password", "value": "pw"},
+  {"deleted": 0, "label": "Username", "order": 1, "sensitive": 0, "type": "username", "value": "me"},
+  {"deleted": 1, "label": "Old", "order": 3, "sensitive": 0, "type": "text", "value": "x"},
+  {"deleted": 0, "label": "Website", "order": 4, "sensitive": 0, "type": "url", "value": ""}]}]}`
+
+const keepassFixture = `<?xml version="1.0" encoding="utf-8" standalone="yes"?>
+<KeePassFile><Meta><RecycleBinUUID>bin</RecycleBinUUID></Meta><Root><Group><UUID>root</UUID><Name>Root</Name>
+<Entry><Tags>bank;money</Tags>
+ <String><Key>Title</Key><Value>Bank</Value></String>
+ <String><Key>UserName</Key><Value>me</Value></String>
+ <String><Key>Password</Key><Value ProtectInMemory="True">pw</Value></String>
+ <String><Key>URL</Key><Value>bank.example</Value></String>
+ <String><Key>Notes</Key><Value>n</Value></String>
+ <String><Key>otp</Key><Value>otpauth://totp/bank?secret=JBSWY3DP</Value></String>
+ <String><Key>PIN</Key><Value ProtectInMemory="True">1234</Value></String>
+ <String><Key>Branch</Key><Value>Main street</Value></String>
+</Entry>
+<Group><UUID>g1</UUID><Name>Email</Name><Entry>
+ <String><Key>Title</Key><Value>Mail</Value></String>
+ <String><Key>Email</Key><Value>me@example.com</Value></String>
+</Entry></Group>
+<Group><UUID>bin</UUID><Name>Recycle Bin</Name><Entry>
+ <String><Key>Title</Key><Value>Deleted</Value></String>
+</Entry></Group>
+</Group></Root></KeePassFile>`
+
+const lastPassFixture = "\ufeffurl,username,password,totp,extra,name,grouping,fav\n" +
+	"https://example.com,me,pw,,notes here,Example,Work\\Dev,1\n" +
+	"http://sn,,,,secret note,Note,,0\n"
+
+const onePuxFixture = `{"accounts": [{"vaults": [{"items": [
+{"favIndex": 1, "state": "active", "categoryUuid": "001", "details": {
+  "loginFields": [{"value": "me", "name": "username", "designation": "username"},
+   {"value": "pw", "name": "password", "designation": "password"}],
+  "notesPlain": "n",
+  "sections": [{"title": "", "fields": [
+   {"title": "Recovery", "id": "r", "value": {"concealed": "abc"}},
+   {"title": "", "id": "mail", "value": {"email": {"email_address": "me@example.com", "provider": null}}}]}]},
+ "overview": {"title": "Site", "url": "https://site.example",
+  "urls": [{"url": "https://site.example"}, {"url": "https://login.site.example"}], "tags": ["Work", "Work"]}},
+{"item": {"state": "archived", "categoryUuid": "002", "details": {"sections": [{"fields": [
+   {"title": "Cardholder", "id": "cardholder", "value": {"string": "Jo Doe"}},
+   {"title": "Number", "id": "ccnum", "value": {"creditCardNumber": "4111"}},
+   {"title": "Expiry", "id": "expiry", "value": {"monthYear": 203001}},
+   {"title": "CVV", "id": "cvv", "value": {"concealed": "123"}}]}]},
+ "overview": {"title": "Visa"}}}]}]}]}`
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		format string
+		path   func(t *testing.T) string
+		want   []enpasscli.Item
+	}{
+		{
+			format: "bitwarden",
+			path:   func(t *testing.T) string { return writeImport(t, "bitwarden.json", bitwardenFixture) },
+			want: []enpasscli.Item{
+				{
+					Title: "GitHub", Subtitle: "octo", Category: "login", Template: "login.default",
+					Note: "recovery codes in the safe", Favorite: true, Tags: []string{"Work"},
+					Fields: []enpasscli.Field{
+						field("Username", "username", "octo", false),
+						field("Password", "password", "pw", true),
+						field("Website", "url", "https://github.com", false),
+						field("One-time code", "totp", "JBSWY3DP", true),
+						field("Website", "url", "https://gist.github.com", false),
+						field("Recovery", "text", "abc", true),
+						field("Team", "text", "core", false),
+					},
+				},
+				{
+					Title: "Visa", Subtitle: "Jo Doe", Category: "creditcard", Template: "creditcard.default",
+					Fields: []enpasscli.Field{
+						field("Cardholder", "ccName", "Jo Doe", false),
+						field("Type", "ccType", "Visa", false),
+						field("Number", "ccNumber", "4111", false),
+						field("CVC", "ccCvc", "123", true),
+						field("Expiry date", "ccExpiry", "01/30", false),
+					},
+				},
+				{
+					Title: "Me", Category: "identity", Template: "identity.default",
+					Fields: []enpasscli.Field{
+						field("First name", "text", "Jo", false),
+						field("E-mail", "email", "jo@example.com", false),
+					},
+				},
+				{Title: "Secure note", Category: "note", Template: "note.default", Note: "text"},
+			},
+		},
+		{
+			format: "enpass-json",
+			path:   func(t *testing.T) string { return writeImport(t, "enpass.json", enpassFixture) },
+			want: []enpasscli.Item{
+				{
+					Title: "Mail", Subtitle: "me", Category: "login", Template: "login.default", Note: "n",
+					Favorite: true, Archived: true, Tags: []string{"Personal"},
+					Fields: []enpasscli.Field{
+						field("Username", "username", "me", false),
+						field("Password", "password", "pw", true),
+						field("Website", "url", "", false),
+					},
+				},
+			},
+		},
+		{
+			format: "keepass",
+			path:   func(t *testing.T) string { return writeImport(t, "keepass.xml", keepassFixture) },
+			want: []enpasscli.Item{
+				{
+					Title: "Bank", Subtitle: "me", Category: "login", Template: "login.default", Note: "n",
+					Tags: []string{"bank", "money"},
+					Fields: []enpasscli.Field{
+						field("Username", "username", "me", false),
+						field("Password", "password", "pw", true),
+						field("Website", "url", "bank.example", false),
+						field("One-time code", "totp", "otpauth://totp/bank?secret=JBSWY3DP", true),
+						field("PIN", "text", "1234", true),
+						field("Branch", "text", "Main street", false),
+					},
+				},
+				{
+					Title: "Mail", Subtitle: "me@example.com", Category: "login", Template: "login.default",
+					Tags:   []string{"Email"},
+					Fields: []enpasscli.Field{field("E-mail", "email", "me@example.com", false)},
+				},
+			},
+		},
+		{
+			format: "lastpass-csv",
+			path:   func(t *testing.T) string { return writeImport(t, "lastpass.csv", lastPassFixture) },
+			want: []enpasscli.Item{
+				{
+					Title: "Example", Subtitle: "me", Category: "login", Template: "login.default",
+					Note: "notes here", Favorite: true, Tags: []string{"Work/Dev"},
+					Fields: []enpasscli.Field{
+						field("Username", "username", "me", false),
+						field("Password", "password", "pw", true),
+						field("Website", "url", "https://example.com", false),
+					},
+				},
+				{Title: "Note", Category: "note", Template: "note.default", Note: "secret note"},
+			},
+		},
+		{
+			format: "1password-1pux",
+			path:   func(t *testing.T) string { return write1PUX(t, onePuxFixture) },
+			want: []enpasscli.Item{
+				{
+					Title: "Site", Subtitle: "me", Category: "login", Template: "login.default", Note: "n",
+					Favorite: true, Tags: []string{"Work"},
+					Fields: []enpasscli.Field{
+						field("Username", "username", "me", false),
+						field("Password", "password", "pw", true),
+						field("Website", "url", "https://site.example", false),
+						field("Website", "url", "https://login.site.example", false),
+						field("Recovery", "text", "abc", true),
+						field("mail", "email", "me@example.com", false),
+					},
+				},
+				{
+					Title: "Visa", Category: "creditcard", Template: "creditcard.default", Archived: true,
+					Fields: []enpasscli.Field{
+						field("Cardholder", "ccName", "Jo Doe", false),
+						field("Number", "ccNumber", "4111", true),
+						field("Expiry", "ccExpiry", "01/30", false),
+						field("CVV", "ccCvc", "123", true),
+					},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			got, err := Read(tt.format, tt.path(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("read %d items, want %d: %+v", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if !reflect.DeepEqual(got[i], tt.want[i]) {
+					t.Errorf("item %d:\n got %+v\nwant %+v", i, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestReadErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		path   func(t *testing.T) string
+	}{
+		{name: "unknown format", format: "roboform", path: func(t *testing.T) string { return writeImport(t, "x", "") }},
+		{name: "missing file", format: "bitwarden", path: func(t *testing.T) string { return filepath.Join(t.TempDir(), "missing") }},
+		{name: "encrypted bitwarden", format: "bitwarden", path: func(t *testing.T) string {
+			return writeImport(t, "bitwarden.json", `{"encrypted": true, "items": []}`)
+		}},
+		{name: "not json", format: "enpass-json", path: func(t *testing.T) string { return writeImport(t, "enpass.json", "<xml/>") }},
+		{name: "not xml", format: "keepass", path: func(t *testing.T) string { return writeImport(t, "keepass.xml", "{}") }},
+		{name: "lastpass without name column", format: "lastpass-csv", path: func(t *testing.T) string {
+			return writeImport(t, "lastpass.csv", "url,username,password\n")
+		}},
+		{name: "not a zip", format: "1password-1pux", path: func(t *testing.T) string { return writeImport(t, "export.1pux", "{}") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if items, err := Read(tt.format, tt.path(t)); err == nil {
+				t.Errorf("Read = %+v, want an error", items)
+			}
+		})
+	}
+}
+
+// login : an item with the given url, username and email fields, empty ones left out
+func login(uuid string, title string, rawURL string, username string, email string) enpasscli.Item {
+	item := enpasscli.Item{UUID: uuid, Title: title, Category: "login"}
+	addField(&item, "Website", "url", rawURL)
+	addField(&item, "Username", "username", username)
+	addField(&item, "E-mail", "email", email)
+
+	return item
+}
+
+func TestNewPlan(t *testing.T) {
+	trashed := login("e2", "Trashed", "trash.example", "me", "")
+	trashed.Trashed = true
+	existing := []enpasscli.Item{
+		login("e1", "Example", "https://www.Example.com/", "Me", ""),
+		trashed,
+		login("e3", "No username", "nouser.example", "", ""),
+		login("e4", "No url", "", "me", ""),
+	}
+
+	tests := []struct {
+		item        enpasscli.Item
+		action      Action
+		duplicateOf string
+	}{
+		{item: login("", " ", "other.example", "me", ""), action: ActionSkip},
+		{item: login("", "Same url and username", "http://example.com", " me ", ""), action: ActionSkip, duplicateOf: "e1"},
+		{item: login("", "Same as a trashed item", "trash.example", "me", ""), action: ActionCreate},
+		{item: login("", "Same url, no username", "nouser.example", "", ""), action: ActionCreate},
+		{item: login("", "Same username, no url", "", "me", ""), action: ActionCreate},
+		{item: login("", "Email as username", "site.example/login", "", "Me@Site.example"), action: ActionCreate},
+		{item: login("", "Same as an earlier import", "https://site.example/login/", "me@site.example", ""), action: ActionSkip},
+		{item: login("", "Same url, other username", "https://site.example/login", "you", ""), action: ActionCreate},
+		{item: login("", "Same url, no username again", "nouser.example", "", ""), action: ActionCreate},
+	}
+
+	imported := make([]enpasscli.Item, 0, len(tests))
+	for _, tt := range tests {
+		imported = append(imported, tt.item)
+	}
+
+	plan := NewPlan(imported, existing)
+	if len(plan.Steps) != len(tests) {
+		t.Fatalf("%d steps, want %d", len(plan.Steps), len(tests))
+	}
+	for i, tt := range tests {
+		step := plan.Steps[i]
+		if step.Action != tt.action || step.DuplicateOf != tt.duplicateOf {
+			t.Errorf("%q: %s of %q, want %s of %q", tt.item.Title, step.Action, step.DuplicateOf, tt.action, tt.duplicateOf)
+		}
+		if step.Action == ActionSkip && step.Reason == "" {
+			t.Errorf("%q: skipped without a reason", tt.item.Title)
+		}
+		if !reflect.DeepEqual(step.Item, tt.item) {
+			t.Errorf("%q: step item %+v", tt.item.Title, step.Item)
+		}
+	}
+
+	if got := plan.Count(ActionCreate); got != 6 {
+		t.Errorf("Count(create) = %d, want 6", got)
+	}
+	if got := plan.Count(ActionSkip); got != 3 {
+		t.Errorf("Count(skip) = %d, want 3", got)
+	}
+}
+
+type fakeCreator struct {
+	created []enpasscli.Item
+}
+
+func (c *fakeCreator) CreateItems(ctx context.Context, items []enpasscli.Item) ([]enpasscli.Item, error) {
+	c.created = append(c.created, items...)
+	return items, nil
+}
+
+func TestPlanApply(t *testing.T) {
+	plan := NewPlan([]enpasscli.Item{
+		login("", "first", "a.example", "me", ""),
+		login("", "", "b.example", "me", ""),
+		login("", "second", "c.example", "me", ""),
+		login("", "duplicate", "a.example", "me", ""),
+	}, nil)
+
+	var creator fakeCreator
+	if _, err := plan.Apply(context.Background(), &creator); err != nil {
+		t.Fatal(err)
+	}
+
+	var titles []string
+	for _, item := range creator.created {
+		titles = append(titles, item.Title)
+	}
+	if !reflect.DeepEqual(titles, []string{"first", "second"}) {
+		t.Errorf("created %q, want first and second", titles)
+	}
+}
@@ -0,0 +1,114 @@
+package importer
+
+import (
+	"encoding/xml"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+// the unencrypted KeePass 2 XML export, groups become tags and the recycle bin is left out
+
+type keepassFile struct {
+	Meta struct {
+		RecycleBinUUID string `xml:"RecycleBinUUID"`
+	} `xml:"Meta"`
+	Root struct {
+		Groups []keepassGroup `xml:"Group"`
+	} `xml:"Root"`
+}
+
+type keepassGroup struct {
+	UUID    string         `xml:"UUID"`
+	Name    string         `xml:"Name"`
+	Entries []keepassEntry `xml:"Entry"`
+	Groups  []keepassGroup `xml:"Group"`
+}
+
+type keepassEntry struct {
+	Tags    string          `xml:"Tags"`
+	Strings []keepassString `xml:"String"`
+}
+
+type keepassString struct {
+	Key   string `xml:"Key"`
+	Value struct {
+		Protected string `xml:"ProtectInMemory,attr"`
+		Text      string `xml:",chardata"`
+	} `xml:"Value"`
+}
+
+// keepassOTPKeys : where KeePassXC and the KeeOtp plugins keep one-time password secrets, in order of preference
+var keepassOTPKeys = []string{"otp", "TimeOtp-Secret-Base32", "TOTP Seed"}
+
+func readKeePassXML(path string) ([]enpasscli.Item, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var file keepassFile
+	if err := xml.NewDecoder(f).Decode(&file); err != nil {
+		return nil, errors.Wrap(err, "not a KeePass 2 XML file")
+	}
+
+	var items []enpasscli.Item
+	for _, root := range file.Root.Groups {
+		// entries of the root group have no tag
+		items = appendKeePassGroup(items, root, "", file.Meta.RecycleBinUUID)
+	}
+
+	return items, nil
+}
+
+func appendKeePassGroup(items []enpasscli.Item, group keepassGroup, tag string, recycleBin string) []enpasscli.Item {
+	if recycleBin != "" && group.UUID == recycleBin {
+		return items
+	}
+
+	for _, entry := range group.Entries {
+		items = append(items, keepassItem(entry, tag))
+	}
+
+	for _, child := range group.Groups {
+		items = appendKeePassGroup(items, child, child.Name, recycleBin)
+	}
+
+	return items
+}
+
+func keepassItem(entry keepassEntry, tag string) enpasscli.Item {
+	values := make(map[string]string)
+	for _, str := range entry.Strings {
+		values[str.Key] = str.Value.Text
+	}
+
+	var totp string
+	for _, key := range keepassOTPKeys {
+		if totp = values[key]; totp != "" {
+			break
+		}
+	}
+
+	item := newLogin(values["Title"], values["UserName"], values["Email"], values["Password"], values["URL"], totp)
+	item.Note = values["Notes"]
+	item.Tags = appendTag(splitTags(entry.Tags), tag)
+
+	for _, str := range entry.Strings {
+		switch str.Key {
+		case "Title", "UserName", "Email", "Password", "URL", "Notes", "TOTP Seed", "TOTP Settings":
+			continue
+		}
+		if str.Key == "otp" || strings.HasPrefix(str.Key, "TimeOtp-") {
+			continue
+		}
+
+		addFieldSensitive(&item, str.Key, "text", str.Value.Text, strings.EqualFold(str.Value.Protected, "true"))
+	}
+
+	return item
+}
@@ -0,0 +1,78 @@
+package importer
+
+import (
+	"encoding/csv"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+// LastPass marks secure notes with this url
+const lastPassNoteURL = "http://sn"
+
+// the columns of the LastPass CSV export, found by header name
+var lastPassColumns = []string{"url", "username", "password", "totp", "extra", "name", "grouping", "fav"}
+
+func readLastPassCSV(path string) ([]enpasscli.Item, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+
+	header, err := r.Read()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read the CSV header")
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range []string{"url", "username", "password", "name"} {
+		if _, ok := columns[name]; !ok {
+			return nil, errors.Errorf("not a LastPass CSV export, there is no %q column", name)
+		}
+	}
+
+	var items []enpasscli.Item
+	for line := 2; ; line++ {
+		row, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", line)
+		}
+
+		values := make(map[string]string)
+		for _, name := range lastPassColumns {
+			if i, ok := columns[name]; ok && i < len(row) {
+				values[name] = row[i]
+			}
+		}
+
+		var item enpasscli.Item
+		if values["url"] == lastPassNoteURL {
+			item = enpasscli.Item{Title: values["name"], Category: "note", Template: "note.default"}
+		} else {
+			item = newLogin(values["name"], values["username"], "", values["password"], values["url"], values["totp"])
+		}
+
+		item.Note = values["extra"]
+		item.Favorite = values["fav"] == "1"
+		// nested folders are separated by backslashes
+		item.Tags = appendTag(nil, strings.Replace(values["grouping"], "\\", "/", -1))
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
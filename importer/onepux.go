@@ -0,0 +1,245 @@
+package importer
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"main/enpasscli"
+)
+
+// the export.data JSON inside a 1Password .1pux archive
+
+const onePuxDataFile = "export.data"
+
+// 1Password category uuids
+var onePuxCategories = map[string]string{
+	"001": "login",
+	"002": "creditcard",
+	"003": "note",
+	"004": "identity",
+	"005": "password",
+}
+
+type onePuxExport struct {
+	Accounts []struct {
+		Vaults []struct {
+			Items []onePuxItem `json:"items"`
+		} `json:"vaults"`
+	} `json:"accounts"`
+}
+
+type onePuxItem struct {
+	FavIndex     int    `json:"favIndex"`
+	State        string `json:"state"`
+	CategoryUUID string `json:"categoryUuid"`
+	Details      struct {
+		LoginFields []struct {
+			Value       string `json:"value"`
+			Name        string `json:"name"`
+			Designation string `json:"designation"`
+		} `json:"loginFields"`
+		NotesPlain string `json:"notesPlain"`
+		Password   string `json:"password"`
+		Sections   []struct {
+			Title  string `json:"title"`
+			Fields []struct {
+				Title string                     `json:"title"`
+				ID    string                     `json:"id"`
+				Value map[string]json.RawMessage `json:"value"`
+			} `json:"fields"`
+		} `json:"sections"`
+	} `json:"details"`
+	Overview struct {
+		Title string `json:"title"`
+		URL   string `json:"url"`
+		URLs  []struct {
+			URL string `json:"url"`
+		} `json:"urls"`
+		Tags []string `json:"tags"`
+	} `json:"overview"`
+
+	// older exports wrap every item
+	Item *onePuxItem `json:"item"`
+}
+
+func read1PUX(path string) ([]enpasscli.Item, error) {
+	archive, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "not a 1Password .1pux archive")
+	}
+	defer archive.Close()
+
+	for _, file := range archive.File {
+		if file.Name != onePuxDataFile {
+			continue
+		}
+
+		r, err := file.Open()
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(r)
+		r.Close()
+		if err != nil {
+			return nil, err
+		}
+
+		return parse1PUX(data)
+	}
+
+	return nil, errors.Errorf("archive has no %s", onePuxDataFile)
+}
+
+func parse1PUX(data []byte) ([]enpasscli.Item, error) {
+	var export onePuxExport
+	if err := json.Unmarshal(data, &export); err != nil {
+		return nil, errors.Wrap(err, "could not parse 1Password export data")
+	}
+
+	var items []enpasscli.Item
+	for _, account := range export.Accounts {
+		for _, vault := range account.Vaults {
+			for _, opItem := range vault.Items {
+				if opItem.Item != nil {
+					opItem = *opItem.Item
+				}
+				items = append(items, onePuxToItem(opItem))
+			}
+		}
+	}
+
+	return items, nil
+}
+
+func onePuxToItem(opItem onePuxItem) enpasscli.Item {
+	category, ok := onePuxCategories[opItem.CategoryUUID]
+	if !ok {
+		category = "note"
+	}
+
+	var username, password string
+	for _, field := range opItem.Details.LoginFields {
+		switch field.Designation {
+		case "username":
+			username = field.Value
+		case "password":
+			password = field.Value
+		}
+	}
+	if password == "" {
+		password = opItem.Details.Password
+	}
+
+	var item enpasscli.Item
+	if category == "login" || category == "password" {
+		item = newLogin(opItem.Overview.Title, username, "", password, opItem.Overview.URL, "")
+		item.Category, item.Template = category, category+".default"
+		for _, u := range opItem.Overview.URLs {
+			if u.URL != opItem.Overview.URL {
+				addField(&item, "Website", "url", u.URL)
+			}
+		}
+	} else {
+		item = enpasscli.Item{Title: opItem.Overview.Title, Category: category, Template: category + ".default"}
+	}
+
+	item.Note = opItem.Details.NotesPlain
+	item.Favorite = opItem.FavIndex > 0
+	item.Archived = opItem.State == "archived"
+	for _, tag := range opItem.Overview.Tags {
+		item.Tags = appendTag(item.Tags, tag)
+	}
+
+	for _, section := range opItem.Details.Sections {
+		for _, field := range section.Fields {
+			label := field.Title
+			if label == "" {
+				label = field.ID
+			}
+
+			fieldType, value, sensitive := onePuxValue(field.ID, field.Value)
+			addFieldSensitive(&item, label, fieldType, value, sensitive)
+		}
+	}
+
+	return item
+}
+
+// onePuxValue : the Enpass field type and text of a section field value, which is keyed by its kind
+func onePuxValue(id string, value map[string]json.RawMessage) (string, string, bool) {
+	for kind, raw := range value {
+		var text string
+		var number int64
+
+		switch kind {
+		case "email":
+			// {"email_address": ..., "provider": ...}, or a plain string in older exports
+			var email struct {
+				Address string `json:"email_address"`
+			}
+			if json.Unmarshal(raw, &email) != nil {
+				json.Unmarshal(raw, &email.Address)
+			}
+			return "email", email.Address, false
+		case "monthYear":
+			// YYYYMM
+			if json.Unmarshal(raw, &number) == nil && number > 0 {
+				return onePuxFieldType(id, "text"), fmt.Sprintf("%02d/%02d", number%100, number/100%100), false
+			}
+			return "text", "", false
+		case "date":
+			if json.Unmarshal(raw, &number) == nil && number > 0 {
+				return "text", time.Unix(number, 0).UTC().Format("2006-01-02"), false
+			}
+			return "text", "", false
+		}
+
+		if json.Unmarshal(raw, &text) != nil {
+			continue
+		}
+
+		switch kind {
+		case "concealed":
+			return onePuxFieldType(id, "text"), text, true
+		case "totp":
+			return "totp", text, true
+		case "url":
+			return "url", text, false
+		case "phone":
+			return "phone", text, false
+		case "creditCardNumber":
+			return "ccNumber", text, true
+		case "creditCardType":
+			return "ccType", text, false
+		default:
+			return onePuxFieldType(id, "text"), text, false
+		}
+	}
+
+	return "text", "", false
+}
+
+// onePuxFieldType : the Enpass credit card field type of well-known 1Password field ids
+func onePuxFieldType(id string, fallback string) string {
+	switch id {
+	case "cardholder":
+		return "ccName"
+	case "cvv":
+		return "ccCvc"
+	case "pin":
+		return "ccPin"
+	case "expiry":
+		return "ccExpiry"
+	case "validFrom":
+		return "ccValidFrom"
+	case "bank":
+		return "ccBankname"
+	default:
+		return fallback
+	}
+}
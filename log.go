@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+)
+
+type logLevel int
+
+const (
+	levelDebug logLevel = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var logLevels = map[string]logLevel{
+	"debug": levelDebug,
+	"info":  levelInfo,
+	"warn":  levelWarn,
+	"error": levelError,
+}
+
+// logger : leveled logging to stderr, never used for item contents
+type logger struct {
+	level logLevel
+	out   *log.Logger
+}
+
+func newLogger(w io.Writer, level string) (*logger, error) {
+	l, ok := logLevels[level]
+	if !ok {
+		return nil, fmt.Errorf("unknown log level %q", level)
+	}
+
+	return &logger{level: l, out: log.New(w, "enpass: ", 0)}, nil
+}
+
+func (l *logger) logf(level logLevel, format string, args ...interface{}) {
+	if level >= l.level {
+		l.out.Printf(format, args...)
+	}
+}
+
+func (l *logger) Debugf(format string, args ...interface{}) {
+	l.logf(levelDebug, format, args...)
+}
+
+func (l *logger) Infof(format string, args ...interface{}) {
+	l.logf(levelInfo, format, args...)
+}
+
+func (l *logger) Warnf(format string, args ...interface{}) {
+	l.logf(levelWarn, format, args...)
+}
+
+func (l *logger) Errorf(format string, args ...interface{}) {
+	l.logf(levelError, format, args...)
+}
@@ -1,18 +1,281 @@
 package main
 
 import (
-	"log"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"main/agent"
 	"main/enpasscli"
 )
 
+const (
+	// default vault directory, overridden by -vault
+	envVault = "ENPASS_VAULT"
+)
+
+// command : an enpass subcommand
+type command struct {
+	name    string
+	usage   string
+	summary string
+	run     func(a *app, args []string) error
+}
+
+var commands = []command{
+	{name: "list", usage: "list [flags]", summary: "list items, optionally filtered and sorted", run: runList},
+	{name: "show", usage: "show <item>", summary: "show an item and its fields", run: runShow},
+	{name: "get", usage: "get <item> [field]", summary: "print one field of an item, the password by default", run: runGet},
+	{name: "otp", usage: "otp [flags] <item>", summary: "print the current one-time password of an item", run: runOTP},
+	{name: "search", usage: "search [flags] <text>", summary: "list items whose title, subtitle, url, username or note contain text", run: runSearch},
+	{name: "attachment", usage: "attachment list|get ...", summary: "list the attachments of an item or write one out", run: runAttachment},
+	{name: "info", usage: "info", summary: "show vault information", run: runInfo},
+	{name: "passwd", usage: "passwd [flags]", summary: "change the master password", run: runPasswd},
+	{name: "backup", usage: "backup [-encrypt] [-out file]", summary: "archive the vault files, optionally encrypted with a backup passphrase", run: runBackup},
+	{name: "restore", usage: "restore [-force] [-item <item>] <backup>", summary: "verify a backup and restore the vault or a single item from it", run: runRestore},
+	{name: "agent", usage: "agent [-socket path] [-idle-timeout d]", summary: "keep the vault unlocked in the background, start it with eval \"$(enpass agent)\"", run: runAgent},
+	{name: "verify", usage: "verify", summary: "check the vault database, item keys, fields and attachment files for damage", run: runVerify},
+	{name: "vaults", usage: "vaults [flags] [root]", summary: "list the vaults below an Enpass data directory", run: runVaults},
+	{name: "generate", usage: "generate [flags]", summary: "print a random password or passphrase", run: runGenerate},
+	{name: "rotate", usage: "rotate [flags] <item>", summary: "replace a field of an item with a generated password", run: runRotate},
+	{name: "import", usage: "import -from format [-commit] <file>", summary: "import items exported from another password manager", run: runImport},
+	{name: "audit", usage: "audit [breached] [flags]", summary: "report weak, reused, old or breached passwords, logins without TOTP and http urls", run: runAudit},
+	{name: "export", usage: "export [-to format] [-out file]", summary: "export all items in plaintext, as JSON or for another password manager", run: runExport},
+}
+
+// app : state shared by all subcommands
+type app struct {
+	ctx    context.Context
+	log    *logger
+	stdout io.Writer
+	stderr io.Writer
+
+	vaultDir    string
+	keyfilePath string
+	passwordFD  int
+	// never write to the vault files, snapshot reads a private copy of the database
+	readOnly bool
+	snapshot bool
+
+	// opened on first use
+	vault *enpasscli.Vault
+	agent *agent.Client
+}
+
+// itemSource : the reads of the read commands, served by the unlocked vault or by an agent
+type itemSource interface {
+	Info() enpasscli.VaultInfo
+	GetItems(ctx context.Context) ([]enpasscli.Item, error)
+	FindItem(ctx context.Context, query string) (enpasscli.Item, error)
+	Search(ctx context.Context, query enpasscli.Query) ([]enpasscli.Item, error)
+}
+
+// items : the agent at ENPASS_AGENT_SOCK if it serves the vault, else the vault unlocked with openVault
+func (a *app) items() (itemSource, error) {
+	if client := a.dialAgent(); client != nil {
+		return client, nil
+	}
+
+	vault, err := a.openVault()
+	if err != nil {
+		return nil, err
+	}
+
+	return vault, nil
+}
+
+// openVault : unlock the vault, prompting for the master password if needed
+func (a *app) openVault() (*enpasscli.Vault, error) {
+	if a.vault != nil {
+		return a.vault, nil
+	}
+
+	// only prompt once the vault turned out to exist and be supported
+	return a.open(enpasscli.WithPasswordSource(func(context.Context) ([]byte, error) {
+		return readPassword(a.passwordFD)
+	}))
+}
+
+// openVaultWith : unlock the vault with a password the command already read
+func (a *app) openVaultWith(password []byte) (*enpasscli.Vault, error) {
+	return a.open(enpasscli.WithPassword(password))
+}
+
+func (a *app) open(opts ...enpasscli.Option) (*enpasscli.Vault, error) {
+	a.log.Debugf("opening vault %s", a.vaultDir)
+
+	vault, err := enpasscli.Open(a.ctx, a.vaultDir, append(opts, a.openOptions()...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	a.vault = vault
+	return a.vault, nil
+}
+
+// openOptions : the options every vault the command opens shares, besides the password
+func (a *app) openOptions() []enpasscli.Option {
+	opts := []enpasscli.Option{enpasscli.WithKeyfile(a.keyfilePath), enpasscli.WithLogger(a.log), enpasscli.WithLockedMemory()}
+	if a.snapshot {
+		opts = append(opts, enpasscli.WithSnapshot())
+	} else if a.readOnly {
+		opts = append(opts, enpasscli.WithReadOnly())
+	}
+
+	return opts
+}
+
+// checkWritable : fail early in commands that change the vault files
+func (a *app) checkWritable() error {
+	if a.readOnly || a.snapshot {
+		return enpasscli.ErrReadOnly
+	}
+
+	return nil
+}
+
+func (a *app) close() {
+	if a.vault != nil {
+		a.vault.Close()
+		a.vault = nil
+	}
+	if a.agent != nil {
+		a.agent.Close()
+		a.agent = nil
+	}
+}
+
 func main() {
-	enpass, err := enpasscli.OpenVault("vault.enpassdb", "", []byte("mymasterpassword"))
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		cancel()
+	}()
+
+	code := run(ctx, os.Args[1:], os.Stdout, os.Stderr)
+	cancel()
+	os.Exit(code)
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	a := &app{ctx: ctx, stdout: stdout, stderr: stderr}
+
+	defaultVaultDir := os.Getenv(envVault)
+	if defaultVaultDir == "" {
+		defaultVaultDir = "."
+	}
+
+	fs := flag.NewFlagSet("enpass", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&a.vaultDir, "vault", defaultVaultDir, "vault directory containing vault.enpassdb and vault.json (env "+envVault+")")
+	fs.StringVar(&a.keyfilePath, "keyfile", "", "keyfile, if the vault uses one")
+	fs.IntVar(&a.passwordFD, "password-fd", -1, "read the master password from this file descriptor")
+	fs.BoolVar(&a.readOnly, "read-only", false, "never write to the vault files, commands that would fail")
+	fs.BoolVar(&a.snapshot, "snapshot", false, "like -read-only, but read a private copy of the database so Enpass can keep writing")
+	logLevel := fs.String("log-level", "info", "log level: debug, info, warn or error")
+	fs.Usage = func() { printUsage(fs) }
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	log, err := newLogger(stderr, *logLevel)
 	if err != nil {
-		log.Fatalf("could not open vault: %v", err)
+		fmt.Fprintf(stderr, "enpass: %v\n", err)
+		return exitUsage
 	}
+	a.log = log
 
-	defer enpass.Close()
+	if fs.NArg() == 0 {
+		printUsage(fs)
+		return exitUsage
+	}
+
+	cmd := findCommand(fs.Arg(0))
+	if cmd == nil {
+		fmt.Fprintf(stderr, "enpass: unknown command %q\n", fs.Arg(0))
+		printUsage(fs)
+		return exitUsage
+	}
 
-	log.Println("printing tables")
-	log.Fatal(enpass.GetCards())
+	err = cmd.run(a, fs.Args()[1:])
+	a.close()
+
+	if err != nil {
+		if err != flag.ErrHelp {
+			a.log.Errorf("%s: %v", cmd.name, err)
+		}
+		return exitCode(err)
+	}
+
+	return exitOK
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
+	}
+
+	return nil
+}
+
+func printUsage(fs *flag.FlagSet) {
+	out := fs.Output()
+	fmt.Fprintln(out, "usage: enpass [flags] <command> [command flags] [args]")
+	fmt.Fprintln(out, "\ncommands:")
+	for _, cmd := range commands {
+		fmt.Fprintf(out, "  %-28s %s\n", cmd.usage, cmd.summary)
+	}
+	fmt.Fprintln(out, "\nflags:")
+	fs.PrintDefaults()
+	fmt.Fprintf(out, "\nthe master password is read from -password-fd, %s or a terminal prompt\n", envPassword)
+	fmt.Fprintf(out, "with %s set, list, search, show, get, otp, info and audit read from that agent instead\n", envAgentSock)
+}
+
+// parseFlags : parse subcommand flags, reporting bad input as a usage error
+func parseFlags(fs *flag.FlagSet, args []string, output io.Writer) error {
+	fs.SetOutput(output)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return usageErrorf("%v", err)
+	}
+
+	return nil
+}
+
+// parseFlagsInterspersed : parseFlags for commands whose flags may also follow the arguments, returns the arguments
+func parseFlagsInterspersed(fs *flag.FlagSet, args []string, output io.Writer) ([]string, error) {
+	var positional []string
+	for {
+		if err := parseFlags(fs, args, output); err != nil {
+			return nil, err
+		}
+
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return positional, nil
+		}
+
+		// everything after "--" is an argument
+		if consumed := len(args) - len(rest); consumed > 0 && args[consumed-1] == "--" {
+			return append(positional, rest...), nil
+		}
+
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
 }
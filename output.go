@@ -0,0 +1,347 @@
+package main
+
+// Every read command prints its result in the format chosen with -format.
+//
+// Structured formats (json, yaml) print one document:
+//
+//   {"schema_version": 1, "kind": "<kind>", "records": [<record>, ...]}
+//
+// jsonl prints one record per line, each carrying "schema_version" and "kind".
+// csv and tsv print a header row, also without records, followed by one row per
+// flat record, lists are joined with commas. text is meant for humans and may change at any time.
+//
+// Timestamps are RFC 3339 in UTC, booleans are booleans and absent lists are
+// empty lists. Sensitive values are printed as "********" unless -reveal is
+// given. Kinds and their record keys, in order:
+//
+//   item     (list, search)  uuid, title, subtitle, category, template, tags,
+//                            favorite, trashed, archived, created_at, updated_at
+//   item     (show)          the above followed by note and fields, a list of
+//                            field records; csv and tsv print field records
+//   field    (get, rotate)   item_uuid, item_title, uid, label, type, value,
+//                            sensitive, order, updated_at; get never masks
+//                            the value it was asked for, rotate prints the
+//                            new value
+//   otp      (otp)           item_uuid, item_title, code, expires_at,
+//                            remaining_seconds
+//   attachment               uuid, item_uuid, name, mime, size, inline,
+//   (attachment list)        created_at, updated_at
+//   vault    (info)          name, version, items, keyfile, encryption_algo,
+//                            kdf_algo, kdf_iterations
+//   vault_ref (vaults)       dir, name, uuid, items, keyfile, last_modified
+//   generated (generate)     type, value, entropy_bits
+//   import_step (import)     action, title, category, tags, reason,
+//                            duplicate_of, uuid; uuid is empty without
+//                            -commit
+//   finding  (audit)         check, item_uuid, item_title, field, detail,
+//                            prevalence; never contains a secret
+//   problem  (verify)        problem, uuid, detail; uuid is empty for problems
+//                            of the whole vault
+//
+// schemaVersion is raised whenever a key is renamed or removed, or its type changes.
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	schemaVersion = 1
+	// replaces sensitive values unless -reveal is given
+	maskedValue = "********"
+)
+
+var outputFormats = []string{"text", "json", "jsonl", "csv", "yaml", "tsv"}
+
+// kv : one key of a record, values are string, bool, int, time.Time, []string or []record
+type kv struct {
+	key   string
+	value interface{}
+}
+
+// record : an ordered set of keys, so every format prints them in the documented order
+type record []kv
+
+func (r record) MarshalJSON() ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, entry := range r {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		key, _ := json.Marshal(entry.key)
+		value, err := json.Marshal(jsonValue(entry.value))
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+func jsonValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case time.Time:
+		return formatTime(v)
+	case []string:
+		if v == nil {
+			return []string{}
+		}
+	case []record:
+		if v == nil {
+			return []record{}
+		}
+	}
+
+	return value
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+// output : a command result
+type output struct {
+	kind    string
+	records []record
+	// rows for csv and tsv when records are nested, defaults to records
+	flat []record
+	// keys of the flat records, so csv and tsv print a header without any records
+	columns []string
+	// human readable rendering, defaults to a table of the flat rows
+	text func(w io.Writer) error
+}
+
+// outputFlags : -format and, for commands printing secrets, -reveal
+type outputFlags struct {
+	format string
+	reveal bool
+}
+
+func addOutputFlags(fs *flag.FlagSet, withReveal bool) *outputFlags {
+	f := &outputFlags{}
+	fs.StringVar(&f.format, "format", "text", "output format: "+strings.Join(outputFormats, ", "))
+	if withReveal {
+		fs.BoolVar(&f.reveal, "reveal", false, "print sensitive values instead of masking them")
+	}
+	return f
+}
+
+func (f *outputFlags) validate() error {
+	for _, format := range outputFormats {
+		if f.format == format {
+			return nil
+		}
+	}
+
+	return usageErrorf("invalid -format %q, use one of %s", f.format, strings.Join(outputFormats, ", "))
+}
+
+func (f *outputFlags) write(w io.Writer, out output) error {
+	var err error
+
+	switch f.format {
+	case "json":
+		err = writeJSON(w, out)
+	case "jsonl":
+		err = writeJSONLines(w, out)
+	case "yaml":
+		err = writeYAML(w, out)
+	case "csv":
+		err = writeSeparated(w, out, ',')
+	case "tsv":
+		err = writeSeparated(w, out, '\t')
+	default:
+		if out.text != nil {
+			err = out.text(w)
+		} else {
+			err = writeTable(w, out.flatRecords())
+		}
+	}
+
+	return errors.Wrap(err, "could not write output")
+}
+
+func (o output) flatRecords() []record {
+	if o.flat != nil {
+		return o.flat
+	}
+
+	return o.records
+}
+
+// header : the keys of the flat records, taken from the first one when the output has no columns
+func (o output) header() []string {
+	if o.columns != nil {
+		return o.columns
+	}
+	if records := o.flatRecords(); len(records) > 0 {
+		return records[0].keys()
+	}
+
+	return nil
+}
+
+func (r record) keys() []string {
+	keys := make([]string, len(r))
+	for i, entry := range r {
+		keys[i] = entry.key
+	}
+
+	return keys
+}
+
+func (o output) envelope() record {
+	records := o.records
+	if records == nil {
+		records = []record{}
+	}
+
+	return record{{"schema_version", schemaVersion}, {"kind", o.kind}, {"records", records}}
+}
+
+func writeJSON(w io.Writer, out output) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out.envelope())
+}
+
+func writeJSONLines(w io.Writer, out output) error {
+	enc := json.NewEncoder(w)
+	for _, r := range out.records {
+		line := append(record{{"schema_version", schemaVersion}, {"kind", out.kind}}, r...)
+		if err := enc.Encode(line); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeSeparated(w io.Writer, out output, separator rune) error {
+	records := out.flatRecords()
+
+	cw := csv.NewWriter(w)
+	cw.Comma = separator
+
+	if header := out.header(); header != nil {
+		if err := cw.Write(header); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range records {
+		row := make([]string, len(r))
+		for i, entry := range r {
+			row[i] = scalarString(entry.value)
+		}
+		if err := cw.Write(row); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// scalarString : plain text of a value for csv, tsv and text
+func scalarString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case time.Time:
+		return formatTime(v)
+	case []string:
+		return strings.Join(v, ",")
+	case []record:
+		return fmt.Sprintf("%d entries", len(v))
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// writeTable : aligned columns with an upper case header
+func writeTable(w io.Writer, records []record) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+
+	if len(records) > 0 {
+		header := make([]string, len(records[0]))
+		for i, entry := range records[0] {
+			header[i] = strings.ToUpper(entry.key)
+		}
+		fmt.Fprintln(tw, strings.Join(header, "\t"))
+	}
+
+	for _, r := range records {
+		row := make([]string, len(r))
+		for i, entry := range r {
+			row[i] = scalarString(entry.value)
+		}
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+
+	return tw.Flush()
+}
+
+// writeKeyValues : one "key: value" line per entry of a single record
+func writeKeyValues(w io.Writer, r record) error {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, entry := range r {
+		fmt.Fprintf(tw, "%s:\t%s\n", entry.key, scalarString(entry.value))
+	}
+
+	return tw.Flush()
+}
+
+func writeYAML(w io.Writer, out output) error {
+	var buf bytes.Buffer
+	if err := writeYAMLRecord(&buf, out.envelope(), 0, false); err != nil {
+		return err
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// writeYAMLRecord : block mappings, strings are quoted JSON strings which YAML reads back verbatim
+func writeYAMLRecord(buf *bytes.Buffer, r record, indent int, listItem bool) error {
+	for i, entry := range r {
+		prefix := strings.Repeat(" ", indent)
+		if listItem && i == 0 {
+			prefix = strings.Repeat(" ", indent-2) + "- "
+		}
+
+		if nested, ok := entry.value.([]record); ok && len(nested) > 0 {
+			fmt.Fprintf(buf, "%s%s:\n", prefix, entry.key)
+			for _, child := range nested {
+				if err := writeYAMLRecord(buf, child, indent+4, true); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+
+		value, err := json.Marshal(jsonValue(entry.value))
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(buf, "%s%s: %s\n", prefix, entry.key, value)
+	}
+
+	return nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// master password, used when -password-fd is not given
+	envPassword = "ENPASS_PASSWORD"
+	// new master password for passwd, used when -new-password-fd is not given
+	envNewPassword = "ENPASS_NEW_PASSWORD"
+	// passphrase of backup and restore, used when -passphrase-fd is not given
+	envBackupPassphrase = "ENPASS_BACKUP_PASSPHRASE"
+	// terminal the password prompt is written to and read from
+	ttyPath = "/dev/tty"
+)
+
+// readPassword : master password from the file descriptor, the environment or a no-echo terminal prompt
+func readPassword(fd int) ([]byte, error) {
+	return readSecret(fd, envPassword, "Master password: ")
+}
+
+// readNewPassword : like readPassword, a prompted password has to be typed twice
+func readNewPassword(fd int) ([]byte, error) {
+	return readConfirmedSecret(fd, envNewPassword, "New master password: ", "Repeat new master password: ", "the new master passwords do not match")
+}
+
+// readBackupPassphrase : passphrase of an encrypted backup, typed twice when confirm is set
+func readBackupPassphrase(fd int, confirm bool) ([]byte, error) {
+	if !confirm {
+		return readSecret(fd, envBackupPassphrase, "Backup passphrase: ")
+	}
+
+	return readConfirmedSecret(fd, envBackupPassphrase, "Backup passphrase: ", "Repeat backup passphrase: ", "the backup passphrases do not match")
+}
+
+// readConfirmedSecret : like readSecret, but a prompted secret has to be typed twice
+func readConfirmedSecret(fd int, env string, prompt string, repeatPrompt string, mismatch string) ([]byte, error) {
+	_, inEnv := os.LookupEnv(env)
+
+	secret, err := readSecret(fd, env, prompt)
+	if err != nil || fd >= 0 || inEnv {
+		return secret, err
+	}
+
+	confirmation, err := readSecret(fd, env, repeatPrompt)
+	if err != nil {
+		wipe(secret)
+		return nil, err
+	}
+	defer wipe(confirmation)
+
+	if !bytes.Equal(secret, confirmation) {
+		wipe(secret)
+		return nil, usageErrorf("%s", mismatch)
+	}
+
+	return secret, nil
+}
+
+// readSecret : read from the file descriptor if set, else from env if set, else prompt on the terminal
+func readSecret(fd int, env string, prompt string) ([]byte, error) {
+	if fd >= 0 {
+		f := os.NewFile(uintptr(fd), "password-fd")
+		if f == nil {
+			return nil, usageErrorf("invalid password file descriptor %d", fd)
+		}
+		defer f.Close()
+
+		return readLine(f)
+	}
+
+	if password, ok := os.LookupEnv(env); ok {
+		// do not pass the password on to anything we might spawn
+		os.Unsetenv(env)
+		return []byte(password), nil
+	}
+
+	tty, err := os.OpenFile(ttyPath, os.O_RDWR, 0)
+	if err != nil {
+		return nil, errors.Wrapf(err, "no terminal to prompt for the password, set %s or pass a file descriptor", env)
+	}
+	defer tty.Close()
+
+	fmt.Fprint(tty, prompt)
+	defer fmt.Fprintln(tty)
+
+	restore, err := disableEcho(tty)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not disable terminal echo")
+	}
+	defer restore()
+
+	return readLine(tty)
+}
+
+// readLine : read up to the first newline, without it
+func readLine(r io.Reader) ([]byte, error) {
+	line, err := bufio.NewReader(r).ReadSlice('\n')
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "could not read master password")
+	}
+
+	// ReadSlice returns a view into the reader's buffer, copy it so we own the only reference
+	password := make([]byte, len(line))
+	copy(password, line)
+	wipe(line)
+
+	for len(password) > 0 && (password[len(password)-1] == '\n' || password[len(password)-1] == '\r') {
+		password = password[:len(password)-1]
+	}
+
+	return password, nil
+}
+
+// wipe : overwrite a secret once it is no longer needed
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
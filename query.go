@@ -0,0 +1,84 @@
+package main
+
+import (
+	"flag"
+	"regexp"
+	"strings"
+
+	"main/enpasscli"
+)
+
+var sortOrders = map[string]enpasscli.SortOrder{
+	"none":     enpasscli.SortNone,
+	"title":    enpasscli.SortByTitle,
+	"modified": enpasscli.SortByModified,
+}
+
+// queryFlags : the item filters shared by list and search
+type queryFlags struct {
+	text            string
+	regex           string
+	categories      string
+	tags            string
+	sort            string
+	favorite        bool
+	includeTrashed  bool
+	includeArchived bool
+}
+
+func addQueryFlags(fs *flag.FlagSet) *queryFlags {
+	f := &queryFlags{}
+	fs.StringVar(&f.text, "q", "", "only items whose title, subtitle, url, username or note contain this text")
+	fs.StringVar(&f.regex, "regex", "", "only items whose title, subtitle, url, username or note match this regular expression")
+	fs.StringVar(&f.categories, "category", "", "only items in these comma-separated categories, e.g. login,creditcard,identity,note")
+	fs.StringVar(&f.tags, "tag", "", "only items with one of these comma-separated tags")
+	fs.StringVar(&f.sort, "sort", "title", "sort by title, modified or none")
+	fs.BoolVar(&f.favorite, "favorite", false, "only favorite items")
+	fs.BoolVar(&f.includeTrashed, "include-trashed", false, "include trashed items")
+	fs.BoolVar(&f.includeArchived, "include-archived", false, "include archived items")
+	return f
+}
+
+func (f *queryFlags) query() (enpasscli.Query, error) {
+	query := enpasscli.Query{
+		Text:          f.text,
+		Categories:    splitList(f.categories),
+		Tags:          splitList(f.tags),
+		FavoritesOnly: f.favorite,
+	}
+
+	if f.regex != "" {
+		re, err := regexp.Compile(f.regex)
+		if err != nil {
+			return enpasscli.Query{}, usageErrorf("invalid -regex: %v", err)
+		}
+		query.Regexp = re
+	}
+
+	sortOrder, ok := sortOrders[f.sort]
+	if !ok {
+		return enpasscli.Query{}, usageErrorf("invalid -sort %q, use title, modified or none", f.sort)
+	}
+	query.Sort = sortOrder
+
+	if f.includeTrashed {
+		query.Trashed = enpasscli.Include
+	}
+	if f.includeArchived {
+		query.Archived = enpasscli.Include
+	}
+
+	return query, nil
+}
+
+// splitList : comma-separated flag value, without empty entries
+func splitList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+
+	return list
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"main/audit"
+	"main/enpasscli"
+	"main/importer"
+)
+
+// itemRecord : the item keys of the output schema, see output.go
+func itemRecord(item enpasscli.Item) record {
+	return record{
+		{"uuid", item.UUID},
+		{"title", item.Title},
+		{"subtitle", item.Subtitle},
+		{"category", item.Category},
+		{"template", item.Template},
+		{"tags", item.Tags},
+		{"favorite", item.Favorite},
+		{"trashed", item.Trashed},
+		{"archived", item.Archived},
+		{"created_at", item.CreatedAt},
+		{"updated_at", item.UpdatedAt},
+	}
+}
+
+// itemDetailRecord : itemRecord followed by the note and all fields
+func itemDetailRecord(item enpasscli.Item, reveal bool) record {
+	fields := make([]record, 0, len(item.Fields))
+	for _, field := range item.Fields {
+		fields = append(fields, fieldRecord(item, field, reveal))
+	}
+
+	return append(itemRecord(item), kv{"note", item.Note}, kv{"fields", fields})
+}
+
+func fieldRecord(item enpasscli.Item, field enpasscli.Field, reveal bool) record {
+	return record{
+		{"item_uuid", item.UUID},
+		{"item_title", item.Title},
+		{"uid", field.UID},
+		{"label", field.Label},
+		{"type", field.Type},
+		{"value", fieldValue(field, reveal)},
+		{"sensitive", field.Sensitive},
+		{"order", field.Order},
+		{"updated_at", field.UpdatedAt},
+	}
+}
+
+// fieldValue : mask sensitive values unless asked not to
+func fieldValue(field enpasscli.Field, reveal bool) string {
+	if field.Sensitive && !reveal && field.Value != "" {
+		return maskedValue
+	}
+
+	return field.Value
+}
+
+func attachmentRecord(attachment enpasscli.Attachment) record {
+	return record{
+		{"uuid", attachment.UUID},
+		{"item_uuid", attachment.ItemUUID},
+		{"name", attachment.Name},
+		{"mime", attachment.Mime},
+		{"size", attachment.Size},
+		{"inline", attachment.Inline},
+		{"created_at", attachment.CreatedAt},
+		{"updated_at", attachment.UpdatedAt},
+	}
+}
+
+func vaultRecord(info enpasscli.VaultInfo) record {
+	return record{
+		{"name", info.VaultName},
+		{"version", info.VaultVersion},
+		{"items", info.VaultNumItems},
+		{"keyfile", info.HasKeyfile == 1},
+		{"encryption_algo", info.EncryptionAlgo},
+		{"kdf_algo", info.KDFAlgo},
+		{"kdf_iterations", info.KDFIterations},
+	}
+}
+
+func vaultRefRecord(ref enpasscli.VaultRef) record {
+	return record{
+		{"dir", ref.Dir},
+		{"name", ref.Name},
+		{"uuid", ref.UUID},
+		{"items", ref.Items},
+		{"keyfile", ref.HasKeyfile},
+		{"last_modified", ref.LastModified},
+	}
+}
+
+func findingRecord(finding audit.Finding) record {
+	return record{
+		{"check", string(finding.Check)},
+		{"item_uuid", finding.ItemUUID},
+		{"item_title", finding.ItemTitle},
+		{"field", finding.Field},
+		{"detail", finding.Detail},
+		{"prevalence", finding.Prevalence},
+	}
+}
+
+func problemRecord(problem enpasscli.Problem) record {
+	return record{
+		{"problem", string(problem.Kind)},
+		{"uuid", problem.UUID},
+		{"detail", problem.Detail},
+	}
+}
+
+// importStepRecord : uuid is only set once the item was created
+func importStepRecord(step importer.Step, uuid string) record {
+	return record{
+		{"action", string(step.Action)},
+		{"title", step.Item.Title},
+		{"category", step.Item.Category},
+		{"tags", step.Item.Tags},
+		{"reason", step.Reason},
+		{"duplicate_of", step.DuplicateOf},
+		{"uuid", uuid},
+	}
+}
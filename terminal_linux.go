@@ -0,0 +1,32 @@
+package main
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// disableEcho : turn off echoing of typed characters, returns a func restoring the previous state
+func disableEcho(tty *os.File) (func(), error) {
+	var old syscall.Termios
+	if err := ioctl(tty.Fd(), syscall.TCGETS, &old); err != nil {
+		return nil, err
+	}
+
+	noEcho := old
+	noEcho.Lflag &^= syscall.ECHO
+	noEcho.Lflag |= syscall.ICANON | syscall.ISIG
+	if err := ioctl(tty.Fd(), syscall.TCSETS, &noEcho); err != nil {
+		return nil, err
+	}
+
+	return func() { ioctl(tty.Fd(), syscall.TCSETS, &old) }, nil
+}
+
+func ioctl(fd uintptr, request uintptr, termios *syscall.Termios) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, fd, request, uintptr(unsafe.Pointer(termios))); errno != 0 {
+		return errno
+	}
+
+	return nil
+}
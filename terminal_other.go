@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+)
+
+func disableEcho(tty *os.File) (func(), error) {
+	return nil, errors.Errorf("no-echo prompt is not supported on this platform, set %s or use -password-fd", envPassword)
+}